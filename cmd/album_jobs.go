@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/notify"
+	"msr-archiver/internal/worker"
+)
+
+const (
+	jobOrderListed     = "listed"
+	jobOrderSmallFirst = "small-first"
+)
+
+// albumTask is an album queued for archiving. refresh revisits a completed
+// album for tracks added since.
+type albumTask struct {
+	album   model.Album
+	refresh bool
+}
+
+// runReport counts the outcomes of one batch of albums.
+type runReport struct {
+	archived  int
+	succeeded int
+	failed    int
+	skipped   int
+}
+
+// jobPriorities returns the start priority of each task, lowest first. The
+// listed order keeps the task order; small-first uses the song counts known
+// from the catalog cache and starts albums of unknown size last, in listed
+// order.
+func jobPriorities(order string, tasks []albumTask, songs map[string][]model.Song) ([]int, error) {
+	priorities := make([]int, len(tasks))
+	switch order {
+	case "", jobOrderListed:
+		for i := range tasks {
+			priorities[i] = i
+		}
+	case jobOrderSmallFirst:
+		for i, task := range tasks {
+			if cached, ok := songs[task.album.CID]; ok {
+				priorities[i] = len(cached)
+			} else {
+				priorities[i] = math.MaxInt32
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid --job-order %q: want %s or %s", order, jobOrderListed, jobOrderSmallFirst)
+	}
+	return priorities, nil
+}
+
+// runAlbums archives tasks in a worker pool, logs the outcome of every
+// album as it finishes and fires the run notifications.
+func (r *albumRunner) runAlbums(ctx context.Context, tasks []albumTask, priorities []int) (runReport, error) {
+	var archived atomic.Int32
+	pool := worker.New(ctx, worker.Options{Workers: r.cfg.Workers, FailFast: r.cfg.FailFast})
+	results := pool.Results()
+
+	started := time.Now()
+	r.notify(ctx, notify.Event{Type: notify.EventRunStarted, Albums: len(tasks)})
+	for i, task := range tasks {
+		if err := pool.Submit(task.album.Name, priorities[i], r.albumJob(task.album, task.refresh, &archived)); err != nil {
+			return runReport{}, err
+		}
+	}
+	pool.Close()
+
+	var report runReport
+	for res := range results {
+		switch {
+		case res.Err == nil:
+			report.succeeded++
+			r.logger.Infof("[%s] Finished in %s", res.ID, res.Duration.Round(time.Millisecond))
+		case res.Skipped():
+			report.skipped++
+			r.logger.Warnf("[%s] Not started: run canceled", res.ID)
+		case errors.Is(res.Err, context.Canceled):
+			report.failed++
+			r.logger.Warnf("[%s] Canceled after %s", res.ID, res.Duration.Round(time.Millisecond))
+		default:
+			report.failed++
+			r.logger.Errorf("[%s] Failed after %s: %v", res.ID, res.Duration.Round(time.Millisecond), res.Err)
+		}
+	}
+
+	_, err := pool.Wait()
+	report.archived = int(archived.Load())
+	r.notifyRunFinished(ctx, report.archived, started, err)
+	if report.failed > 0 || report.skipped > 0 {
+		r.logger.Infof("Albums: %d succeeded, %d failed, %d not started", report.succeeded, report.failed, report.skipped)
+	}
+	return report, err
+}
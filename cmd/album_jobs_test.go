@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"msr-archiver/internal/model"
+)
+
+func TestJobPriorities(t *testing.T) {
+	tasks := []albumTask{
+		{album: model.Album{CID: "big"}},
+		{album: model.Album{CID: "unknown"}},
+		{album: model.Album{CID: "small"}},
+	}
+	songs := map[string][]model.Song{
+		"big":   make([]model.Song, 12),
+		"small": make([]model.Song, 2),
+	}
+
+	listed, err := jobPriorities("listed", tasks, songs)
+	if err != nil || !slices.Equal(listed, []int{0, 1, 2}) {
+		t.Fatalf("listed priorities = %v, %v", listed, err)
+	}
+	small, err := jobPriorities("small-first", tasks, songs)
+	if err != nil || !slices.Equal(small, []int{12, math.MaxInt32, 2}) {
+		t.Fatalf("small-first priorities = %v, %v", small, err)
+	}
+	if _, err := jobPriorities("random", tasks, songs); err == nil {
+		t.Fatalf("expected an error for an unknown order")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"cmp"
+	"sort"
+	"strings"
+
+	"msr-archiver/internal/model"
+)
+
+type albumSortMode int
+
+const (
+	sortCatalog albumSortMode = iota
+	sortName
+	sortArtist
+	sortSongCount
+	sortDownloaded
+	sortModeCount
+)
+
+func (s albumSortMode) String() string {
+	switch s {
+	case sortName:
+		return "name"
+	case sortArtist:
+		return "artist"
+	case sortSongCount:
+		return "song count"
+	case sortDownloaded:
+		return "downloaded"
+	default:
+		return "catalog"
+	}
+}
+
+type albumGroupMode int
+
+const (
+	groupNone albumGroupMode = iota
+	groupArtist
+	groupSeries
+	groupModeCount
+)
+
+func (g albumGroupMode) String() string {
+	switch g {
+	case groupArtist:
+		return "artist"
+	case groupSeries:
+		return "series"
+	default:
+		return "none"
+	}
+}
+
+// albumOrder sorts and groups picker rows.
+type albumOrder struct {
+	sort    albumSortMode
+	reverse bool
+	group   albumGroupMode
+}
+
+// albumGroupKey returns the heading an album is listed under.
+func albumGroupKey(album model.Album, mode albumGroupMode) string {
+	switch mode {
+	case groupArtist:
+		if len(album.Artistes) == 0 || strings.TrimSpace(album.Artistes[0]) == "" {
+			return "Unknown artist"
+		}
+		return strings.TrimSpace(album.Artistes[0])
+	case groupSeries:
+		return albumSeries(album.Name)
+	default:
+		return ""
+	}
+}
+
+// albumSeries approximates the series of an album from its name: the text
+// before a " - ", ":" or parenthesized suffix, e.g. "Operation Originium" for
+// "Operation Originium (Instrumental)".
+func albumSeries(name string) string {
+	series := name
+	for _, sep := range []string{" - ", ":", "：", "(", "（"} {
+		if idx := strings.Index(series, sep); idx > 0 {
+			series = series[:idx]
+		}
+	}
+	series = strings.TrimSpace(series)
+	if series == "" {
+		return strings.TrimSpace(name)
+	}
+	return series
+}
+
+// sortAlbumIndexes orders catalog indexes by group, then sort key, then
+// catalog order. songCounts holds counts for albums whose songs are loaded;
+// unknown counts sort last in either direction.
+func sortAlbumIndexes(indexes []int, albums []model.Album, completed map[int]bool, songCounts map[int]int, order albumOrder) {
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := indexes[i], indexes[j]
+		if order.group != groupNone {
+			ga := strings.ToLower(albumGroupKey(albums[a], order.group))
+			gb := strings.ToLower(albumGroupKey(albums[b], order.group))
+			if ga != gb {
+				return ga < gb
+			}
+		}
+		if order.sort == sortSongCount {
+			_, okA := songCounts[a]
+			_, okB := songCounts[b]
+			if okA != okB {
+				return okA
+			}
+		}
+
+		c := compareAlbums(a, b, albums, completed, songCounts, order.sort)
+		if order.reverse {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return a < b
+	})
+}
+
+func compareAlbums(a, b int, albums []model.Album, completed map[int]bool, songCounts map[int]int, mode albumSortMode) int {
+	switch mode {
+	case sortName:
+		return strings.Compare(strings.ToLower(albums[a].Name), strings.ToLower(albums[b].Name))
+	case sortArtist:
+		return strings.Compare(strings.ToLower(albumGroupKey(albums[a], groupArtist)), strings.ToLower(albumGroupKey(albums[b], groupArtist)))
+	case sortSongCount:
+		return cmp.Compare(songCounts[a], songCounts[b])
+	case sortDownloaded:
+		// Pending albums first.
+		if completed[a] == completed[b] {
+			return 0
+		}
+		if completed[a] {
+			return 1
+		}
+		return -1
+	case sortCatalog:
+		return cmp.Compare(a, b)
+	}
+	return 0
+}
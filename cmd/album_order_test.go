@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"msr-archiver/internal/model"
+)
+
+func TestSortAlbumIndexes(t *testing.T) {
+	albums := []model.Album{
+		{CID: "a0", Name: "Operation Pyrite", Artistes: []string{"MSR"}},
+		{CID: "a1", Name: "alive", Artistes: []string{"DJ Okawari"}},
+		{CID: "a2", Name: "Operation Pyrite (Instrumental)", Artistes: []string{"MSR"}},
+		{CID: "a3", Name: "Boiling Blood"},
+	}
+	completed := map[int]bool{1: true}
+	counts := map[int]int{0: 8, 2: 3}
+
+	cases := []struct {
+		order albumOrder
+		want  []int
+	}{
+		{albumOrder{}, []int{0, 1, 2, 3}},
+		{albumOrder{sort: sortName}, []int{1, 3, 0, 2}},
+		{albumOrder{sort: sortName, reverse: true}, []int{2, 0, 3, 1}},
+		{albumOrder{sort: sortSongCount}, []int{2, 0, 1, 3}},
+		{albumOrder{sort: sortSongCount, reverse: true}, []int{0, 2, 1, 3}},
+		{albumOrder{sort: sortDownloaded}, []int{0, 2, 3, 1}},
+		{albumOrder{group: groupArtist, sort: sortName}, []int{1, 0, 2, 3}},
+		{albumOrder{group: groupSeries}, []int{1, 3, 0, 2}},
+	}
+	for _, c := range cases {
+		got := []int{0, 1, 2, 3}
+		sortAlbumIndexes(got, albums, completed, counts, c.order)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("order %+v: got %v, want %v", c.order, got, c.want)
+		}
+	}
+}
+
+func TestAlbumSeries(t *testing.T) {
+	cases := map[string]string{
+		"Operation Pyrite (Instrumental)": "Operation Pyrite",
+		"Arknights: Stories":              "Arknights",
+		"Vigilo - Remix":                  "Vigilo",
+		"Alive":                           "Alive",
+	}
+	for name, want := range cases {
+		if got := albumSeries(name); got != want {
+			t.Errorf("albumSeries(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPickerHideCompletedKeepsCursorAlbum(t *testing.T) {
+	albums := []model.Album{{CID: "a1", Name: "First"}, {CID: "a2", Name: "Second"}, {CID: "a3", Name: "Third"}}
+	m := newAlbumPickerModel(context.Background(), albums, nil, pickerOptions{}, nil)
+	m.completed[0] = true
+	m.cursor = 2
+
+	m.hideCompleted = true
+	m.rebuildFiltered()
+	if !reflect.DeepEqual(m.filtered, []int{1, 2}) {
+		t.Fatalf("filtered = %v, want [1 2]", m.filtered)
+	}
+	if idx, _ := m.currentAlbumIndex(); idx != 2 {
+		t.Fatalf("cursor moved to album %d, want 2", idx)
+	}
+}
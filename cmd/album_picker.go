@@ -2,15 +2,23 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
 	"msr-archiver/internal/api"
+	"msr-archiver/internal/catalog"
+	"msr-archiver/internal/diskspace"
+	"msr-archiver/internal/fuzzy"
 	"msr-archiver/internal/model"
+	"msr-archiver/internal/preview"
+	"msr-archiver/internal/query"
 	"msr-archiver/internal/state"
 )
 
@@ -31,6 +39,9 @@ type albumSongsLoadedMsg struct {
 	albumIdx int
 	songs    []model.Song
 	err      error
+	// prefetch marks results of background prefetching.
+	prefetch bool
+	cacheErr error
 }
 
 type albumPickerModel struct {
@@ -38,13 +49,25 @@ type albumPickerModel struct {
 	api      *api.Client
 	albums   []model.Album
 	selected map[int]struct{}
+	// songPicks holds per-album song CIDs when only some songs are chosen.
+	songPicks map[int]map[string]struct{}
 
-	completed map[int]bool
-	filtered  []int
-	cursor    int
+	completed   map[int]bool
+	isCompleted func(model.Album) bool
+	filtered    []int
+	cursor      int
 
 	filterInput textinput.Model
 	filtering   bool
+	filterErr   string
+
+	order         albumOrder
+	hideCompleted bool
+	sizes         map[string]int64
+
+	// matcher scores filter text; highlights holds matched name runes.
+	matcher    *fuzzy.Matcher
+	highlights map[int][]int
 
 	phase        pickerPhase
 	reviewCursor int
@@ -54,10 +77,34 @@ type albumPickerModel struct {
 	detailErr      string
 	detailLoading  bool
 	detailOffset   int
+	detailCursor   int
 
 	songsCache   map[int][]model.Song
 	songErrCache map[int]string
 
+	// songCache persists fetched song lists; inflight tracks running fetches
+	// and prefetching whether a background prefetch is running.
+	songCache   *catalog.Cache
+	inflight    map[int]bool
+	prefetching bool
+
+	width  int
+	height int
+
+	// player previews songs in the detail view; sourceURLs caches resolved
+	// song sources and playingCID is the song being played.
+	player     *preview.Player
+	sourceURLs map[string]string
+	playingCID string
+	playback   string
+
+	// selections autosaves the selection set and stores presets.
+	selections  *state.SelectionStore
+	savedKey    string
+	notice      string
+	presetInput textinput.Model
+	naming      bool
+
 	done    bool
 	aborted bool
 	err     error
@@ -66,39 +113,68 @@ type albumPickerModel struct {
 func chooseAlbumsInteractively(
 	ctx context.Context,
 	albums []model.Album,
-	store *state.Store,
+	store completionState,
+	opts pickerOptions,
 	apiClient *api.Client,
-) ([]model.Album, error) {
+) ([]model.Album, songPicks, error) {
 	stat, err := os.Stdin.Stat()
 	if err != nil {
-		return nil, fmt.Errorf("inspect stdin: %w", err)
+		return nil, nil, fmt.Errorf("inspect stdin: %w", err)
 	}
 	if stat.Mode()&os.ModeCharDevice == 0 {
-		return nil, fmt.Errorf("interactive selection requires a terminal; use --albums instead")
+		return nil, nil, fmt.Errorf("interactive selection requires a terminal; use --albums instead")
 	}
 
-	picker := newAlbumPickerModel(ctx, albums, store, apiClient)
+	picker := newAlbumPickerModel(ctx, albums, store, opts, apiClient)
 	finalModel, err := tea.NewProgram(picker).Run()
 	if err != nil {
-		return nil, fmt.Errorf("run interactive album selector: %w", err)
+		return nil, nil, fmt.Errorf("run interactive album selector: %w", err)
 	}
 
 	final := finalModel.(*albumPickerModel)
+	final.stopPlayback()
 	if final.aborted {
-		return nil, fmt.Errorf("interactive selection aborted")
+		return nil, nil, fmt.Errorf("interactive selection aborted")
 	}
 
 	selectedIndexes := selectedIndexesFromSet(final.selected)
-	return albumsFromIndexes(albums, selectedIndexes)
+	selected, err := albumsFromIndexes(albums, selectedIndexes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return selected, final.selectedSongPicks(), nil
 }
 
-func newAlbumPickerModel(ctx context.Context, albums []model.Album, store *state.Store, apiClient *api.Client) *albumPickerModel {
+// pickerOptions holds optional picker dependencies.
+type pickerOptions struct {
+	// selections autosaves the selection and stores presets; nil disables both.
+	selections *state.SelectionStore
+	// sizes holds on-disk sizes of archived albums keyed by CID.
+	sizes map[string]int64
+	// transliterate lets the filter match CJK titles by pinyin or romaji.
+	transliterate bool
+	// songCache provides and stores song lists; nil disables persistence.
+	songCache *catalog.Cache
+	// player previews songs; nil disables playback.
+	player *preview.Player
+}
+
+func newAlbumPickerModel(
+	ctx context.Context,
+	albums []model.Album,
+	store completionState,
+	opts pickerOptions,
+	apiClient *api.Client,
+) *albumPickerModel {
 	filter := textinput.New()
 	filter.Prompt = "/"
+	presetInput := textinput.New()
+	presetInput.Prompt = "Preset name: "
 
+	isCompleted := completedFunc(store)
 	completed := make(map[int]bool, len(albums))
 	for i, album := range albums {
-		completed[i] = store != nil && store.IsCompleted(album.Name)
+		completed[i] = isCompleted(album)
 	}
 
 	m := &albumPickerModel{
@@ -106,23 +182,90 @@ func newAlbumPickerModel(ctx context.Context, albums []model.Album, store *state
 		api:          apiClient,
 		albums:       albums,
 		selected:     make(map[int]struct{}),
+		songPicks:    make(map[int]map[string]struct{}),
 		completed:    completed,
+		isCompleted:  isCompleted,
 		filterInput:  filter,
 		phase:        pickerPhaseSelect,
 		songsCache:   make(map[int][]model.Song),
 		songErrCache: make(map[int]string),
+		selections:   opts.selections,
+		sizes:        opts.sizes,
+		matcher:      fuzzy.NewMatcher(opts.transliterate),
+		highlights:   make(map[int][]int),
+		presetInput:  presetInput,
+		songCache:    opts.songCache,
+		inflight:     make(map[int]bool),
+		player:       opts.player,
+		sourceURLs:   make(map[string]string),
 	}
+	m.loadCachedSongs()
+	m.restoreSession()
 	m.rebuildFiltered()
 	return m
 }
 
+// restoreSession reloads the autosaved selection from an earlier picker run.
+func (m *albumPickerModel) restoreSession() {
+	if m.selections == nil {
+		return
+	}
+	sel, ok := m.selections.Session()
+	if !ok || sel.Empty() {
+		return
+	}
+	m.selected, m.songPicks, _ = indexesFromSelection(m.albums, sel)
+	m.savedKey = m.selectionKey()
+	m.notice = fmt.Sprintf("Restored %d album(s) from the previous session (c clears).", len(m.selected))
+}
+
+// selectionKey identifies the current selection so autosave only writes on
+// changes.
+func (m *albumPickerModel) selectionKey() string {
+	b, _ := json.Marshal(selectionFromIndexes(m.albums, m.selected, m.songPicks))
+	return string(b)
+}
+
+// autosave persists the selection set when it changed.
+func (m *albumPickerModel) autosave() {
+	if m.selections == nil {
+		return
+	}
+	key := m.selectionKey()
+	if key == m.savedKey {
+		return
+	}
+	if err := m.selections.SaveSession(selectionFromIndexes(m.albums, m.selected, m.songPicks)); err != nil {
+		m.notice = fmt.Sprintf("Autosave failed: %v", err)
+		return
+	}
+	m.savedKey = key
+}
+
 func (m *albumPickerModel) Init() tea.Cmd {
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, m.nextPrefetch())
 }
 
 func (m *albumPickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+
+	case songSourceMsg:
+		return m, m.handleSongSource(msg)
+
+	case playbackTickMsg:
+		return m, m.handlePlaybackTick()
+
 	case albumSongsLoadedMsg:
+		delete(m.inflight, msg.albumIdx)
+		if msg.prefetch {
+			m.prefetching = false
+		}
+		if msg.cacheErr != nil {
+			m.notice = fmt.Sprintf("Song cache write failed: %v", msg.cacheErr)
+		}
 		if msg.err != nil {
 			m.songErrCache[msg.albumIdx] = msg.err.Error()
 		} else {
@@ -130,6 +273,10 @@ func (m *albumPickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			delete(m.songErrCache, msg.albumIdx)
 		}
 
+		if m.order.sort == sortSongCount {
+			m.rebuildFiltered()
+		}
+
 		if m.phase == pickerPhaseDetail && m.detailAlbumIdx == msg.albumIdx {
 			m.detailLoading = false
 			if msg.err != nil {
@@ -140,8 +287,9 @@ func (m *albumPickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.detailSongs = msg.songs
 			}
 			m.detailOffset = 0
+			m.detailCursor = 0
 		}
-		return m, nil
+		return m, m.nextPrefetch()
 
 	case tea.KeyMsg:
 		switch msg.String() {
@@ -150,14 +298,18 @@ func (m *albumPickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		}
 
+		var next tea.Model = m
+		var cmd tea.Cmd
 		switch m.phase {
 		case pickerPhaseSelect:
-			return m.updateSelect(msg)
+			next, cmd = m.updateSelect(msg)
 		case pickerPhaseReview:
-			return m.updateReview(msg)
+			next, cmd = m.updateReview(msg)
 		case pickerPhaseDetail:
-			return m.updateDetail(msg)
+			next, cmd = m.updateDetail(msg)
 		}
+		m.autosave()
+		return next, tea.Batch(cmd, m.nextPrefetch())
 	}
 
 	return m, nil
@@ -170,6 +322,9 @@ func (m *albumPickerModel) View() string {
 	case pickerPhaseDetail:
 		return m.detailView()
 	default:
+		if m.width >= splitPaneMinWidth {
+			return m.splitView()
+		}
 		return m.selectionView()
 	}
 }
@@ -230,6 +385,22 @@ func (m *albumPickerModel) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 	case "ctrl+a":
 		m.toggleSelectAllFiltered()
+	case "o":
+		m.order.sort = (m.order.sort + 1) % sortModeCount
+		m.rebuildFiltered()
+	case "O":
+		m.order.reverse = !m.order.reverse
+		m.rebuildFiltered()
+	case "v":
+		m.order.group = (m.order.group + 1) % groupModeCount
+		m.rebuildFiltered()
+	case "h":
+		m.hideCompleted = !m.hideCompleted
+		m.rebuildFiltered()
+	case "c":
+		m.selected = make(map[int]struct{})
+		m.songPicks = make(map[int]map[string]struct{})
+		m.notice = ""
 	case "d":
 		return m.showCurrentAlbumDetails()
 	case "enter":
@@ -241,7 +412,32 @@ func (m *albumPickerModel) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m *albumPickerModel) updateReview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.naming {
+		switch msg.String() {
+		case "enter":
+			m.naming = false
+			m.presetInput.Blur()
+			m.savePreset(strings.TrimSpace(m.presetInput.Value()))
+			return m, nil
+		case "esc":
+			m.naming = false
+			m.presetInput.Blur()
+			return m, nil
+		default:
+			var cmd tea.Cmd
+			m.presetInput, cmd = m.presetInput.Update(msg)
+			return m, cmd
+		}
+	}
+
 	switch msg.String() {
+	case "p":
+		if m.selections != nil && len(m.selected) > 0 {
+			m.naming = true
+			m.presetInput.SetValue("")
+			m.presetInput.Focus()
+		}
+		return m, nil
 	case "up", "k":
 		if m.reviewCursor > 0 {
 			m.reviewCursor--
@@ -276,18 +472,25 @@ func (m *albumPickerModel) updateReview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m *albumPickerModel) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "b", "esc", "q":
+		m.stopPlayback()
 		m.phase = pickerPhaseSelect
 		m.detailLoading = false
 		return m, nil
-	case "x", " ":
+	case "a":
 		if m.detailAlbumIdx >= 0 && m.detailAlbumIdx < len(m.albums) {
 			m.toggleSelection(m.detailAlbumIdx)
 		}
 		return m, nil
 	case "n":
+		m.stopPlayback()
 		return m.moveDetail(1)
 	case "p":
+		m.stopPlayback()
 		return m.moveDetail(-1)
+	case "[", "left":
+		return m, m.seekPlayback(-playbackSeekStep)
+	case "]", "right":
+		return m, m.seekPlayback(playbackSeekStep)
 	case "r":
 		if m.api != nil && m.detailAlbumIdx >= 0 && m.detailAlbumIdx < len(m.albums) {
 			return m.openAlbumDetails(m.detailAlbumIdx, true)
@@ -298,51 +501,92 @@ func (m *albumPickerModel) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
-	maxOffset := max(0, len(m.detailSongs)-detailListHeight)
+	if len(m.detailSongs) == 0 {
+		return m, nil
+	}
+	last := len(m.detailSongs) - 1
 	switch msg.String() {
 	case "up", "k":
-		if m.detailOffset > 0 {
-			m.detailOffset--
-		}
+		m.detailCursor = max(0, m.detailCursor-1)
 	case "down", "j":
-		if m.detailOffset < maxOffset {
-			m.detailOffset++
-		}
+		m.detailCursor = min(last, m.detailCursor+1)
 	case "ctrl+u", "pgup":
-		step := max(1, detailListHeight/2)
-		m.detailOffset = max(0, m.detailOffset-step)
+		m.detailCursor = max(0, m.detailCursor-max(1, detailListHeight/2))
 	case "ctrl+d", "pgdown":
-		step := max(1, detailListHeight/2)
-		m.detailOffset = min(maxOffset, m.detailOffset+step)
+		m.detailCursor = min(last, m.detailCursor+max(1, detailListHeight/2))
+	case "x", " ":
+		m.toggleSong(m.detailAlbumIdx, m.detailSongs, m.detailCursor)
+	case "l":
+		m.detailOffset, _ = listWindow(len(m.detailSongs), m.detailCursor, detailListHeight)
+		return m, m.togglePlayback()
 	}
+	m.detailOffset, _ = listWindow(len(m.detailSongs), m.detailCursor, detailListHeight)
 
 	return m, nil
 }
 
 func (m *albumPickerModel) rebuildFiltered() {
-	query := strings.ToLower(strings.TrimSpace(m.filterInput.Value()))
-	m.filtered = m.filtered[:0]
+	expr, err := query.Parse(m.filterInput.Value(), query.Options{})
+	if err != nil {
+		// Keep the last good result while the expression is being typed.
+		m.filterErr = err.Error()
+		return
+	}
+	m.filterErr = ""
+	current, hadCurrent := m.currentAlbumIndex()
 
-	for idx, album := range m.albums {
-		if query == "" {
-			m.filtered = append(m.filtered, idx)
+	hits := expr.Search(m.albums, m.isCompleted, m.fuzzyMatch)
+	m.filtered = m.filtered[:0]
+	clear(m.highlights)
+	scores := make(map[int]int, len(hits))
+	scored := false
+	for _, h := range hits {
+		if m.hideCompleted && m.completed[h.Index] {
 			continue
 		}
-		if strings.Contains(strings.ToLower(album.Name), query) || strings.Contains(strings.ToLower(album.CID), query) {
-			m.filtered = append(m.filtered, idx)
+		m.filtered = append(m.filtered, h.Index)
+		scores[h.Index] = h.Score
+		if len(h.Positions) > 0 {
+			m.highlights[h.Index] = h.Positions
+			scored = true
+		}
+	}
+	if scored && m.order == (albumOrder{}) {
+		// Best matches first while searching in the default order.
+		sort.SliceStable(m.filtered, func(i, j int) bool {
+			return scores[m.filtered[i]] > scores[m.filtered[j]]
+		})
+	} else {
+		songCounts := make(map[int]int, len(m.songsCache))
+		for idx, songs := range m.songsCache {
+			songCounts[idx] = len(songs)
 		}
+		sortAlbumIndexes(m.filtered, m.albums, m.completed, songCounts, m.order)
 	}
 
 	if len(m.filtered) == 0 {
 		m.cursor = 0
 		return
 	}
+	if hadCurrent {
+		for pos, idx := range m.filtered {
+			if idx == current {
+				m.cursor = pos
+				return
+			}
+		}
+	}
 	m.cursor = min(m.cursor, len(m.filtered)-1)
 	if m.cursor < 0 {
 		m.cursor = 0
 	}
 }
 
+func (m *albumPickerModel) fuzzyMatch(term, name string) (int, []int, bool) {
+	res, ok := m.matcher.Match(term, name)
+	return res.Score, res.Positions, ok
+}
+
 func (m *albumPickerModel) currentAlbumIndex() (int, bool) {
 	if len(m.filtered) == 0 {
 		return 0, false
@@ -353,7 +597,20 @@ func (m *albumPickerModel) currentAlbumIndex() (int, bool) {
 	return m.filtered[m.cursor], true
 }
 
+func (m *albumPickerModel) savePreset(name string) {
+	if name == "" {
+		m.notice = "Preset not saved: name is empty."
+		return
+	}
+	if err := m.selections.SavePreset(name, selectionFromIndexes(m.albums, m.selected, m.songPicks)); err != nil {
+		m.notice = fmt.Sprintf("Save preset failed: %v", err)
+		return
+	}
+	m.notice = fmt.Sprintf("Saved preset %q; reuse it with --selection-preset %s", name, name)
+}
+
 func (m *albumPickerModel) toggleSelection(idx int) {
+	delete(m.songPicks, idx)
 	if _, exists := m.selected[idx]; exists {
 		delete(m.selected, idx)
 		return
@@ -361,6 +618,67 @@ func (m *albumPickerModel) toggleSelection(idx int) {
 	m.selected[idx] = struct{}{}
 }
 
+// songSelected reports whether a song will be archived with the current
+// selection.
+func (m *albumPickerModel) songSelected(albumIdx int, songCID string) bool {
+	if _, ok := m.selected[albumIdx]; !ok {
+		return false
+	}
+	picked, ok := m.songPicks[albumIdx]
+	if !ok {
+		return true
+	}
+	_, ok = picked[songCID]
+	return ok
+}
+
+// toggleSong flips one song of an album. Picking a song of an unselected
+// album selects the album with just that song; unpicking every song
+// deselects the album, and picking every song selects it in full again.
+func (m *albumPickerModel) toggleSong(albumIdx int, songs []model.Song, pos int) {
+	if pos < 0 || pos >= len(songs) {
+		return
+	}
+	picked := make(map[string]struct{}, len(songs))
+	for _, song := range songs {
+		if m.songSelected(albumIdx, song.CID) {
+			picked[song.CID] = struct{}{}
+		}
+	}
+	cid := songs[pos].CID
+	if _, ok := picked[cid]; ok {
+		delete(picked, cid)
+	} else {
+		picked[cid] = struct{}{}
+	}
+
+	switch len(picked) {
+	case 0:
+		delete(m.selected, albumIdx)
+		delete(m.songPicks, albumIdx)
+	case len(songs):
+		m.selected[albumIdx] = struct{}{}
+		delete(m.songPicks, albumIdx)
+	default:
+		m.selected[albumIdx] = struct{}{}
+		m.songPicks[albumIdx] = picked
+	}
+}
+
+// selectedSongPicks returns song choices for selected albums keyed by CID.
+func (m *albumPickerModel) selectedSongPicks() songPicks {
+	if len(m.songPicks) == 0 {
+		return nil
+	}
+	picks := make(songPicks, len(m.songPicks))
+	for idx, picked := range m.songPicks {
+		if _, ok := m.selected[idx]; ok {
+			picks[m.albums[idx].CID] = picked
+		}
+	}
+	return picks
+}
+
 func (m *albumPickerModel) toggleSelectAllFiltered() {
 	if len(m.filtered) == 0 {
 		return
@@ -377,6 +695,7 @@ func (m *albumPickerModel) toggleSelectAllFiltered() {
 	if allSelected {
 		for _, idx := range m.filtered {
 			delete(m.selected, idx)
+			delete(m.songPicks, idx)
 		}
 		return
 	}
@@ -427,6 +746,7 @@ func (m *albumPickerModel) openAlbumDetails(idx int, forceRefetch bool) (tea.Mod
 	m.phase = pickerPhaseDetail
 	m.detailAlbumIdx = idx
 	m.detailOffset = 0
+	m.detailCursor = 0
 
 	if !forceRefetch {
 		if songs, ok := m.songsCache[idx]; ok {
@@ -453,20 +773,15 @@ func (m *albumPickerModel) openAlbumDetails(idx int, forceRefetch bool) (tea.Mod
 		return m, nil
 	}
 
-	album := m.albums[idx]
 	m.detailLoading = true
 	m.detailSongs = nil
 	m.detailErr = ""
-	return m, fetchAlbumSongsCmd(m.ctx, m.api, album.CID, idx)
-}
-
-func fetchAlbumSongsCmd(ctx context.Context, apiClient *api.Client, albumCID string, albumIdx int) tea.Cmd {
-	return func() tea.Msg {
-		songs, err := withRetryResult(ctx, 3, func() ([]model.Song, error) {
-			return apiClient.GetAlbumSongs(ctx, albumCID)
-		})
-		return albumSongsLoadedMsg{albumIdx: albumIdx, songs: songs, err: err}
+	if m.inflight[idx] && !forceRefetch {
+		// A prefetch is already loading this album.
+		return m, nil
 	}
+	m.inflight[idx] = true
+	return m, m.fetchSongsCmd(idx, false, forceRefetch)
 }
 
 func (m *albumPickerModel) selectionView() string {
@@ -476,7 +791,7 @@ func (m *albumPickerModel) selectionView() string {
 
 	if m.filtering {
 		lines = append(lines,
-			"Filter mode: type album name/CID, then Enter or Esc to apply.",
+			"Filter mode: fuzzy name/CID, re:, artist:, new, latest:N, not:, @file (comma-separated); Enter or Esc to apply.",
 			m.filterInput.View(),
 		)
 	} else if q := strings.TrimSpace(m.filterInput.Value()); q != "" {
@@ -484,6 +799,13 @@ func (m *albumPickerModel) selectionView() string {
 	} else {
 		lines = append(lines, "Filter: press / to search by album name or CID")
 	}
+	if m.filterErr != "" {
+		lines = append(lines, "Invalid filter: "+m.filterErr)
+	}
+	if m.notice != "" {
+		lines = append(lines, m.notice)
+	}
+	lines = append(lines, m.orderSummary())
 
 	lines = append(lines, "")
 	if len(m.filtered) == 0 {
@@ -492,6 +814,12 @@ func (m *albumPickerModel) selectionView() string {
 		start, end := listWindow(len(m.filtered), m.cursor, albumListHeight)
 		for pos := start; pos < end; pos++ {
 			albumIdx := m.filtered[pos]
+			if m.order.group != groupNone {
+				key := albumGroupKey(m.albums[albumIdx], m.order.group)
+				if pos == start || key != albumGroupKey(m.albums[m.filtered[pos-1]], m.order.group) {
+					lines = append(lines, fmt.Sprintf("── %s ──", key))
+				}
+			}
 			cursor := " "
 			if pos == m.cursor {
 				cursor = ">"
@@ -499,10 +827,17 @@ func (m *albumPickerModel) selectionView() string {
 			checked := " "
 			if _, ok := m.selected[albumIdx]; ok {
 				checked = "x"
+				if _, partial := m.songPicks[albumIdx]; partial {
+					checked = "~"
+				}
 			}
 
 			label := albumOptionLabel(albumIdx+1, m.albums[albumIdx], m.completed[albumIdx])
-			lines = append(lines, fmt.Sprintf("%s [%s] %s", cursor, checked, label))
+			if positions, ok := m.highlights[albumIdx]; ok {
+				name := m.albums[albumIdx].Name
+				label = strings.Replace(label, name, highlightRunes(name, positions), 1)
+			}
+			lines = append(lines, fmt.Sprintf("%s [%s] %s%s", cursor, checked, label, m.albumColumns(albumIdx)))
 		}
 		if end < len(m.filtered) {
 			lines = append(lines, fmt.Sprintf("... %d more album(s)", len(m.filtered)-end))
@@ -513,12 +848,71 @@ func (m *albumPickerModel) selectionView() string {
 	if m.filtering {
 		lines = append(lines, "Keys: type filter | Enter/Esc apply | Ctrl+C exit")
 	} else {
-		lines = append(lines, "Keys: j/k move | x toggle | Ctrl+A select all | / filter | d details | Enter review | Ctrl+C exit")
+		lines = append(lines, "Keys: j/k move | x toggle | Ctrl+A select all | c clear | / filter | o/O sort/reverse | v group | h hide done | d details | Enter review | Ctrl+C exit")
 	}
 
 	return strings.Join(lines, "\n")
 }
 
+var matchStyle = lipgloss.NewStyle().Bold(true).Underline(true)
+
+// highlightRunes renders the runes of s at positions with matchStyle.
+func highlightRunes(s string, positions []int) string {
+	if len(positions) == 0 {
+		return s
+	}
+	marked := make(map[int]struct{}, len(positions))
+	for _, p := range positions {
+		marked[p] = struct{}{}
+	}
+
+	var b, run strings.Builder
+	flush := func() {
+		if run.Len() > 0 {
+			b.WriteString(matchStyle.Render(run.String()))
+			run.Reset()
+		}
+	}
+	for i, r := range []rune(s) {
+		if _, ok := marked[i]; ok {
+			run.WriteRune(r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+func (m *albumPickerModel) orderSummary() string {
+	direction := "asc"
+	if m.order.reverse {
+		direction = "desc"
+	}
+	hidden := "shown"
+	if m.hideCompleted {
+		hidden = "hidden"
+	}
+	return fmt.Sprintf("Sort: %s (%s) | Group: %s | Downloaded: %s", m.order.sort, direction, m.order.group, hidden)
+}
+
+// albumColumns renders the track count of albums whose songs are loaded and
+// the on-disk size of archived albums.
+func (m *albumPickerModel) albumColumns(idx int) string {
+	var cols []string
+	if songs, ok := m.songsCache[idx]; ok {
+		cols = append(cols, fmt.Sprintf("%d tracks", len(songs)))
+	}
+	if size, ok := m.sizes[m.albums[idx].CID]; ok {
+		cols = append(cols, diskspace.FormatSize(size))
+	}
+	if len(cols) == 0 {
+		return ""
+	}
+	return " | " + strings.Join(cols, " | ")
+}
+
 func (m *albumPickerModel) reviewView() string {
 	selectedIndexes := selectedIndexesFromSet(m.selected)
 	preview := buildSelectedAlbumsPreview(m.albums, selectedIndexes, 8)
@@ -532,8 +926,11 @@ func (m *albumPickerModel) reviewView() string {
 	lines := []string{
 		"Review selected albums",
 		preview,
-		"",
 	}
+	if picks := m.selectedSongPicks(); len(picks) > 0 {
+		lines = append(lines, fmt.Sprintf("%d album(s) with a song subset; they stay pending after download.", len(picks)))
+	}
+	lines = append(lines, "")
 	for i, option := range options {
 		cursor := " "
 		if i == m.reviewCursor {
@@ -541,7 +938,18 @@ func (m *albumPickerModel) reviewView() string {
 		}
 		lines = append(lines, fmt.Sprintf("%s %s", cursor, option))
 	}
-	lines = append(lines, "", "Keys: j/k move | Enter choose | b back | d details")
+	if m.notice != "" {
+		lines = append(lines, "", m.notice)
+	}
+	if m.naming {
+		lines = append(lines, "", m.presetInput.View(), "Keys: Enter save | Esc cancel")
+		return strings.Join(lines, "\n")
+	}
+	keys := "Keys: j/k move | Enter choose | b back | d details"
+	if m.selections != nil {
+		keys += " | p save preset"
+	}
+	lines = append(lines, "", keys)
 
 	return strings.Join(lines, "\n")
 }
@@ -568,32 +976,43 @@ func (m *albumPickerModel) detailView() string {
 
 	if m.detailLoading {
 		lines = append(lines, "Loading songs from API...")
-		lines = append(lines, "", "Keys: n/p next/prev album | x toggle | Esc/B back")
+		lines = append(lines, "", "Keys: n/p next/prev album | a toggle album | Esc/B back")
 		return strings.Join(lines, "\n")
 	}
 
 	if m.detailErr != "" {
 		lines = append(lines, fmt.Sprintf("Failed to load songs: %s", m.detailErr))
-		lines = append(lines, "", "Keys: r retry | n/p next/prev album | x toggle | Esc/B back")
+		lines = append(lines, "", "Keys: r retry | n/p next/prev album | a toggle album | Esc/B back")
 		return strings.Join(lines, "\n")
 	}
 
 	lines = append(lines, fmt.Sprintf("Songs (%d):", len(m.detailSongs)))
 	if len(m.detailSongs) == 0 {
 		lines = append(lines, "No songs found for this album.")
-		lines = append(lines, "", "Keys: n/p next/prev album | x toggle | Esc/B back")
+		lines = append(lines, "", "Keys: n/p next/prev album | a toggle album | Esc/B back")
 		return strings.Join(lines, "\n")
 	}
 
 	end := min(len(m.detailSongs), m.detailOffset+detailListHeight)
 	for i := m.detailOffset; i < end; i++ {
-		lines = append(lines, fmt.Sprintf("%3d. %s", i+1, m.detailSongs[i].Name))
+		cursor := " "
+		if i == m.detailCursor {
+			cursor = ">"
+		}
+		checked := " "
+		if m.songSelected(m.detailAlbumIdx, m.detailSongs[i].CID) {
+			checked = "x"
+		}
+		lines = append(lines, fmt.Sprintf("%s [%s] %3d. %s", cursor, checked, i+1, m.detailSongs[i].Name))
 	}
 	if end < len(m.detailSongs) {
 		lines = append(lines, fmt.Sprintf("... %d more song(s)", len(m.detailSongs)-end))
 	}
+	if line := m.playbackLine(); line != "" {
+		lines = append(lines, "", line)
+	}
 
-	lines = append(lines, "", "Keys: j/k scroll | r refetch | n/p next/prev album | x toggle | Esc/B back")
+	lines = append(lines, "", "Keys: j/k move | x toggle song | a toggle album | l play/stop | [/] seek | r refetch | n/p next/prev album | Esc/B back")
 	return strings.Join(lines, "\n")
 }
 
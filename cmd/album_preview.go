@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"msr-archiver/internal/api"
+	"msr-archiver/internal/model"
+)
+
+const (
+	// splitPaneMinWidth is the terminal width at which the song preview is
+	// shown next to the album list.
+	splitPaneMinWidth = 100
+	// prefetchInterval spaces background song requests so browsing does not
+	// flood the API.
+	prefetchInterval = 300 * time.Millisecond
+	// prefetchRadius is how many rows around the cursor are prefetched.
+	prefetchRadius = 3
+)
+
+var previewPaneStyle = lipgloss.NewStyle().
+	Border(lipgloss.NormalBorder(), false, false, false, true).
+	PaddingLeft(1)
+
+// loadCachedSongs seeds the song cache from song lists persisted by earlier
+// runs. A missing or unreadable cache only means songs are fetched again.
+func (m *albumPickerModel) loadCachedSongs() {
+	if m.songCache == nil {
+		return
+	}
+	cached, err := m.songCache.LoadSongs()
+	if err != nil || len(cached) == 0 {
+		return
+	}
+	for idx, album := range m.albums {
+		if songs, ok := cached[album.CID]; ok {
+			m.songsCache[idx] = songs
+		}
+	}
+}
+
+// fetchSongsCmd loads the songs of albums[idx] and persists them in the
+// catalog cache. Prefetches wait prefetchInterval first. A refetch
+// revalidates the API response cache instead of reusing a fresh entry.
+func (m *albumPickerModel) fetchSongsCmd(idx int, prefetch, refetch bool) tea.Cmd {
+	ctx, apiClient, cache := m.ctx, m.api, m.songCache
+	albumCID := m.albums[idx].CID
+	return func() tea.Msg {
+		if prefetch {
+			timer := time.NewTimer(prefetchInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return albumSongsLoadedMsg{albumIdx: idx, err: ctx.Err(), prefetch: true}
+			case <-timer.C:
+			}
+		}
+
+		reqCtx := ctx
+		if refetch {
+			reqCtx = api.Revalidate(ctx)
+		}
+		songs, err := withRetryResult(ctx, 3, func() ([]model.Song, error) {
+			return apiClient.GetAlbumSongs(reqCtx, albumCID)
+		})
+		msg := albumSongsLoadedMsg{albumIdx: idx, songs: songs, err: err, prefetch: prefetch}
+		if err == nil && cache != nil {
+			msg.cacheErr = cache.PutSongs(albumCID, songs)
+		}
+		return msg
+	}
+}
+
+// nextPrefetch starts fetching the songs of the nearest album around the
+// cursor that is not loaded yet. Only one prefetch runs at a time.
+func (m *albumPickerModel) nextPrefetch() tea.Cmd {
+	if m.api == nil || m.prefetching {
+		return nil
+	}
+	idx, ok := m.prefetchCandidate()
+	if !ok {
+		return nil
+	}
+	m.prefetching = true
+	m.inflight[idx] = true
+	return m.fetchSongsCmd(idx, true, false)
+}
+
+// prefetchCandidate returns the closest filtered album to the cursor, within
+// prefetchRadius, whose songs are neither loaded, failed nor being fetched.
+func (m *albumPickerModel) prefetchCandidate() (int, bool) {
+	if len(m.filtered) == 0 {
+		return 0, false
+	}
+	for dist := 0; dist <= prefetchRadius; dist++ {
+		for _, pos := range []int{m.cursor + dist, m.cursor - dist} {
+			if pos < 0 || pos >= len(m.filtered) {
+				continue
+			}
+			idx := m.filtered[pos]
+			if _, ok := m.songsCache[idx]; ok {
+				continue
+			}
+			if _, ok := m.songErrCache[idx]; ok {
+				continue
+			}
+			if m.inflight[idx] {
+				continue
+			}
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// splitView shows the album list with a song preview of the album under the
+// cursor on the right.
+func (m *albumPickerModel) splitView() string {
+	leftWidth := m.width * 55 / 100
+	rightWidth := m.width - leftWidth - previewPaneStyle.GetHorizontalFrameSize()
+
+	left := lipgloss.NewStyle().Width(leftWidth).MaxWidth(leftWidth).Render(m.selectionView())
+	right := previewPaneStyle.Width(rightWidth).MaxWidth(rightWidth + previewPaneStyle.GetHorizontalFrameSize()).Render(m.previewView())
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, right)
+}
+
+// previewView renders the song list of the album under the cursor.
+func (m *albumPickerModel) previewView() string {
+	idx, ok := m.currentAlbumIndex()
+	if !ok {
+		return "No album under the cursor."
+	}
+	album := m.albums[idx]
+	lines := []string{album.Name}
+	if len(album.Artistes) > 0 {
+		lines = append(lines, strings.Join(album.Artistes, ", "))
+	}
+	lines = append(lines, "")
+
+	songs, loaded := m.songsCache[idx]
+	switch {
+	case loaded:
+		lines = append(lines, fmt.Sprintf("Songs (%d):", len(songs)))
+		if len(songs) == 0 {
+			lines = append(lines, "No songs found for this album.")
+		}
+		height := albumListHeight
+		if m.height > 0 {
+			height = max(1, m.height-6)
+		}
+		end := min(len(songs), height)
+		for i := 0; i < end; i++ {
+			checked := " "
+			if m.songSelected(idx, songs[i].CID) {
+				checked = "x"
+			}
+			lines = append(lines, fmt.Sprintf("[%s] %3d. %s", checked, i+1, songs[i].Name))
+		}
+		if end < len(songs) {
+			lines = append(lines, fmt.Sprintf("... %d more song(s)", len(songs)-end))
+		}
+	case m.songErrCache[idx] != "":
+		lines = append(lines, "Failed to load songs: "+m.songErrCache[idx], "Press d and r to retry.")
+	case m.inflight[idx]:
+		lines = append(lines, "Loading songs...")
+	default:
+		lines = append(lines, "Songs not loaded yet.")
+	}
+	return strings.Join(lines, "\n")
+}
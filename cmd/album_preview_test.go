@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"msr-archiver/internal/api"
+	"msr-archiver/internal/catalog"
+	"msr-archiver/internal/httpcache"
+	"msr-archiver/internal/model"
+)
+
+func TestPickerLoadsCachedSongs(t *testing.T) {
+	albums := []model.Album{{CID: "a1", Name: "First"}, {CID: "a2", Name: "Second"}}
+	cache := catalog.NewCache(filepath.Join(t.TempDir(), "albums_cache.json"))
+	if err := cache.Save(albums); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := cache.PutSongs("a2", []model.Song{{CID: "s1", Name: "Song One"}}); err != nil {
+		t.Fatalf("PutSongs failed: %v", err)
+	}
+
+	m := newAlbumPickerModel(context.Background(), albums, nil, pickerOptions{songCache: cache}, nil)
+	if _, ok := m.songsCache[0]; ok {
+		t.Fatalf("album 0 should not have cached songs")
+	}
+	if songs := m.songsCache[1]; len(songs) != 1 || songs[0].CID != "s1" {
+		t.Fatalf("songsCache[1] = %+v, want one cached song", songs)
+	}
+
+	m.cursor = 1
+	if view := m.previewView(); !strings.Contains(view, "Song One") {
+		t.Fatalf("preview does not list cached song:\n%s", view)
+	}
+}
+
+func TestPrefetchCandidateNearestUnloaded(t *testing.T) {
+	albums := make([]model.Album, 10)
+	for i := range albums {
+		albums[i] = model.Album{CID: string(rune('a' + i)), Name: string(rune('A' + i))}
+	}
+	m := newAlbumPickerModel(context.Background(), albums, nil, pickerOptions{}, nil)
+	m.cursor = 5
+
+	m.songsCache[5] = nil
+	m.inflight[6] = true
+	m.songErrCache[4] = "boom"
+	if idx, ok := m.prefetchCandidate(); !ok || idx != 7 {
+		t.Fatalf("prefetchCandidate = %d, %v; want 7", idx, ok)
+	}
+
+	for i := range albums {
+		m.songsCache[i] = nil
+	}
+	if _, ok := m.prefetchCandidate(); ok {
+		t.Fatalf("expected no candidate when every album is loaded")
+	}
+	if cmd := m.nextPrefetch(); cmd != nil {
+		t.Fatalf("nextPrefetch without an API client should be nil")
+	}
+}
+
+func TestPickerRefetchRevalidatesCachedSongs(t *testing.T) {
+	var requests, conditional int
+	client := api.NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		if req.Header.Get("If-None-Match") != "" {
+			conditional++
+		}
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(`{"data":{"songs":[{"cid":"s1","name":"Song One"}]}}`)),
+		}
+		resp.Header.Set("ETag", `"v1"`)
+		return resp, nil
+	})}, api.Options{Cache: httpcache.New(t.TempDir()), AlbumDetailTTL: time.Hour})
+
+	m := newAlbumPickerModel(context.Background(), []model.Album{{CID: "a1", Name: "First"}}, nil, pickerOptions{}, client)
+	for _, refetch := range []bool{false, false, true} {
+		msg, ok := m.fetchSongsCmd(0, false, refetch)().(albumSongsLoadedMsg)
+		if !ok || msg.err != nil || len(msg.songs) != 1 {
+			t.Fatalf("fetch (refetch=%v) = %+v", refetch, msg)
+		}
+	}
+	if requests != 2 || conditional != 1 {
+		t.Fatalf("requests = %d, conditional = %d; want the cached response reused once and revalidated on refetch", requests, conditional)
+	}
+}
@@ -104,7 +104,7 @@ func TestSelectAlbumsByQuery(t *testing.T) {
 		{CID: "a3", Name: "Third Night"},
 	}
 
-	got, err := selectAlbumsByQuery(albums, "a2,third")
+	got, err := selectAlbumsByQuery(albums, "a2,third", nil)
 	if err != nil {
 		t.Fatalf("selectAlbumsByQuery failed: %v", err)
 	}
@@ -122,7 +122,7 @@ func TestSelectAlbumsByQueryAmbiguous(t *testing.T) {
 		{CID: "a2", Name: "Alpha Remix"},
 	}
 
-	_, err := selectAlbumsByQuery(albums, "alp")
+	_, err := selectAlbumsByQuery(albums, "alp", nil)
 	if err == nil {
 		t.Fatalf("expected ambiguous query error")
 	}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"msr-archiver/internal/catalog"
+	"msr-archiver/internal/config"
+)
+
+// runCatalogCommand handles `catalog <subcommand>` and returns an exit code.
+func runCatalogCommand(args []string) int {
+	if len(args) == 0 || args[0] != "diff" {
+		fmt.Fprintln(os.Stderr, "usage: msr-archiver catalog diff [--since DATE|DURATION] [flags]")
+		return 2
+	}
+
+	var sinceRaw string
+	cfg, _, _, err := config.ParseCommand("catalog diff", args[1:], os.Getenv, func(fs *flag.FlagSet) {
+		fs.StringVar(&sinceRaw, "since", "", "show changes since a date (2006-01-02), RFC3339 time or duration ago (e.g. 168h)")
+	})
+	if err != nil {
+		return flagErrorCode(err)
+	}
+
+	since, err := parseSince(sinceRaw, time.Now())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	entries, err := catalog.NewCache(resolveAlbumCachePath(cfg)).Changes(since)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	printChangeEntries(os.Stdout, entries)
+	return 0
+}
+
+// parseSince accepts a date, an RFC3339 timestamp or a duration before now.
+// An empty value means the beginning of the changelog.
+func parseSince(raw string, now time.Time) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", raw, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(raw); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q: want 2006-01-02, RFC3339 or a duration", raw)
+}
+
+func printChangeEntries(w io.Writer, entries []catalog.ChangeEntry) {
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No catalog changes recorded.")
+		return
+	}
+	for _, e := range entries {
+		detail := fmt.Sprintf("%s (%s)", e.Name, e.CID)
+		if e.Old != "" || e.New != "" {
+			detail = fmt.Sprintf("%s: %q -> %q", detail, e.Old, e.New)
+		}
+		fmt.Fprintf(w, "%s  %-15s %s\n", e.At.Local().Format("2006-01-02 15:04"), e.Kind, detail)
+	}
+}
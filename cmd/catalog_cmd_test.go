@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	got, err := parseSince("168h", now)
+	if err != nil || !got.Equal(now.Add(-168*time.Hour)) {
+		t.Fatalf("duration since: got %v, %v", got, err)
+	}
+	got, err = parseSince("2026-03-01T00:00:00Z", now)
+	if err != nil || !got.Equal(time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("rfc3339 since: got %v, %v", got, err)
+	}
+	got, err = parseSince("2026-03-01", now)
+	if err != nil || got.Day() != 1 || got.Month() != time.March {
+		t.Fatalf("date since: got %v, %v", got, err)
+	}
+	got, err = parseSince("", now)
+	if err != nil || !got.IsZero() {
+		t.Fatalf("empty since: got %v, %v", got, err)
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Fatalf("expected invalid since error")
+	}
+}
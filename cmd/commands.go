@@ -0,0 +1,525 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"msr-archiver/internal/api"
+	"msr-archiver/internal/audio"
+	"msr-archiver/internal/catalog"
+	"msr-archiver/internal/config"
+	"msr-archiver/internal/logging"
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/query"
+	"msr-archiver/internal/state"
+	"msr-archiver/internal/validate"
+)
+
+// command is a CLI subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+func commands() []command {
+	return []command{
+		{name: "download", summary: "archive albums (default command)", run: runDownload},
+		{name: "list", summary: "list the album catalog, optionally filtered by a query", run: runListCommand},
+		{name: "info", summary: "show an album and its songs", run: runInfoCommand},
+		{name: "verify", summary: "check archived tracks against the library", run: runVerifyCommand},
+		{name: "status", summary: "show completed and pending albums", run: runStatusCommand},
+		{name: "catalog", summary: "show recorded catalog changes", run: runCatalogCommand},
+		{name: "db", summary: "query the library database read-only, or import JSON state", run: runDBCommand},
+		{name: "config", summary: "print the effective configuration", run: runConfigCommand},
+		{name: "completion", summary: "print a shell completion script", run: runCompletionCommand},
+		{name: "help", summary: "show this help", run: runHelpCommand},
+	}
+}
+
+// runCLI dispatches to a subcommand. Arguments that do not start with a known
+// command name run the default download command, so existing flag-only
+// invocations keep working.
+func runCLI(args []string) int {
+	if len(args) > 0 {
+		for _, c := range commands() {
+			if args[0] == c.name {
+				return c.run(args[1:])
+			}
+		}
+		if !strings.HasPrefix(args[0], "-") {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+			printUsage(os.Stderr)
+			return 2
+		}
+	}
+	return runDownload(args)
+}
+
+func runHelpCommand([]string) int {
+	printUsage(os.Stdout)
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: msr-archiver [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-11s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run `msr-archiver <command> --help` for command flags.")
+}
+
+// flagErrorCode maps a flag parsing error to an exit code, printing it unless
+// it was a --help request.
+func flagErrorCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, err)
+	return 2
+}
+
+// readOnlyEnv holds what the catalog inspection commands need. It never
+// creates the output directory or requires ffmpeg, and catalogs it fetches
+// are not persisted.
+type readOnlyEnv struct {
+	cfg       config.Config
+	logger    *logging.Logger
+	apiClient *api.Client
+	cache     *catalog.Cache
+}
+
+// inspectTransport replaces the network for the inspection commands in
+// tests.
+var inspectTransport http.RoundTripper
+
+func newReadOnlyEnv(cfg config.Config) (readOnlyEnv, error) {
+	apiHTTP, _, err := newHTTPClients(cfg)
+	if err != nil {
+		return readOnlyEnv{}, err
+	}
+	if inspectTransport != nil {
+		apiHTTP.Transport = inspectTransport
+	}
+	apiCfg := cfg
+	if _, err := os.Stat(resolveAPICacheDir(cfg)); err != nil {
+		// Only reuse a response cache an earlier run created.
+		apiCfg.APICache = false
+	}
+	return readOnlyEnv{
+		cfg:       cfg,
+		logger:    logging.NewWithWriter(os.Stderr),
+		apiClient: newAPIClient(apiCfg, apiHTTP),
+		cache:     catalog.NewCache(resolveAlbumCachePath(cfg)),
+	}, nil
+}
+
+func runListCommand(args []string) int {
+	var search, artist, status string
+	var asJSON bool
+	cfg, _, rest, err := config.ParseCommand("list", args, os.Getenv, func(fs *flag.FlagSet) {
+		fs.StringVar(&search, "search", "", "only albums whose name or CID contains TEXT")
+		fs.StringVar(&artist, "artist", "", "only albums credited to an artist containing NAME")
+		fs.StringVar(&status, "status", "all", "filter by archive status: all, completed or pending")
+		fs.BoolVar(&asJSON, "json", false, "print albums as JSON")
+	})
+	if err != nil {
+		return flagErrorCode(err)
+	}
+	expr, err := query.Parse(strings.Join(rest, " "), query.Options{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	switch status {
+	case "all", "completed", "pending":
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --status %q: want all, completed or pending\n", status)
+		return 2
+	}
+
+	env, err := newReadOnlyEnv(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	archive, err := openReadOnlyState(context.Background(), cfg)
+	if err != nil {
+		env.logger.Errorf("%v", err)
+		return 1
+	}
+	defer archive.Close()
+	store := archive.completion
+	albums, err := loadAlbumsReadOnly(context.Background(), cfg, env.logger, env.apiClient, env.cache)
+	if err != nil {
+		env.logger.Errorf("%v", err)
+		return 1
+	}
+
+	type listed struct {
+		Order int `json:"order"`
+		model.Album
+		Completed bool `json:"completed"`
+	}
+	matched := make(map[int]struct{})
+	for _, idx := range expr.Filter(albums, completedFunc(store)) {
+		matched[idx] = struct{}{}
+	}
+	var out []listed
+	for _, a := range filterAlbums(albums, search, artist) {
+		if _, ok := matched[a.order-1]; !ok {
+			continue
+		}
+		completed := store.IsCompleted(a.album.Name)
+		if (status == "completed" && !completed) || (status == "pending" && completed) {
+			continue
+		}
+		out = append(out, listed{Order: a.order, Album: a.album, Completed: completed})
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			env.logger.Errorf("encode albums: %v", err)
+			return 1
+		}
+		return 0
+	}
+	for _, a := range out {
+		fmt.Println(albumOptionLabel(a.Order, a.Album, a.Completed))
+	}
+	fmt.Fprintf(os.Stderr, "%d/%d albums\n", len(out), len(albums))
+	return 0
+}
+
+type orderedAlbum struct {
+	order int
+	album model.Album
+}
+
+// filterAlbums applies case-insensitive substring filters and keeps 1-based
+// catalog order.
+func filterAlbums(albums []model.Album, search, artist string) []orderedAlbum {
+	search = strings.ToLower(strings.TrimSpace(search))
+	artist = strings.ToLower(strings.TrimSpace(artist))
+
+	var out []orderedAlbum
+	for i, a := range albums {
+		if search != "" && !strings.Contains(strings.ToLower(a.Name), search) && !strings.Contains(strings.ToLower(a.CID), search) {
+			continue
+		}
+		if artist != "" {
+			found := false
+			for _, name := range a.Artistes {
+				if strings.Contains(strings.ToLower(name), artist) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		out = append(out, orderedAlbum{order: i + 1, album: a})
+	}
+	return out
+}
+
+func runInfoCommand(args []string) int {
+	cfg, _, rest, err := config.ParseCommand("info", args, os.Getenv, nil)
+	if err != nil {
+		return flagErrorCode(err)
+	}
+	q := strings.TrimSpace(strings.Join(rest, " "))
+	if q == "" {
+		fmt.Fprintln(os.Stderr, "usage: msr-archiver info [flags] <album name or CID>")
+		return 2
+	}
+
+	ctx := context.Background()
+	env, err := newReadOnlyEnv(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	archive, err := openReadOnlyState(context.Background(), cfg)
+	if err != nil {
+		env.logger.Errorf("%v", err)
+		return 1
+	}
+	defer archive.Close()
+	store := archive.completion
+	albums, err := loadAlbumsReadOnly(ctx, cfg, env.logger, env.apiClient, env.cache)
+	if err != nil {
+		env.logger.Errorf("%v", err)
+		return 1
+	}
+	album, err := query.Resolve(albums, q)
+	if err != nil {
+		env.logger.Errorf("%v", err)
+		return 1
+	}
+
+	songs, err := withRetryResult(ctx, 3, func() ([]model.Song, error) {
+		return env.apiClient.GetAlbumSongs(ctx, album.CID)
+	})
+	if err != nil {
+		env.logger.Errorf("fetch album songs: %v", err)
+		return 1
+	}
+
+	printAlbumInfo(os.Stdout, album, songs, store.IsCompleted(album.Name))
+	return 0
+}
+
+func printAlbumInfo(w io.Writer, album model.Album, songs []model.Song, completed bool) {
+	status := "not downloaded"
+	if completed {
+		status = "downloaded"
+	}
+	fmt.Fprintf(w, "%s\n", album.Name)
+	fmt.Fprintf(w, "CID: %s | Status: %s\n", album.CID, status)
+	if len(album.Artistes) > 0 {
+		fmt.Fprintf(w, "Artists: %s\n", strings.Join(album.Artistes, ", "))
+	}
+	if album.CoverURL != "" {
+		fmt.Fprintf(w, "Cover: %s\n", album.CoverURL)
+	}
+	fmt.Fprintf(w, "\nSongs (%d):\n", len(songs))
+	for i, s := range songs {
+		line := fmt.Sprintf("%3d. %s (%s)", i+1, s.Name, s.CID)
+		if len(s.Artistes) > 0 {
+			line += " - " + strings.Join(s.Artistes, ", ")
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func runStatusCommand(args []string) int {
+	var showPending bool
+	cfg, _, rest, err := config.ParseCommand("status", args, os.Getenv, func(fs *flag.FlagSet) {
+		fs.BoolVar(&showPending, "pending", false, "list every pending album")
+	})
+	if err != nil {
+		return flagErrorCode(err)
+	}
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %s\n", strings.Join(rest, " "))
+		return 2
+	}
+
+	env, err := newReadOnlyEnv(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	archive, err := openReadOnlyState(context.Background(), cfg)
+	if err != nil {
+		env.logger.Errorf("%v", err)
+		return 1
+	}
+	defer archive.Close()
+	store := archive.completion
+	albums, err := loadAlbumsReadOnly(context.Background(), cfg, env.logger, env.apiClient, env.cache)
+	if err != nil {
+		env.logger.Errorf("%v", err)
+		return 1
+	}
+
+	var pending []orderedAlbum
+	for i, a := range albums {
+		if !store.IsCompleted(a.Name) {
+			pending = append(pending, orderedAlbum{order: i + 1, album: a})
+		}
+	}
+	completed := len(albums) - len(pending)
+
+	fmt.Printf("Output:    %s\n", cfg.OutputDir)
+	fmt.Printf("Completed: %d/%d albums\n", completed, len(albums))
+	fmt.Printf("Pending:   %d albums\n", len(pending))
+
+	limit := 10
+	if showPending {
+		limit = len(pending)
+	}
+	for i, a := range pending {
+		if i >= limit {
+			fmt.Printf("... and %d more (use --pending to list all)\n", len(pending)-limit)
+			break
+		}
+		fmt.Println(albumOptionLabel(a.order, a.album, false))
+	}
+	return 0
+}
+
+func runVerifyCommand(args []string) int {
+	var quick bool
+	cfg, _, rest, err := config.ParseCommand("verify", args, os.Getenv, func(fs *flag.FlagSet) {
+		fs.BoolVar(&quick, "quick", false, "only check that files exist and have valid headers; skip decoding")
+	})
+	if err != nil {
+		return flagErrorCode(err)
+	}
+	logger := logging.NewWithWriter(os.Stderr)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !quick {
+		if err := audio.CheckFFmpeg(ctx); err != nil {
+			logger.Errorf("%v", err)
+			return 1
+		}
+	}
+	archive, err := openReadOnlyState(ctx, cfg)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return 1
+	}
+	defer archive.Close()
+	libraryStore := archive.library
+
+	albums := libraryStore.Albums()
+	if q := strings.TrimSpace(strings.Join(rest, " ")); q != "" {
+		albums = filterLibraryAlbums(albums, q)
+		if len(albums) == 0 {
+			logger.Errorf("no archived album matches %q", q)
+			return 1
+		}
+	}
+
+	checked, failed := 0, 0
+	for _, a := range albums {
+		for _, t := range a.Tracks {
+			if ctx.Err() != nil {
+				logger.Errorf("verify interrupted: %v", ctx.Err())
+				return 1
+			}
+			checked++
+			// Track paths are relative to the output directory and include the
+			// album directory.
+			path := filepath.Join(cfg.OutputDir, filepath.FromSlash(t.Path))
+			if err := verifyTrack(ctx, path, t.FileType, quick); err != nil {
+				failed++
+				fmt.Printf("FAIL %s: %v\n", path, err)
+			}
+		}
+	}
+	fmt.Printf("Verified %d tracks in %d albums, %d failed\n", checked, len(albums), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// filterLibraryAlbums matches an exact CID, otherwise a case-insensitive
+// substring of the album name.
+func filterLibraryAlbums(albums []state.LibraryAlbum, q string) []state.LibraryAlbum {
+	for _, a := range albums {
+		if a.CID == q {
+			return []state.LibraryAlbum{a}
+		}
+	}
+	needle := strings.ToLower(q)
+	var out []state.LibraryAlbum
+	for _, a := range albums {
+		if strings.Contains(strings.ToLower(a.Name), needle) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func verifyTrack(ctx context.Context, path, fileType string, quick bool) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	if !quick {
+		return validate.Decode(ctx, path, fileType)
+	}
+	switch fileType {
+	case ".flac":
+		_, err := validate.FLACStreamInfo(path)
+		return err
+	case ".mp3":
+		return validate.MP3(path)
+	case ".wav":
+		return validate.WAV(path)
+	}
+	return nil
+}
+
+func runCompletionCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: msr-archiver completion bash|zsh|fish")
+		return 2
+	}
+	script, err := completionScript(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Print(script)
+	return 0
+}
+
+func completionScript(shell string) (string, error) {
+	var names []string
+	for _, c := range commands() {
+		names = append(names, c.name)
+	}
+	flags := config.FlagNames()
+	sort.Strings(flags)
+	dashed := make([]string, 0, len(flags))
+	for _, f := range flags {
+		dashed = append(dashed, "--"+f)
+	}
+
+	switch shell {
+	case "bash":
+		return fmt.Sprintf(`_msr_archiver() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    if [[ $COMP_CWORD -eq 1 && "$cur" != -* ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+    COMPREPLY=($(compgen -W "%s" -- "$cur"))
+}
+complete -F _msr_archiver msr-archiver
+`, strings.Join(names, " "), strings.Join(dashed, " ")), nil
+	case "zsh":
+		return fmt.Sprintf(`#compdef msr-archiver
+_msr_archiver() {
+    if (( CURRENT == 2 )) && [[ $words[CURRENT] != -* ]]; then
+        compadd -- %s
+        return
+    fi
+    compadd -- %s
+}
+compdef _msr_archiver msr-archiver
+`, strings.Join(names, " "), strings.Join(dashed, " ")), nil
+	case "fish":
+		var b strings.Builder
+		fmt.Fprintf(&b, "complete -c msr-archiver -f -n '__fish_use_subcommand' -a '%s'\n", strings.Join(names, " "))
+		for _, f := range flags {
+			fmt.Fprintf(&b, "complete -c msr-archiver -l %s\n", f)
+		}
+		return b.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported shell %q: want bash, zsh or fish", shell)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"msr-archiver/internal/librarydb"
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+func TestFilterAlbums(t *testing.T) {
+	albums := []model.Album{
+		{CID: "1001", Name: "Operation Originium", Artistes: []string{"塞壬唱片-MSR"}},
+		{CID: "1002", Name: "Speed of Light", Artistes: []string{"DJ Okawari"}},
+		{CID: "1003", Name: "Miracle Originium", Artistes: []string{"DJ Okawari", "MSR"}},
+	}
+
+	got := filterAlbums(albums, "originium", "")
+	if len(got) != 2 || got[0].order != 1 || got[1].order != 3 {
+		t.Fatalf("search filter: got %+v", got)
+	}
+	got = filterAlbums(albums, "", "okawari")
+	if len(got) != 2 || got[0].album.CID != "1002" || got[1].album.CID != "1003" {
+		t.Fatalf("artist filter: got %+v", got)
+	}
+	got = filterAlbums(albums, "originium", "okawari")
+	if len(got) != 1 || got[0].album.CID != "1003" {
+		t.Fatalf("combined filter: got %+v", got)
+	}
+	if got := filterAlbums(albums, "1002", ""); len(got) != 1 || got[0].order != 2 {
+		t.Fatalf("cid filter: got %+v", got)
+	}
+}
+
+func TestFilterLibraryAlbums(t *testing.T) {
+	albums := []state.LibraryAlbum{{CID: "1001", Name: "First Light"}, {CID: "1002", Name: "Light 1001"}}
+
+	if got := filterLibraryAlbums(albums, "1001"); len(got) != 1 || got[0].CID != "1001" {
+		t.Fatalf("cid match should win: got %+v", got)
+	}
+	if got := filterLibraryAlbums(albums, "light"); len(got) != 2 {
+		t.Fatalf("name match: got %+v", got)
+	}
+}
+
+func TestCompletionScript(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		script, err := completionScript(shell)
+		if err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		if !strings.Contains(script, "list") || !strings.Contains(script, "output") {
+			t.Fatalf("%s script missing commands or flags:\n%s", shell, script)
+		}
+	}
+	if _, err := completionScript("powershell"); err == nil {
+		t.Fatalf("expected unsupported shell error")
+	}
+}
+
+func TestRunCLIUnknownCommand(t *testing.T) {
+	if code := runCLI([]string{"bogus"}); code != 2 {
+		t.Fatalf("unknown command exit code = %d, want 2", code)
+	}
+}
+
+func TestRunVerifyCommandResolvesLibraryPaths(t *testing.T) {
+	out := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(out, "Album"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(out, "Album", "01.ogg"), []byte("OggS\x00\x02"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	album := state.LibraryAlbum{CID: "a1", Name: "Album", Dir: "Album", Tracks: []state.LibraryTrack{
+		{Number: 1, CID: "s1", Title: "One", Path: filepath.Join("Album", "01.ogg"), FileType: ".ogg"},
+	}}
+
+	lib, err := state.NewLibraryStore(filepath.Join(out, "library.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.PutAlbum(album); err != nil {
+		t.Fatal(err)
+	}
+	if code := runVerifyCommand([]string{"--quick", "--state", "json", "--output", out}); code != 0 {
+		t.Fatalf("verify with JSON state exit code = %d, want 0", code)
+	}
+
+	db, err := librarydb.Open(context.Background(), filepath.Join(out, librarydb.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutAlbum(album); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if code := runVerifyCommand([]string{"--quick", "--output", out}); code != 0 {
+		t.Fatalf("verify with SQLite state exit code = %d, want 0", code)
+	}
+
+	if err := os.Remove(filepath.Join(out, "Album", "01.ogg")); err != nil {
+		t.Fatal(err)
+	}
+	if code := runVerifyCommand([]string{"--quick", "--output", out}); code != 1 {
+		t.Fatalf("verify with missing track exit code = %d, want 1", code)
+	}
+}
+
+func TestRunListCommandLeavesMissingOutputDir(t *testing.T) {
+	inspectTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(`{"data":[{"cid":"a1","name":"Album"}]}`)),
+		}, nil
+	})
+	t.Cleanup(func() { inspectTransport = nil })
+
+	out := filepath.Join(t.TempDir(), "missing")
+	if code := runListCommand([]string{"--output", out, "--refresh-albums", "--json"}); code != 0 {
+		t.Fatalf("list exit code = %d, want 0", code)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Fatalf("list created the output directory: %v", err)
+	}
+}
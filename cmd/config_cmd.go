@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"msr-archiver/internal/config"
+)
+
+// runConfigCommand handles `config show [flags]` and returns an exit code.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, "usage: msr-archiver config show [--config PATH] [--profile NAME] [flags]")
+		return 2
+	}
+
+	_, eff, err := config.ParseArgs("config show", args[1:], os.Getenv)
+	if err != nil {
+		return flagErrorCode(err)
+	}
+
+	if _, err := eff.WriteTo(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"msr-archiver/internal/config"
+	"msr-archiver/internal/librarydb"
+	"msr-archiver/internal/logging"
+)
+
+const dbUsage = "usage: msr-archiver db query [--json] SQL | msr-archiver db import [flags]"
+
+// runDBCommand handles `db <subcommand>` and returns an exit code.
+func runDBCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, dbUsage)
+		return 2
+	}
+	switch args[0] {
+	case "query":
+		return runDBQuery(args[1:])
+	case "import":
+		return runDBImport(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, dbUsage)
+		return 2
+	}
+}
+
+// runDBQuery runs SQL against the library database opened read-only.
+func runDBQuery(args []string) int {
+	var asJSON bool
+	cfg, _, rest, err := config.ParseCommand("db query", args, os.Getenv, func(fs *flag.FlagSet) {
+		fs.BoolVar(&asJSON, "json", false, "print rows as JSON objects")
+	})
+	if err != nil {
+		return flagErrorCode(err)
+	}
+	sql := strings.TrimSpace(strings.Join(rest, " "))
+	if sql == "" {
+		fmt.Fprintln(os.Stderr, dbUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	db, err := librarydb.OpenReadOnly(ctx, resolveLibraryDBPath(cfg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	res, err := db.Query(ctx, sql)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if asJSON {
+		if err := writeQueryJSON(os.Stdout, res); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	writeQueryTable(os.Stdout, res)
+	return 0
+}
+
+// runDBImport merges the JSON state files of the output directory into the
+// library database, creating it if needed.
+func runDBImport(args []string) int {
+	cfg, _, _, err := config.ParseCommand("db import", args, os.Getenv, nil)
+	if err != nil {
+		return flagErrorCode(err)
+	}
+	logger := logging.NewWithWriter(os.Stderr)
+	ctx := context.Background()
+
+	db, err := librarydb.Open(ctx, resolveLibraryDBPath(cfg))
+	if err != nil {
+		logger.Errorf("%v", err)
+		return 1
+	}
+	defer db.Close()
+
+	stats, err := db.ImportJSON(ctx, jsonImportSources(cfg))
+	if err != nil {
+		logger.Errorf("import JSON state: %v", err)
+		return 1
+	}
+	if stats.Empty() {
+		logger.Infof("No JSON state files found in %s", cfg.OutputDir)
+		return 0
+	}
+	logger.Infof(
+		"Imported into %s: %d completed albums, %d archived albums, %d catalog albums, %d songs",
+		db.Path(), stats.Completed, stats.Archived, stats.Catalog, stats.Songs,
+	)
+	return 0
+}
+
+func writeQueryTable(w io.Writer, res librarydb.Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(res.Columns, "\t"))
+	for _, row := range res.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			if v == nil {
+				cells[i] = "NULL"
+				continue
+			}
+			cells[i] = strings.ReplaceAll(fmt.Sprint(v), "\t", " ")
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "(%d rows)\n", len(res.Rows))
+}
+
+func writeQueryJSON(w io.Writer, res librarydb.Result) error {
+	out := make([]map[string]any, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		obj := make(map[string]any, len(row))
+		for i, v := range row {
+			obj[res.Columns[i]] = v
+		}
+		out = append(out, obj)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"msr-archiver/internal/librarydb"
+)
+
+func TestWriteQueryOutput(t *testing.T) {
+	res := librarydb.Result{
+		Columns: []string{"name", "tracks", "archived_at"},
+		Rows: [][]any{
+			{"Album\tOne", int64(3), nil},
+			{"B", int64(12), "2026-04-01T08:00:00Z"},
+		},
+	}
+
+	var table bytes.Buffer
+	writeQueryTable(&table, res)
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "name") || !strings.Contains(lines[1], "Album One") ||
+		!strings.Contains(lines[1], "NULL") || lines[3] != "(2 rows)" {
+		t.Fatalf("table output:\n%s", table.String())
+	}
+
+	var out bytes.Buffer
+	if err := writeQueryJSON(&out, res); err != nil {
+		t.Fatalf("writeQueryJSON failed: %v", err)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(rows) != 2 || rows[1]["tracks"] != float64(12) || rows[0]["archived_at"] != nil {
+		t.Fatalf("JSON rows = %v", rows)
+	}
+}
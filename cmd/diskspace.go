@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"msr-archiver/internal/config"
+	"msr-archiver/internal/diskspace"
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/worker"
+)
+
+const (
+	// defaultTrackBytes is the assumed size of an archived FLAC track when
+	// the library has no history yet.
+	defaultTrackBytes = 30 << 20
+	// defaultTracksPerAlbum is assumed for albums whose songs are unknown.
+	defaultTracksPerAlbum = 8
+	// wavScratchFactor approximates the size of the intermediate WAV relative
+	// to the FLAC it is converted to; both exist on disk during conversion.
+	wavScratchFactor = 2
+)
+
+// spaceHistory holds averages of the archived library.
+type spaceHistory struct {
+	trackBytes     int64
+	tracksPerAlbum int
+	librarySize    int64
+}
+
+// libraryHistory derives per-track and per-album averages from library state,
+// falling back to defaults for an empty library.
+func libraryHistory(outputDir string, libraryStore libraryState) spaceHistory {
+	hist := spaceHistory{trackBytes: defaultTrackBytes, tracksPerAlbum: defaultTracksPerAlbum}
+	sizes := archivedSizes(outputDir, libraryStore)
+
+	var sized, tracks, albums int
+	for _, a := range libraryStore.Albums() {
+		if len(a.Tracks) == 0 {
+			continue
+		}
+		albums++
+		tracks += len(a.Tracks)
+		if size, ok := sizes[a.CID]; ok {
+			hist.librarySize += size
+			sized += len(a.Tracks)
+		}
+	}
+	if sized > 0 {
+		hist.trackBytes = hist.librarySize / int64(sized)
+	}
+	if albums > 0 {
+		hist.tracksPerAlbum = max(1, tracks/albums)
+	}
+	return hist
+}
+
+// spaceEstimate is the disk space a run is expected to need.
+type spaceEstimate struct {
+	tracks int
+	// bytes is the size of the finished files.
+	bytes int64
+	// scratch is the extra space held by WAV files during conversion.
+	scratch int64
+	// measured is set when sizes come from HEAD requests.
+	measured bool
+}
+
+func (e spaceEstimate) total() int64 {
+	return e.bytes + e.scratch
+}
+
+// estimateFromHistory estimates pending albums from library averages. songs
+// holds known song lists by album CID, e.g. from the catalog cache; planned
+// songs already in the library are not counted.
+func estimateFromHistory(
+	albums []model.Album,
+	songs map[string][]model.Song,
+	libraryStore libraryState,
+	plan songPlan,
+	hist spaceHistory,
+	workers int,
+) spaceEstimate {
+	var est spaceEstimate
+	for _, album := range albums {
+		have := make(map[string]struct{})
+		if rec, ok := libraryStore.Album(album.CID); ok {
+			for _, t := range rec.Tracks {
+				have[t.CID] = struct{}{}
+			}
+		}
+
+		list, known := songs[album.CID]
+		if !known {
+			est.tracks += max(0, hist.tracksPerAlbum-len(have))
+			continue
+		}
+		for i, song := range list {
+			if _, ok := have[song.CID]; ok {
+				continue
+			}
+			if plan.includes(album, i+1, song) {
+				est.tracks++
+			}
+		}
+	}
+	est.bytes = int64(est.tracks) * hist.trackBytes
+	est.scratch = int64(min(max(workers, 1), est.tracks)) * hist.trackBytes * wavScratchFactor
+	return est
+}
+
+// estimateFromHeads sizes pending tracks with HEAD requests against their
+// source URLs. Source sizes are used as-is, which overestimates FLAC output.
+func (r *albumRunner) estimateFromHeads(ctx context.Context, albums []model.Album, hist spaceHistory) (spaceEstimate, error) {
+	var (
+		mu      sync.Mutex
+		est     = spaceEstimate{measured: true}
+		largest int64
+	)
+	jobs := make([]worker.Job, 0, len(albums))
+	for _, album := range albums {
+		jobs = append(jobs, func(ctx context.Context) error {
+			have := make(map[string]struct{})
+			if rec, ok := r.library.Album(album.CID); ok {
+				for _, t := range rec.Tracks {
+					have[t.CID] = struct{}{}
+				}
+			}
+			songs, err := withRetryResult(ctx, 3, func() ([]model.Song, error) {
+				return r.apiClient.GetAlbumSongs(ctx, album.CID)
+			})
+			if err != nil {
+				return fmt.Errorf("album %q: fetch album songs: %w", album.Name, err)
+			}
+			for i, song := range songs {
+				if _, ok := have[song.CID]; ok || !r.plan.includes(album, i+1, song) {
+					continue
+				}
+				detail, err := withRetryResult(ctx, 3, func() (model.SongDetail, error) {
+					return r.apiClient.GetSongDetail(ctx, song.CID)
+				})
+				if err != nil {
+					return fmt.Errorf("album %q: fetch song detail for %q: %w", album.Name, song.Name, err)
+				}
+				size, err := withRetryResult(ctx, 3, func() (int64, error) {
+					return r.downloader.ContentLength(ctx, detail.SourceURL)
+				})
+				if err != nil || size < 0 {
+					size = hist.trackBytes * wavScratchFactor
+				}
+
+				mu.Lock()
+				est.tracks++
+				est.bytes += size
+				largest = max(largest, size)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := worker.Run(ctx, r.cfg.Workers, jobs); err != nil {
+		return spaceEstimate{}, err
+	}
+	est.scratch = int64(min(max(r.cfg.Workers, 1), est.tracks)) * largest
+	return est, nil
+}
+
+// preflight checks the estimated space of a run against free space on the
+// output filesystem and the library size limit.
+func (r *albumRunner) preflight(ctx context.Context, albums []model.Album, songs map[string][]model.Song, limits diskspace.Limits) error {
+	var pending []model.Album
+	for _, album := range albums {
+		if !r.store.IsCompleted(album.Name) {
+			pending = append(pending, album)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	hist := libraryHistory(r.cfg.OutputDir, r.library)
+	est := estimateFromHistory(pending, songs, r.library, r.plan, hist, r.cfg.Workers)
+	if r.cfg.PreflightHead {
+		r.logger.Infof("Preflight: measuring %d pending album(s) with HEAD requests", len(pending))
+		measured, err := r.estimateFromHeads(ctx, pending, hist)
+		if err != nil {
+			return fmt.Errorf("measure pending tracks: %w", err)
+		}
+		est = measured
+	}
+
+	if r.cfg.StreamConvert {
+		// Streamed WAV data never reaches the disk.
+		est.scratch = 0
+	}
+
+	source := "library averages"
+	if est.measured {
+		source = "HEAD sizes"
+	}
+	r.logger.Infof(
+		"Preflight: %d pending track(s) need about %s plus %s conversion scratch (%s)",
+		est.tracks, diskspace.FormatSize(est.bytes), diskspace.FormatSize(est.scratch), source,
+	)
+
+	if limits.MaxLibrary > 0 && hist.librarySize+est.bytes > limits.MaxLibrary {
+		return fmt.Errorf(
+			"%w: library is %s and the run adds about %s, over --max-library-size %s",
+			diskspace.ErrLimit, diskspace.FormatSize(hist.librarySize), diskspace.FormatSize(est.bytes), diskspace.FormatSize(limits.MaxLibrary),
+		)
+	}
+
+	usage, err := diskspace.Stat(r.cfg.OutputDir)
+	if errors.Is(err, diskspace.ErrUnsupported) {
+		r.logger.Warnf("Preflight: %v; skipping free space check", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r.logger.Infof("Preflight: %s free on the output filesystem", diskspace.FormatSize(usage.Free))
+	if usage.Free-est.total() < limits.MinFree {
+		return fmt.Errorf(
+			"%w: about %s needed but %s free (keeping %s free); free up space, narrow the selection or pass --preflight=false",
+			diskspace.ErrLimit, diskspace.FormatSize(est.total()), diskspace.FormatSize(usage.Free), diskspace.FormatSize(limits.MinFree),
+		)
+	}
+	return nil
+}
+
+// trackReserve is the space checked for before each track: the finished
+// file, plus the intermediate WAV unless conversion is streamed.
+func trackReserve(cfg config.Config, hist spaceHistory) int64 {
+	if cfg.StreamConvert {
+		return hist.trackBytes
+	}
+	return hist.trackBytes * (1 + wavScratchFactor)
+}
+
+// parseSpaceLimits reads --min-free-space and --max-library-size.
+func parseSpaceLimits(minFree, maxLibrary string) (diskspace.Limits, error) {
+	var limits diskspace.Limits
+	var err error
+	if limits.MinFree, err = diskspace.ParseSize(minFree); err != nil {
+		return limits, fmt.Errorf("--min-free-space: %w", err)
+	}
+	if limits.MaxLibrary, err = diskspace.ParseSize(maxLibrary); err != nil {
+		return limits, fmt.Errorf("--max-library-size: %w", err)
+	}
+	return limits, nil
+}
+
+// fileSize returns the size of path, or zero if it cannot be read.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+func TestEstimateFromHistory(t *testing.T) {
+	dir := t.TempDir()
+	lib, err := state.NewLibraryStore(filepath.Join(dir, "library.json"))
+	if err != nil {
+		t.Fatalf("NewLibraryStore failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "Old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.flac", "b.flac"} {
+		if err := os.WriteFile(filepath.Join(dir, "Old", name), make([]byte, 1000), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lib.PutAlbum(state.LibraryAlbum{CID: "old", Tracks: []state.LibraryTrack{
+		{CID: "o1", Path: "Old/a.flac"}, {CID: "o2", Path: "Old/b.flac"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.PutAlbum(state.LibraryAlbum{CID: "partial", Tracks: []state.LibraryTrack{{CID: "p1", Path: "Partial/1.flac"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	hist := libraryHistory(dir, lib)
+	if hist.trackBytes != 1000 || hist.librarySize != 2000 || hist.tracksPerAlbum != 1 {
+		t.Fatalf("unexpected history %+v", hist)
+	}
+	hist.tracksPerAlbum = 4
+
+	albums := []model.Album{{CID: "known"}, {CID: "unknown"}, {CID: "partial"}}
+	songs := map[string][]model.Song{
+		"known":   {{CID: "k1"}, {CID: "k2"}, {CID: "k3"}},
+		"partial": {{CID: "p1"}, {CID: "p2"}},
+	}
+	est := estimateFromHistory(albums, songs, lib, songPlan{}, hist, 2)
+	// 3 known + 4 assumed + 1 missing from the partial album.
+	if est.tracks != 8 || est.bytes != 8000 {
+		t.Fatalf("estimate = %+v, want 8 tracks / 8000 bytes", est)
+	}
+	if est.scratch != 2*1000*wavScratchFactor {
+		t.Fatalf("scratch = %d, want two concurrent WAV files", est.scratch)
+	}
+}
+
+func TestParseSpaceLimits(t *testing.T) {
+	limits, err := parseSpaceLimits("1GiB", "")
+	if err != nil || limits.MinFree != 1<<30 || limits.MaxLibrary != 0 {
+		t.Fatalf("parseSpaceLimits = %+v, %v", limits, err)
+	}
+	if _, err := parseSpaceLimits("1GiB", "lots"); err == nil {
+		t.Fatalf("expected error for an invalid --max-library-size")
+	}
+}
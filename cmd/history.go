@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+
+	"msr-archiver/internal/librarydb"
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/notify"
+)
+
+// recordHistory stores run events in the library database. Like
+// notifications, history failures are logged and never fail a run.
+func (r *albumRunner) recordHistory(ctx context.Context, ev notify.Event) {
+	if r.history == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	var err error
+	switch ev.Type {
+	case notify.EventRunStarted:
+		var id int64
+		id, err = r.history.StartRun(ctx, ev.Albums, ev.Time)
+		r.runID.Store(id)
+	case notify.EventRunFinished:
+		err = r.history.FinishRun(ctx, r.runID.Load(), ev.Albums, ev.Failed, ev.Error)
+	default:
+		err = r.history.AddEvent(ctx, librarydb.Event{
+			RunID:    r.runID.Load(),
+			At:       ev.Time,
+			Type:     ev.Type,
+			AlbumCID: ev.AlbumCID,
+			Album:    ev.Album,
+			Tracks:   ev.Tracks,
+			Message:  ev.Error,
+		})
+	}
+	if err != nil {
+		r.logger.Warnf("Record run history failed: %v", err)
+	}
+}
+
+// recordCatalog keeps catalog metadata in the library database.
+func (r *albumRunner) recordCatalog(ctx context.Context, albums []model.Album) {
+	if r.history == nil {
+		return
+	}
+	if err := r.history.SaveCatalog(ctx, albums); err != nil {
+		r.logger.Warnf("Record catalog in library database failed: %v", err)
+	}
+}
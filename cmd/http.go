@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"msr-archiver/internal/api"
+	"msr-archiver/internal/config"
+	"msr-archiver/internal/diskspace"
+	"msr-archiver/internal/download"
+	"msr-archiver/internal/httpcache"
+	"msr-archiver/internal/httpclient"
+	"msr-archiver/internal/logging"
+)
+
+// newHTTPClients builds the client for API calls, capped by --http-timeout,
+// and the client for downloads, which is bounded by the downloader's stall
+// watchdog instead so large transfers are not cut off. Both share one
+// transport and connection pool.
+func newHTTPClients(cfg config.Config) (*http.Client, *http.Client, error) {
+	rt, err := httpclient.NewTransport(httpclient.Options{
+		DialTimeout:           cfg.DialTimeout,
+		TLSHandshakeTimeout:   cfg.TLSTimeout,
+		ResponseHeaderTimeout: cfg.HeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   max(cfg.Workers, cfg.MaxConnsPerHost, 2),
+		Proxy:                 cfg.Proxy,
+		CAFile:                cfg.CAFile,
+		UserAgent:             cfg.UserAgent,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("configure HTTP transport: %w", err)
+	}
+	return &http.Client{Transport: rt, Timeout: cfg.HTTPTimeout}, &http.Client{Transport: rt}, nil
+}
+
+// downloadOptions maps the transfer flags onto the downloader.
+func downloadOptions(cfg config.Config) (download.Options, error) {
+	minRate, err := diskspace.ParseSize(cfg.MinTransferRate)
+	if err != nil {
+		return download.Options{}, fmt.Errorf("--min-transfer-rate: %w", err)
+	}
+	if cfg.ResumeAttempts < 0 {
+		return download.Options{}, fmt.Errorf("--resume-attempts must be >= 0")
+	}
+	return download.Options{
+		Validate:       cfg.Validate,
+		Stream:         cfg.StreamConvert,
+		StallTimeout:   cfg.StallTimeout,
+		MinRate:        minRate,
+		ResumeAttempts: cfg.ResumeAttempts,
+	}, nil
+}
+
+// newAPIClient paces API calls by --api-rate and --api-burst, independent of
+// download bandwidth, and caches album and song details. --refresh-albums
+// revalidates every cached response.
+func newAPIClient(cfg config.Config, httpClient *http.Client) *api.Client {
+	opts := api.Options{
+		Rate:   cfg.APIRate,
+		Burst:  cfg.APIBurst,
+		Budget: cfg.APIBudget,
+	}
+	if cfg.APICache {
+		opts.Cache = httpcache.New(resolveAPICacheDir(cfg))
+		opts.AlbumDetailTTL = cfg.AlbumDetailTTL
+		opts.SongDetailTTL = cfg.SongDetailTTL
+		if cfg.RefreshAlbums {
+			opts.AlbumDetailTTL, opts.SongDetailTTL = 0, 0
+		}
+	}
+	return api.NewWithOptions(httpClient, opts)
+}
+
+func resolveAPICacheDir(cfg config.Config) string {
+	if strings.TrimSpace(cfg.APICacheDir) != "" {
+		return cfg.APICacheDir
+	}
+	return filepath.Join(cfg.OutputDir, "api_cache")
+}
+
+// logAPIUsage reports the API requests of a run.
+func logAPIUsage(logger *logging.Logger, client *api.Client) {
+	stats := client.Stats()
+	if stats.Requests == 0 && stats.CacheHits == 0 {
+		return
+	}
+	budget := ""
+	if stats.Budget > 0 {
+		budget = fmt.Sprintf(" of %d budgeted", stats.Budget)
+	}
+	logger.Infof("API requests: %d%s (%d throttled, %d server errors, %d failed, %d unchanged), %d answered from cache, %s waiting for the rate limit",
+		stats.Requests, budget, stats.Throttled, stats.ServerErrors, stats.Failed, stats.Revalidated, stats.CacheHits, stats.Waited.Round(time.Millisecond))
+	if stats.LowestRate > 0 && stats.Throttled+stats.ServerErrors+stats.Failed > 0 {
+		logger.Warnf("API rate was lowered to %.2f requests/s after throttled or failing responses", stats.LowestRate)
+	}
+}
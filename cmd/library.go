@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"msr-archiver/internal/config"
+	"msr-archiver/internal/library"
+	"msr-archiver/internal/logging"
+	"msr-archiver/internal/model"
+)
+
+// writeLibraryIndex regenerates playlists, CUE sheets and the library index
+// from state.
+// Failures are logged rather than failing the run since the audio is already
+// archived.
+func writeLibraryIndex(
+	cfg config.Config,
+	logger *logging.Logger,
+	catalog []model.Album,
+	libraryStore libraryState,
+	runStarted time.Time,
+) {
+	opts := library.Options{
+		OutputDir: cfg.OutputDir,
+		Catalog:   catalog,
+		Albums:    libraryStore.Albums(),
+	}
+	if cfg.NewPlaylist {
+		opts.NewSince = runStarted
+	}
+
+	sum, err := library.Generate(opts)
+	if err != nil {
+		logger.Warnf("Generate playlists and library index failed: %v", err)
+		return
+	}
+
+	logger.Infof("Wrote %d album playlists, %d CUE sheets and library index (%d albums, %d tracks)", sum.Playlists, sum.CueSheets, sum.Albums, sum.Tracks)
+	if cfg.NewPlaylist {
+		logger.Infof("New-tracks playlist: %d tracks archived this run", sum.NewTracks)
+	}
+}
+
+// archivedSizes sums the on-disk size of each archived album's tracks, keyed
+// by album CID. Missing files are ignored.
+func archivedSizes(outputDir string, libraryStore libraryState) map[string]int64 {
+	sizes := make(map[string]int64)
+	if libraryStore == nil {
+		return sizes
+	}
+	for _, a := range libraryStore.Albums() {
+		var total int64
+		for _, t := range a.Tracks {
+			if info, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(t.Path))); err == nil {
+				total += info.Size()
+			}
+		}
+		if total > 0 {
+			sizes[a.CID] = total
+		}
+	}
+	return sizes
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"msr-archiver/internal/loudness"
+	"msr-archiver/internal/metadata"
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+// albumTrack is a finished track file produced by archiveAlbum.
+type albumTrack struct {
+	Path     string
+	FileType string
+}
+
+// applyLoudness measures track and album loudness once every track of an album
+// is on disk and writes REPLAYGAIN_* tags. Results are cached in the loudness
+// store so reruns only re-tag.
+func (r *albumRunner) applyLoudness(ctx context.Context, album model.Album, tracks []albumTrack) error {
+	if r.loudnessMode == loudness.ModeOff || len(tracks) == 0 {
+		return nil
+	}
+
+	files := make([]string, 0, len(tracks))
+	paths := make([]string, 0, len(tracks))
+	for _, t := range tracks {
+		files = append(files, filepath.Base(t.Path))
+		paths = append(paths, t.Path)
+	}
+
+	entry, cached := r.loudness.Get(album.Name, r.loudnessMode, files)
+	if cached {
+		r.logger.Infof("[%s] Using cached loudness analysis", album.Name)
+	} else {
+		r.logger.Infof("[%s] Analyzing loudness of %d tracks", album.Name, len(tracks))
+		entry = state.AlbumLoudness{
+			Mode:   r.loudnessMode,
+			Tracks: make(map[string]loudness.Measurement, len(tracks)),
+		}
+		for i, t := range tracks {
+			m, err := loudness.Analyze(ctx, t.Path)
+			if err != nil {
+				return fmt.Errorf("analyze %s: %w", files[i], err)
+			}
+			entry.Tracks[files[i]] = m
+		}
+
+		albumM, err := loudness.AnalyzeAlbum(ctx, paths)
+		if err != nil {
+			return fmt.Errorf("analyze album: %w", err)
+		}
+		entry.Album = albumM
+
+		if err := r.loudness.Put(album.Name, entry); err != nil {
+			return fmt.Errorf("persist loudness state: %w", err)
+		}
+	}
+
+	for i, t := range tracks {
+		tags := loudness.Tags(r.loudnessMode, entry.Tracks[files[i]], entry.Album)
+		if err := metadata.ApplyTags(ctx, t.Path, t.FileType, tags); err != nil {
+			return fmt.Errorf("write loudness tags for %s: %w", files[i], err)
+		}
+	}
+
+	r.logger.Infof(
+		"[%s] Album loudness %.1f LUFS (gain %+.2f dB)",
+		album.Name,
+		entry.Album.IntegratedLUFS,
+		entry.Album.Gain(r.loudnessMode),
+	)
+	return nil
+}
@@ -4,85 +4,210 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"msr-archiver/internal/api"
 	"msr-archiver/internal/audio"
 	"msr-archiver/internal/catalog"
 	"msr-archiver/internal/config"
+	"msr-archiver/internal/cover"
+	"msr-archiver/internal/diskspace"
 	"msr-archiver/internal/download"
+	"msr-archiver/internal/librarydb"
 	"msr-archiver/internal/logging"
+	"msr-archiver/internal/loudness"
 	"msr-archiver/internal/metadata"
 	"msr-archiver/internal/model"
+	"msr-archiver/internal/notify"
+	"msr-archiver/internal/preview"
+	"msr-archiver/internal/query"
 	"msr-archiver/internal/state"
 	"msr-archiver/internal/worker"
 )
 
 func main() {
-	cfg := config.Parse()
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+// runDownload is the default command: archive selected albums, or watch the
+// catalog with --watch.
+func runDownload(args []string) int {
+	cfg, _, err := config.ParseArgs("download", args, os.Getenv)
+	if err != nil {
+		return flagErrorCode(err)
+	}
 	logger := logging.New()
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	if err := audio.CheckFFmpeg(ctx); err != nil {
 		logger.Errorf("%v", err)
-		os.Exit(1)
+		return 1
 	}
 
 	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
 		logger.Errorf("create output directory: %v", err)
-		os.Exit(1)
+		return 1
 	}
 
-	store, err := state.NewStore(filepath.Join(cfg.OutputDir, "completed_albums.json"))
+	archive, err := openArchiveState(ctx, cfg, logger)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return 1
+	}
+	defer archive.Close()
+	store, libraryStore := archive.completion, archive.library
+
+	loudnessMode, err := loudness.ParseMode(cfg.Loudness)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return 1
+	}
+	loudnessStore, err := state.NewLoudnessStore(filepath.Join(cfg.OutputDir, "loudness.json"))
 	if err != nil {
-		logger.Errorf("initialize completion state: %v", err)
-		os.Exit(1)
+		logger.Errorf("initialize loudness state: %v", err)
+		return 1
 	}
 
-	httpClient := &http.Client{Timeout: cfg.HTTPTimeout}
-	apiClient := api.New(httpClient)
-	downloader := download.New(httpClient)
+	selections, err := state.NewSelectionStore(filepath.Join(cfg.OutputDir, "selections.json"))
+	if err != nil {
+		logger.Errorf("initialize selection state: %v", err)
+		return 1
+	}
+
+	songSelector, err := query.ParseSongs(cfg.Songs)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return 1
+	}
+
+	spaceLimits, err := parseSpaceLimits(cfg.MinFreeSpace, cfg.MaxLibrarySize)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return 1
+	}
+	hist := libraryHistory(cfg.OutputDir, libraryStore)
+
+	apiHTTP, downloadHTTP, err := newHTTPClients(cfg)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return 1
+	}
+	dlOpts, err := downloadOptions(cfg)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return 1
+	}
+	apiClient := newAPIClient(cfg, apiHTTP)
+	defer logAPIUsage(logger, apiClient)
+	downloader := download.NewWithOptions(downloadHTTP, dlOpts)
 	albumCache := catalog.NewCache(resolveAlbumCachePath(cfg))
+	covers, err := cover.NewPipeline(cover.Options{
+		MaxDimension: cfg.CoverMaxSize,
+		Format:       cfg.CoverFormat,
+		JPEGQuality:  cfg.CoverQuality,
+		FolderJPG:    cfg.FolderJPG,
+	})
+	if err != nil {
+		logger.Errorf("configure cover pipeline: %v", err)
+		return 1
+	}
+
+	runner := &albumRunner{
+		cfg:          cfg,
+		logger:       logger,
+		apiClient:    apiClient,
+		downloader:   downloader,
+		covers:       covers,
+		store:        store,
+		loudness:     loudnessStore,
+		loudnessMode: loudnessMode,
+		library:      libraryStore,
+		notifier:     buildNotifier(cfg, apiHTTP),
+		plan:         songPlan{selector: songSelector},
+		space:        diskspace.NewGuard(cfg.OutputDir, spaceLimits, hist.librarySize),
+		trackReserve: trackReserve(cfg, hist),
+		history:      archive.db,
+	}
+
+	if cfg.Watch {
+		if err := runWatch(ctx, runner, albumCache); err != nil {
+			logger.Errorf("watch: %v", err)
+			return 1
+		}
+		return 0
+	}
 
 	albums, err := loadAlbums(ctx, cfg, logger, apiClient, albumCache)
 	if err != nil {
 		logger.Errorf("%v", err)
-		os.Exit(1)
+		return 1
 	}
+	runner.recordCatalog(ctx, albums)
 
-	selectedAlbums, err := chooseAlbums(ctx, cfg, albums, store, apiClient)
+	selectedAlbums, picks, err := chooseAlbums(ctx, cfg, logger, albums, store, selections, libraryStore, apiClient, albumCache)
 	if err != nil {
 		logger.Errorf("select albums: %v", err)
-		os.Exit(1)
+		return 1
 	}
+	runner.plan.picks = picks
 	if len(selectedAlbums) == 0 {
 		logger.Warnf("No albums selected; exiting")
-		return
+		return 0
 	}
 	logger.Infof("Selected %d/%d albums for download", len(selectedAlbums), len(albums))
 
-	jobs := make([]worker.Job, 0, len(selectedAlbums))
+	var cachedSongs map[string][]model.Song
+	if cfg.Preflight || cfg.JobOrder == jobOrderSmallFirst {
+		if cachedSongs, err = albumCache.LoadSongs(); err != nil {
+			logger.Warnf("Read cached song lists failed: %v", err)
+		}
+	}
+	if cfg.Preflight {
+		if err := runner.preflight(ctx, selectedAlbums, cachedSongs, spaceLimits); err != nil {
+			logger.Errorf("preflight: %v", err)
+			return 1
+		}
+	}
+
+	tasks := make([]albumTask, 0, len(selectedAlbums))
 	for _, album := range selectedAlbums {
-		album := album
-		jobs = append(jobs, func(ctx context.Context) error {
-			if err := processAlbum(ctx, cfg, logger, apiClient, downloader, store, album); err != nil {
-				return fmt.Errorf("album %q: %w", album.Name, err)
-			}
-			return nil
-		})
+		tasks = append(tasks, albumTask{album: album})
+	}
+	priorities, err := jobPriorities(cfg.JobOrder, tasks, cachedSongs)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return 1
+	}
+
+	runStarted := time.Now().UTC()
+	_, runErr := runner.runAlbums(ctx, tasks, priorities)
+
+	if cfg.Playlists {
+		writeLibraryIndex(cfg, logger, albums, libraryStore, runStarted)
 	}
 
-	if err := worker.Run(ctx, cfg.Workers, jobs); err != nil {
-		logger.Errorf("one or more albums failed: %v", err)
-		os.Exit(1)
+	if runErr != nil {
+		logger.Errorf("one or more albums failed: %v", runErr)
+		return 1
+	}
+
+	// The autosaved picker session is kept until the run it started succeeds.
+	if usesPicker(cfg) {
+		if err := selections.ClearSession(); err != nil {
+			logger.Warnf("Clear saved picker selection failed: %v", err)
+		}
 	}
 
 	logger.Infof("All albums processed successfully")
+	return 0
 }
 
 func resolveAlbumCachePath(cfg config.Config) string {
@@ -92,12 +217,37 @@ func resolveAlbumCachePath(cfg config.Config) string {
 	return filepath.Join(cfg.OutputDir, "albums_cache.json")
 }
 
+// loadAlbums returns the cached catalog while it is fresh, and otherwise
+// fetches it and stores it in the cache.
 func loadAlbums(
 	ctx context.Context,
 	cfg config.Config,
 	logger *logging.Logger,
 	apiClient *api.Client,
 	cache *catalog.Cache,
+) ([]model.Album, error) {
+	return loadCatalog(ctx, cfg, logger, apiClient, cache, true)
+}
+
+// loadAlbumsReadOnly is loadAlbums for inspection commands: a fetched
+// catalog is not written to the cache or its changelog.
+func loadAlbumsReadOnly(
+	ctx context.Context,
+	cfg config.Config,
+	logger *logging.Logger,
+	apiClient *api.Client,
+	cache *catalog.Cache,
+) ([]model.Album, error) {
+	return loadCatalog(ctx, cfg, logger, apiClient, cache, false)
+}
+
+func loadCatalog(
+	ctx context.Context,
+	cfg config.Config,
+	logger *logging.Logger,
+	apiClient *api.Client,
+	cache *catalog.Cache,
+	persist bool,
 ) ([]model.Album, error) {
 	var cached []model.Album
 	var cachedAt time.Time
@@ -131,26 +281,55 @@ func loadAlbums(
 		}
 	}
 
+	albums, err := fetchAlbums(ctx, logger, apiClient)
+	if err != nil {
+		if hasCached {
+			logger.Warnf("%v; using cached catalog with %d albums", err, len(cached))
+			return cached, nil
+		}
+		return nil, err
+	}
+	if persist {
+		persistAlbums(logger, cache, albums)
+	}
+	return albums, nil
+}
+
+// fetchAlbums fetches the album catalog from the API.
+func fetchAlbums(ctx context.Context, logger *logging.Logger, apiClient *api.Client) ([]model.Album, error) {
 	logger.Infof("Fetching album catalog from API")
 	albums, err := withRetryResult(ctx, 3, func() ([]model.Album, error) {
 		return apiClient.GetAlbums(ctx)
 	})
 	if err != nil {
-		if hasCached {
-			logger.Warnf("Fetch albums failed (%v); using cached catalog with %d albums", err, len(cached))
-			return cached, nil
-		}
 		return nil, fmt.Errorf("fetch albums: %w", err)
 	}
-
 	logger.Infof("Fetched %d albums from API", len(albums))
-	if err := cache.Save(albums); err != nil {
+	return albums, nil
+}
+
+// persistAlbums stores a fetched catalog in the album cache and logs the
+// changes since the previous snapshot.
+func persistAlbums(logger *logging.Logger, cache *catalog.Cache, albums []model.Album) {
+	diff, err := cache.Refresh(albums)
+	switch {
+	case errors.Is(err, catalog.ErrUnreadableSnapshot):
+		logger.Warnf("%v", err)
+		logger.Infof("Replaced album cache: %s", cache.Path())
+	case err != nil:
 		logger.Warnf("Persist album cache failed: %v", err)
-	} else {
+	default:
 		logger.Infof("Updated album cache: %s", cache.Path())
 	}
-
-	return albums, nil
+	if !diff.Empty() {
+		logger.Infof(
+			"Catalog changes: %d added, %d removed, %d changed (see %s)",
+			len(diff.Added),
+			len(diff.Removed),
+			len(diff.Changed),
+			cache.ChangelogPath(),
+		)
+	}
 }
 
 func shouldUseCachedAlbums(cachedAt time.Time, ttl time.Duration, now time.Time) bool {
@@ -168,98 +347,79 @@ func shouldUseCachedAlbums(cachedAt time.Time, ttl time.Duration, now time.Time)
 	return age <= ttl
 }
 
+// chooseAlbums returns the albums to archive and, for presets and the
+// interactive picker, any per-album song choices.
 func chooseAlbums(
 	ctx context.Context,
 	cfg config.Config,
+	logger *logging.Logger,
 	albums []model.Album,
-	store *state.Store,
+	store completionState,
+	selections *state.SelectionStore,
+	libraryStore libraryState,
 	apiClient *api.Client,
-) ([]model.Album, error) {
+	albumCache *catalog.Cache,
+) ([]model.Album, songPicks, error) {
 	if len(albums) == 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
 
-	if strings.TrimSpace(cfg.Albums) != "" {
-		return selectAlbumsByQuery(albums, cfg.Albums)
+	albumQuery := strings.TrimSpace(cfg.Albums)
+	preset := strings.TrimSpace(cfg.SelectionPreset)
+	if albumQuery != "" && preset != "" {
+		return nil, nil, fmt.Errorf("--albums and --selection-preset cannot be combined")
 	}
-	if cfg.ChooseAlbums {
-		return chooseAlbumsInteractively(ctx, albums, store, apiClient)
+	if albumQuery != "" {
+		selected, err := selectAlbumsByQuery(albums, albumQuery, store)
+		return selected, nil, err
 	}
-	return albums, nil
-}
-
-func selectAlbumsByQuery(albums []model.Album, raw string) ([]model.Album, error) {
-	parts := strings.Split(raw, ",")
-	queries := make([]string, 0, len(parts))
-	for _, p := range parts {
-		p = strings.TrimSpace(p)
-		if p != "" {
-			queries = append(queries, p)
-		}
-	}
-	if len(queries) == 0 {
-		return nil, fmt.Errorf("no valid album query provided")
-	}
-
-	seen := make(map[string]struct{})
-	selected := make([]model.Album, 0, len(queries))
-	for _, q := range queries {
-		if strings.EqualFold(q, "all") {
-			return albums, nil
-		}
-
-		match, err := resolveAlbumQuery(albums, q)
-		if err != nil {
-			return nil, err
+	if preset != "" {
+		sel, ok := selections.Preset(preset)
+		if !ok {
+			saved := selections.Presets()
+			if len(saved) == 0 {
+				return nil, nil, fmt.Errorf("selection preset %q not found; no presets saved", preset)
+			}
+			return nil, nil, fmt.Errorf("selection preset %q not found (saved: %s)", preset, strings.Join(saved, ", "))
 		}
-		if _, ok := seen[match.CID]; ok {
-			continue
+		selected, picks, missing := albumsFromSelection(albums, sel)
+		if missing > 0 {
+			logger.Warnf("Selection preset %q: %d album(s) are no longer in the catalog", preset, missing)
 		}
-		seen[match.CID] = struct{}{}
-		selected = append(selected, match)
+		return selected, picks, nil
 	}
-
-	return selected, nil
+	if cfg.ChooseAlbums {
+		return chooseAlbumsInteractively(ctx, albums, store, pickerOptions{
+			selections:    selections,
+			sizes:         archivedSizes(cfg.OutputDir, libraryStore),
+			transliterate: cfg.SearchTransliterate,
+			songCache:     albumCache,
+			player:        preview.New(cfg.PreviewCommand, cfg.PreviewLength),
+		}, apiClient)
+	}
+	return albums, nil, nil
 }
 
-func resolveAlbumQuery(albums []model.Album, query string) (model.Album, error) {
-	q := strings.ToLower(strings.TrimSpace(query))
-	if q == "" {
-		return model.Album{}, fmt.Errorf("empty album query")
-	}
+// usesPicker reports whether albums are chosen in the interactive picker.
+func usesPicker(cfg config.Config) bool {
+	return cfg.ChooseAlbums && strings.TrimSpace(cfg.Albums) == "" && strings.TrimSpace(cfg.SelectionPreset) == ""
+}
 
-	exact := make([]model.Album, 0, 1)
-	for _, a := range albums {
-		if strings.EqualFold(a.CID, q) || strings.EqualFold(a.Name, q) {
-			exact = append(exact, a)
-		}
-	}
-	if len(exact) == 1 {
-		return exact[0], nil
-	}
-	if len(exact) > 1 {
-		return model.Album{}, fmt.Errorf("album query %q matched multiple albums exactly; use CID", query)
+// selectAlbumsByQuery resolves a --albums selection expression.
+func selectAlbumsByQuery(albums []model.Album, raw string, store completionState) ([]model.Album, error) {
+	expr, err := query.Parse(raw, query.Options{})
+	if err != nil {
+		return nil, err
 	}
+	return expr.Select(albums, completedFunc(store))
+}
 
-	contains := make([]model.Album, 0, 4)
-	for _, a := range albums {
-		if strings.Contains(strings.ToLower(a.Name), q) || strings.Contains(strings.ToLower(a.CID), q) {
-			contains = append(contains, a)
-		}
+// completedFunc reports archive status from store; a nil store marks nothing
+// completed.
+func completedFunc(store completionState) func(model.Album) bool {
+	return func(a model.Album) bool {
+		return store != nil && store.IsCompleted(a.Name)
 	}
-	if len(contains) == 1 {
-		return contains[0], nil
-	}
-	if len(contains) > 1 {
-		labels := make([]string, 0, len(contains))
-		for _, a := range contains {
-			labels = append(labels, fmt.Sprintf("%s (%s)", a.Name, a.CID))
-		}
-		sort.Strings(labels)
-		return model.Album{}, fmt.Errorf("album query %q is ambiguous: %s", query, strings.Join(labels, ", "))
-	}
-
-	return model.Album{}, fmt.Errorf("album query %q not found", query)
 }
 
 func albumOptionLabel(order int, album model.Album, completed bool) string {
@@ -333,90 +493,182 @@ func albumsFromIndexes(albums []model.Album, indexes []int) ([]model.Album, erro
 	return selected, nil
 }
 
-func processAlbum(
-	ctx context.Context,
-	cfg config.Config,
-	logger *logging.Logger,
-	apiClient *api.Client,
-	downloader *download.Downloader,
-	store *state.Store,
-	album model.Album,
-) error {
-	if store.IsCompleted(album.Name) {
-		logger.Infof("Skipping completed album: %s", album.Name)
+// albumRunner bundles the dependencies shared by every album job.
+type albumRunner struct {
+	cfg          config.Config
+	logger       *logging.Logger
+	apiClient    *api.Client
+	downloader   *download.Downloader
+	covers       *cover.Pipeline
+	store        completionState
+	loudness     *state.LoudnessStore
+	loudnessMode string
+	library      libraryState
+	notifier     *notify.Notifier
+	plan         songPlan
+	// space stops new tracks before disk limits are reached; trackReserve is
+	// the space checked for before each track.
+	space        *diskspace.Guard
+	trackReserve int64
+	// history records runs and album events; nil with --state=json.
+	history *librarydb.DB
+	runID   atomic.Int64
+}
+
+// albumJob wraps archiveAlbum as a worker job that fires album notifications
+// and counts albums that received new tracks.
+func (r *albumRunner) albumJob(album model.Album, refresh bool, archived *atomic.Int32) worker.Job {
+	return func(ctx context.Context) error {
+		started := time.Now()
+		written, err := r.archiveAlbum(ctx, album, refresh)
+		if err != nil {
+			r.notify(ctx, notify.Event{
+				Type:     notify.EventAlbumFailed,
+				AlbumCID: album.CID,
+				Album:    album.Name,
+				Error:    err.Error(),
+			})
+			return fmt.Errorf("album %q: %w", album.Name, err)
+		}
+		if written > 0 {
+			archived.Add(1)
+			r.notify(ctx, notify.Event{
+				Type:     notify.EventAlbumCompleted,
+				AlbumCID: album.CID,
+				Album:    album.Name,
+				Tracks:   written,
+				Duration: time.Since(started).Round(time.Second).String(),
+			})
+		}
 		return nil
 	}
+}
+
+// archiveAlbum downloads an album and returns the number of tracks written.
+// With refresh set, completed albums are revisited and only tracks missing
+// from library state are downloaded.
+func (r *albumRunner) archiveAlbum(ctx context.Context, album model.Album, refresh bool) (int, error) {
+	if !refresh && r.store.IsCompleted(album.Name) {
+		r.logger.Infof("Skipping completed album: %s", album.Name)
+		return 0, nil
+	}
+	if err := r.space.Check(0); err != nil {
+		return 0, err
+	}
 	started := time.Now()
-	logger.Infof("[%s] Starting album download", album.Name)
+	r.logger.Infof("[%s] Starting album download", album.Name)
 
-	albumDir := filepath.Join(cfg.OutputDir, download.MakeValid(album.Name))
+	albumDir := filepath.Join(r.cfg.OutputDir, download.MakeValid(album.Name))
 	if err := os.MkdirAll(albumDir, 0o755); err != nil {
-		return fmt.Errorf("create album directory: %w", err)
+		return 0, fmt.Errorf("create album directory: %w", err)
 	}
 
-	coverJPG := filepath.Join(albumDir, "cover.jpg")
-	coverPNG := filepath.Join(albumDir, "cover.png")
-	logger.Infof("[%s] Downloading album cover", album.Name)
+	coverSrc := filepath.Join(albumDir, ".cover-source")
+	r.logger.Infof("[%s] Downloading album cover", album.Name)
 	if err := withRetry(ctx, 3, func() error {
-		_, err := downloader.DownloadToFile(ctx, album.CoverURL, coverJPG)
+		_, err := r.downloader.DownloadToFile(ctx, album.CoverURL, coverSrc)
 		return err
 	}); err != nil {
-		return fmt.Errorf("download album cover: %w", err)
+		return 0, fmt.Errorf("download album cover: %w", err)
 	}
 
-	if err := audio.ConvertToPNG(coverJPG, coverPNG); err != nil {
-		return fmt.Errorf("convert cover to png: %w", err)
+	coverArt, err := r.covers.Process(coverSrc, albumDir)
+	if err != nil {
+		return 0, fmt.Errorf("process album cover: %w", err)
 	}
-	if err := os.Remove(coverJPG); err != nil && !errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("remove source cover jpg: %w", err)
+	if err := os.Remove(coverSrc); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("remove downloaded cover source: %w", err)
 	}
+	defer os.Remove(coverArt.EmbedPath)
 
 	songs, err := withRetryResult(ctx, 3, func() ([]model.Song, error) {
-		return apiClient.GetAlbumSongs(ctx, album.CID)
+		return r.apiClient.GetAlbumSongs(ctx, album.CID)
 	})
 	if err != nil {
-		return fmt.Errorf("fetch album songs: %w", err)
+		return 0, fmt.Errorf("fetch album songs: %w", err)
 	}
 	totalSongs := len(songs)
 	if totalSongs == 0 {
-		logger.Warnf("[%s] Album has no songs; marking as completed", album.Name)
+		r.logger.Warnf("[%s] Album has no songs; marking as completed", album.Name)
+	}
+	r.logger.Infof("[%s] Found %d songs", album.Name, totalSongs)
+
+	tracks := make([]albumTrack, 0, totalSongs)
+	libTracks := make([]state.LibraryTrack, 0, totalSongs)
+	// Tracks recorded by a refresh or an earlier partial run are kept
+	// instead of being downloaded again.
+	existing := make(map[string]state.LibraryTrack)
+	if rec, ok := r.library.Album(album.CID); ok {
+		for _, t := range rec.Tracks {
+			existing[t.CID] = t
+		}
 	}
-	logger.Infof("[%s] Found %d songs", album.Name, totalSongs)
-
+	written, skipped := 0, 0
 	for i, song := range songs {
 		song := song
 		track := i + 1
-		logger.Infof("[%s] [%d/%d] Resolving track: %s", album.Name, track, totalSongs, song.Name)
+		if prev, ok := existing[song.CID]; ok {
+			prevPath := filepath.Join(r.cfg.OutputDir, filepath.FromSlash(prev.Path))
+			if _, err := os.Stat(prevPath); err == nil {
+				prev.Number = track
+				tracks = append(tracks, albumTrack{Path: prevPath, FileType: prev.FileType})
+				libTracks = append(libTracks, prev)
+				continue
+			}
+		}
+		if !r.plan.includes(album, track, song) {
+			skipped++
+			continue
+		}
+		if err := r.space.Check(r.trackReserve); err != nil {
+			return 0, fmt.Errorf("track %q: %w", song.Name, err)
+		}
+		r.logger.Infof("[%s] [%d/%d] Resolving track: %s", album.Name, track, totalSongs, song.Name)
 
 		detail, err := withRetryResult(ctx, 3, func() (model.SongDetail, error) {
-			return apiClient.GetSongDetail(ctx, song.CID)
+			return r.apiClient.GetSongDetail(ctx, song.CID)
 		})
 		if err != nil {
-			return fmt.Errorf("fetch song detail for %q: %w", song.Name, err)
+			return 0, fmt.Errorf("fetch song detail for %q: %w", song.Name, err)
 		}
 
 		var lyricPath string
 		if detail.LyricURL != "" {
 			lyricPath = filepath.Join(albumDir, download.MakeValid(song.Name)+".lrc")
 			if err := withRetry(ctx, 3, func() error {
-				_, err := downloader.DownloadToFile(ctx, detail.LyricURL, lyricPath)
+				_, err := r.downloader.DownloadToFile(ctx, detail.LyricURL, lyricPath)
 				return err
 			}); err != nil {
-				return fmt.Errorf("download lyric for %q: %w", song.Name, err)
+				return 0, fmt.Errorf("download lyric for %q: %w", song.Name, err)
 			}
 		}
 
 		var songPath string
 		var fileType string
 		var dl download.FileDownloadResult
-		progress := makeSongProgressLogger(logger, album.Name, song.Name, track, totalSongs)
-		logger.Infof("[%s] [%d/%d] Downloading track: %s", album.Name, track, totalSongs, song.Name)
+		progress := makeSongProgressLogger(r.logger, album.Name, song.Name, track, totalSongs)
+		r.logger.Infof("[%s] [%d/%d] Downloading track: %s", album.Name, track, totalSongs, song.Name)
+		attempt := 0
 		if err := withRetry(ctx, 3, func() error {
+			attempt++
+			if attempt > 1 && r.cfg.APICache {
+				// The source URL may come from a stale cached response.
+				if fresh, err := r.apiClient.GetSongDetail(api.Revalidate(ctx), song.CID); err == nil {
+					detail = fresh
+				}
+			}
 			var dlErr error
-			songPath, fileType, dl, dlErr = downloader.DownloadSongWithProgress(ctx, albumDir, song.Name, detail.SourceURL, progress)
+			songPath, fileType, dl, dlErr = r.downloader.DownloadSongWithProgress(ctx, albumDir, song.Name, detail.SourceURL, progress)
 			return dlErr
 		}); err != nil {
-			return fmt.Errorf("download song %q: %w", song.Name, err)
+			return 0, fmt.Errorf("download song %q: %w", song.Name, err)
+		}
+
+		if dl.Detection.Mismatch() {
+			r.logger.Warnf(
+				"[%s] [%d/%d] %s: Content-Type %q does not match the %s content; stored as %s",
+				album.Name, track, totalSongs, song.Name, dl.ContentType, dl.Detection.Sniff, fileType,
+			)
 		}
 
 		if err := metadata.Apply(ctx, metadata.Input{
@@ -427,30 +679,77 @@ func processAlbum(
 			AlbumArtists: album.Artistes,
 			Artists:      song.Artistes,
 			TrackNumber:  i + 1,
-			CoverPath:    coverPNG,
+			CoverPath:    coverArt.EmbedPath,
 			LyricPath:    lyricPath,
 		}); err != nil {
-			return fmt.Errorf("write metadata for %q: %w", song.Name, err)
+			return 0, fmt.Errorf("write metadata for %q: %w", song.Name, err)
 		}
+		tracks = append(tracks, albumTrack{Path: songPath, FileType: fileType})
+		written++
+		r.space.Add(fileSize(songPath))
 
-		logger.Infof(
+		duration, err := audio.ProbeDuration(ctx, songPath)
+		if err != nil {
+			r.logger.Warnf("[%s] [%d/%d] Probe duration failed: %v", album.Name, track, totalSongs, err)
+		}
+		relPath, err := filepath.Rel(r.cfg.OutputDir, songPath)
+		if err != nil {
+			relPath = songPath
+		}
+		libTracks = append(libTracks, state.LibraryTrack{
+			Number:          track,
+			CID:             song.CID,
+			Title:           song.Name,
+			Artists:         song.Artistes,
+			Path:            filepath.ToSlash(relPath),
+			FileType:        fileType,
+			DurationSeconds: duration.Seconds(),
+			AddedAt:         time.Now().UTC(),
+		})
+
+		r.logger.Infof(
 			"[%s] [%d/%d] Finished track: %s (%s, %s, %s)",
 			album.Name,
 			track,
 			totalSongs,
 			song.Name,
 			fileType,
-			formatBytes(dl.BytesWritten),
+			diskspace.FormatSize(dl.BytesWritten),
 			formatRate(dl.BytesWritten, dl.Duration),
 		)
 	}
 
-	if err := store.MarkCompleted(album.Name); err != nil {
-		return fmt.Errorf("persist completion state: %w", err)
+	if len(libTracks) == 0 && skipped > 0 {
+		r.logger.Warnf("[%s] No songs match the song selection; skipping album", album.Name)
+		return 0, nil
+	}
+
+	if err := r.applyLoudness(ctx, album, tracks); err != nil {
+		return 0, fmt.Errorf("loudness analysis: %w", err)
+	}
+
+	if err := r.library.PutAlbum(state.LibraryAlbum{
+		CID:         album.CID,
+		Name:        album.Name,
+		Artists:     album.Artistes,
+		Dir:         download.MakeValid(album.Name),
+		CompletedAt: time.Now().UTC(),
+		Tracks:      libTracks,
+	}); err != nil {
+		return 0, fmt.Errorf("persist library state: %w", err)
+	}
+
+	if skipped > 0 {
+		r.logger.Infof("[%s] Archived %d/%d songs in %s; album stays pending", album.Name, totalSongs-skipped, totalSongs, time.Since(started).Round(time.Millisecond))
+		return written, nil
+	}
+
+	if err := r.store.MarkCompleted(album.Name); err != nil {
+		return 0, fmt.Errorf("persist completion state: %w", err)
 	}
 
-	logger.Infof("[%s] Completed album in %s", album.Name, time.Since(started).Round(time.Millisecond))
-	return nil
+	r.logger.Infof("[%s] Completed album in %s", album.Name, time.Since(started).Round(time.Millisecond))
+	return written, nil
 }
 
 func makeSongProgressLogger(
@@ -473,8 +772,8 @@ func makeSongProgressLogger(
 					totalTracks,
 					songName,
 					progress,
-					formatBytes(update.BytesWritten),
-					formatBytes(update.TotalBytes),
+					diskspace.FormatSize(update.BytesWritten),
+					diskspace.FormatSize(update.TotalBytes),
 				)
 				lastProgressBucket = progressBucket
 			}
@@ -492,35 +791,19 @@ func makeSongProgressLogger(
 				track,
 				totalTracks,
 				songName,
-				formatBytes(update.BytesWritten),
+				diskspace.FormatSize(update.BytesWritten),
 			)
 			lastUnknownProgress = now
 		}
 	}
 }
 
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-
-	value := float64(bytes)
-	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
-	idx := 0
-	for value >= unit && idx < len(units)-1 {
-		value /= unit
-		idx++
-	}
-	return fmt.Sprintf("%.1f %s", value, units[idx])
-}
-
 func formatRate(bytes int64, duration time.Duration) string {
 	if duration <= 0 {
 		return "n/a"
 	}
 	bytesPerSecond := int64(float64(bytes) / duration.Seconds())
-	return fmt.Sprintf("%s/s", formatBytes(bytesPerSecond))
+	return fmt.Sprintf("%s/s", diskspace.FormatSize(bytesPerSecond))
 }
 
 func withRetry(ctx context.Context, attempts int, fn func() error) error {
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"msr-archiver/internal/config"
+	"msr-archiver/internal/notify"
+)
+
+// buildNotifier creates hooks from --webhook and --notify-command.
+func buildNotifier(cfg config.Config, httpClient *http.Client) *notify.Notifier {
+	var hooks []notify.Hook
+	for _, url := range splitList(cfg.Webhooks) {
+		hooks = append(hooks, &notify.Webhook{URL: url, Client: httpClient})
+	}
+	if cmd := strings.TrimSpace(cfg.NotifyCommand); cmd != "" {
+		hooks = append(hooks, &notify.Command{Command: cmd})
+	}
+
+	return notify.New(hooks, notify.Options{
+		Events:   splitList(cfg.NotifyEvents),
+		Attempts: cfg.NotifyAttempts,
+		Timeout:  cfg.NotifyTimeout,
+	})
+}
+
+// notify records an event in the run history and fires it; delivery
+// failures are logged and hooks never fail a run.
+func (r *albumRunner) notify(ctx context.Context, ev notify.Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	r.recordHistory(ctx, ev)
+	if err := r.notifier.Fire(ctx, ev); err != nil {
+		r.logger.Warnf("Notification %s failed: %v", ev.Type, err)
+	}
+}
+
+func (r *albumRunner) notifyRunFinished(ctx context.Context, archived int, started time.Time, runErr error) {
+	ev := notify.Event{
+		Type:     notify.EventRunFinished,
+		Albums:   archived,
+		Duration: time.Since(started).Round(time.Second).String(),
+	}
+	if runErr != nil {
+		ev.Error = runErr.Error()
+		ev.Failed = countJoined(runErr)
+	}
+	// Deliver even when the run was interrupted.
+	r.notify(context.WithoutCancel(ctx), ev)
+}
+
+// countJoined returns the number of errors joined by errors.Join.
+func countJoined(err error) int {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return len(joined.Unwrap())
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sort"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+// selectionFromIndexes converts picker state keyed by catalog index into a
+// Selection keyed by CID.
+func selectionFromIndexes(albums []model.Album, selected map[int]struct{}, picks map[int]map[string]struct{}) state.Selection {
+	var sel state.Selection
+	for _, idx := range selectedIndexesFromSet(selected) {
+		if idx < 0 || idx >= len(albums) {
+			continue
+		}
+		cid := albums[idx].CID
+		sel.Albums = append(sel.Albums, cid)
+
+		picked, ok := picks[idx]
+		if !ok {
+			continue
+		}
+		songs := make([]string, 0, len(picked))
+		for songCID := range picked {
+			songs = append(songs, songCID)
+		}
+		sort.Strings(songs)
+		if sel.Songs == nil {
+			sel.Songs = make(map[string][]string)
+		}
+		sel.Songs[cid] = songs
+	}
+	return sel
+}
+
+// indexesFromSelection maps a saved Selection onto the current catalog.
+// Albums no longer in the catalog are counted in missing.
+func indexesFromSelection(albums []model.Album, sel state.Selection) (map[int]struct{}, map[int]map[string]struct{}, int) {
+	byCID := make(map[string]int, len(albums))
+	for idx, a := range albums {
+		byCID[a.CID] = idx
+	}
+
+	selected := make(map[int]struct{}, len(sel.Albums))
+	picks := make(map[int]map[string]struct{})
+	missing := 0
+	for _, cid := range sel.Albums {
+		idx, ok := byCID[cid]
+		if !ok {
+			missing++
+			continue
+		}
+		selected[idx] = struct{}{}
+		if songs, ok := sel.Songs[cid]; ok && len(songs) > 0 {
+			picked := make(map[string]struct{}, len(songs))
+			for _, songCID := range songs {
+				picked[songCID] = struct{}{}
+			}
+			picks[idx] = picked
+		}
+	}
+	return selected, picks, missing
+}
+
+// albumsFromSelection resolves a saved Selection to albums in catalog order
+// plus their song choices.
+func albumsFromSelection(albums []model.Album, sel state.Selection) ([]model.Album, songPicks, int) {
+	selected, picks, missing := indexesFromSelection(albums, sel)
+	out := make([]model.Album, 0, len(selected))
+	var songChoices songPicks
+	for _, idx := range selectedIndexesFromSet(selected) {
+		out = append(out, albums[idx])
+		if picked, ok := picks[idx]; ok {
+			if songChoices == nil {
+				songChoices = make(songPicks)
+			}
+			songChoices[albums[idx].CID] = picked
+		}
+	}
+	return out, songChoices, missing
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+func TestPickerRestoresAutosavedSession(t *testing.T) {
+	albums := []model.Album{{CID: "a1", Name: "First"}, {CID: "a2", Name: "Second"}, {CID: "a3", Name: "Third"}}
+	selections, err := state.NewSelectionStore(filepath.Join(t.TempDir(), "selections.json"))
+	if err != nil {
+		t.Fatalf("NewSelectionStore failed: %v", err)
+	}
+
+	m := newAlbumPickerModel(context.Background(), albums, nil, pickerOptions{selections: selections}, nil)
+	m.toggleSelection(2)
+	m.toggleSong(0, []model.Song{{CID: "s1"}, {CID: "s2"}}, 1)
+	m.autosave()
+
+	// The catalog order changed and a2 was removed before the next launch.
+	reordered := []model.Album{albums[2], albums[0]}
+	restored := newAlbumPickerModel(context.Background(), reordered, nil, pickerOptions{selections: selections}, nil)
+	if len(restored.selected) != 2 {
+		t.Fatalf("expected 2 restored albums, got %v", restored.selected)
+	}
+	picks := restored.selectedSongPicks()
+	if _, ok := picks["a1"]["s2"]; !ok || len(picks["a1"]) != 1 {
+		t.Fatalf("unexpected restored song picks: %v", picks)
+	}
+	if restored.notice == "" {
+		t.Fatalf("expected a restore notice")
+	}
+}
+
+func TestAlbumsFromSelection(t *testing.T) {
+	albums := []model.Album{{CID: "a1"}, {CID: "a2"}, {CID: "a3"}}
+	sel := state.Selection{Albums: []string{"a3", "gone", "a1"}, Songs: map[string][]string{"a3": {"s9"}}}
+
+	got, picks, missing := albumsFromSelection(albums, sel)
+	if missing != 1 {
+		t.Fatalf("missing = %d, want 1", missing)
+	}
+	if len(got) != 2 || got[0].CID != "a1" || got[1].CID != "a3" {
+		t.Fatalf("unexpected albums: %+v", got)
+	}
+	if _, ok := picks["a3"]["s9"]; !ok || len(picks) != 1 {
+		t.Fatalf("unexpected picks: %v", picks)
+	}
+}
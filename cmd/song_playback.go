@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/preview"
+)
+
+const (
+	playbackSeekStep     = 5 * time.Second
+	playbackTickInterval = time.Second
+)
+
+// songSourceMsg carries the resolved source URL of a song to preview.
+// albumIdx is the detail album the song was requested from.
+type songSourceMsg struct {
+	albumIdx int
+	song     model.Song
+	url      string
+	err      error
+}
+
+// playbackTickMsg refreshes the playback position while a preview plays.
+type playbackTickMsg struct{}
+
+// togglePlayback plays the focused song in the detail view, or stops it if it
+// is already playing. Source URLs are resolved through GetSongDetail once.
+func (m *albumPickerModel) togglePlayback() tea.Cmd {
+	if m.player == nil {
+		m.playback = "Audio preview is unavailable."
+		return nil
+	}
+	if m.detailCursor < 0 || m.detailCursor >= len(m.detailSongs) {
+		return nil
+	}
+	song := m.detailSongs[m.detailCursor]
+	if _, _, playing := m.player.Status(); playing && m.playingCID == song.CID {
+		m.stopPlayback()
+		return nil
+	}
+	m.stopPlayback()
+
+	if url, ok := m.sourceURLs[song.CID]; ok {
+		return m.startPlayback(song, url)
+	}
+	if m.api == nil {
+		m.playback = "Audio preview needs the API client."
+		return nil
+	}
+	m.playback = fmt.Sprintf("Resolving %s...", song.Name)
+	ctx, apiClient, albumIdx := m.ctx, m.api, m.detailAlbumIdx
+	return func() tea.Msg {
+		detail, err := withRetryResult(ctx, 3, func() (model.SongDetail, error) {
+			return apiClient.GetSongDetail(ctx, song.CID)
+		})
+		return songSourceMsg{albumIdx: albumIdx, song: song, url: detail.SourceURL, err: err}
+	}
+}
+
+// handleSongSource starts the resolved song unless the user has left the
+// detail view, switched albums or moved to another song meanwhile.
+func (m *albumPickerModel) handleSongSource(msg songSourceMsg) tea.Cmd {
+	if msg.err == nil {
+		m.sourceURLs[msg.song.CID] = msg.url
+	}
+	if !m.isFocusedSong(msg.albumIdx, msg.song.CID) {
+		m.playback = ""
+		return nil
+	}
+	if msg.err != nil {
+		m.playback = fmt.Sprintf("Preview failed: %v", msg.err)
+		return nil
+	}
+	return m.startPlayback(msg.song, msg.url)
+}
+
+// isFocusedSong reports whether the detail view shows albums[albumIdx] with
+// the cursor on songCID.
+func (m *albumPickerModel) isFocusedSong(albumIdx int, songCID string) bool {
+	if m.phase != pickerPhaseDetail || m.detailAlbumIdx != albumIdx {
+		return false
+	}
+	if m.detailCursor < 0 || m.detailCursor >= len(m.detailSongs) {
+		return false
+	}
+	return m.detailSongs[m.detailCursor].CID == songCID
+}
+
+func (m *albumPickerModel) startPlayback(song model.Song, url string) tea.Cmd {
+	if err := m.player.Play(preview.Track{Title: song.Name, URL: url}, 0); err != nil {
+		m.playback = fmt.Sprintf("Preview failed: %v", err)
+		return nil
+	}
+	m.playingCID = song.CID
+	m.playback = ""
+	return playbackTick()
+}
+
+func (m *albumPickerModel) seekPlayback(delta time.Duration) tea.Cmd {
+	if m.player == nil || m.playingCID == "" {
+		return nil
+	}
+	if err := m.player.Seek(delta); err != nil {
+		m.playback = fmt.Sprintf("Seek failed: %v", err)
+	}
+	return nil
+}
+
+// stopPlayback ends any running preview.
+func (m *albumPickerModel) stopPlayback() {
+	if m.player != nil {
+		m.player.Stop()
+	}
+	m.playingCID = ""
+	m.playback = ""
+}
+
+// handlePlaybackTick keeps ticking while the preview plays so the position
+// redraws, and forgets the song once the player exits.
+func (m *albumPickerModel) handlePlaybackTick() tea.Cmd {
+	if m.player == nil || m.playingCID == "" {
+		return nil
+	}
+	if _, _, playing := m.player.Status(); !playing {
+		m.playingCID = ""
+		return nil
+	}
+	return playbackTick()
+}
+
+func playbackTick() tea.Cmd {
+	return tea.Tick(playbackTickInterval, func(time.Time) tea.Msg { return playbackTickMsg{} })
+}
+
+// playbackLine describes the preview state for the detail view.
+func (m *albumPickerModel) playbackLine() string {
+	if m.playback != "" {
+		return m.playback
+	}
+	if m.player == nil || m.playingCID == "" {
+		return ""
+	}
+	track, pos, playing := m.player.Status()
+	if !playing {
+		return ""
+	}
+	return fmt.Sprintf("Playing: %s %s / %s", track.Title, formatClock(pos), formatClock(m.player.Window))
+}
+
+func formatClock(d time.Duration) string {
+	s := int(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,106 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/preview"
+)
+
+func TestPickerPlaybackToggle(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "played.log")
+	player := preview.New(`echo "$MSR_PREVIEW_URL" >> `+log+`; sleep 30`, 10*time.Second)
+
+	albums := []model.Album{{CID: "a1", Name: "First"}}
+	m := newAlbumPickerModel(context.Background(), albums, nil, pickerOptions{player: player}, nil)
+	defer m.stopPlayback()
+	songs := []model.Song{{CID: "s1", Name: "One"}, {CID: "s2", Name: "Two"}}
+	m.songsCache[0] = songs
+	m.openAlbumDetails(0, false)
+	m.sourceURLs["s2"] = "https://cdn/two.wav"
+	m.detailCursor = 1
+
+	l := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")}
+	if _, cmd := m.Update(l); cmd == nil {
+		t.Fatalf("expected a playback tick after starting the preview")
+	}
+	if m.playingCID != "s2" {
+		t.Fatalf("playingCID = %q, want s2", m.playingCID)
+	}
+	if line := m.playbackLine(); !strings.Contains(line, "Playing: Two") {
+		t.Fatalf("playback line = %q", line)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := os.ReadFile(log)
+		if strings.TrimSpace(string(b)) == "https://cdn/two.wav" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("player was not started with the source URL, log: %q", b)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	m.Update(l)
+	if m.playingCID != "" {
+		t.Fatalf("expected a second l to stop playback")
+	}
+	if _, _, playing := player.Status(); playing {
+		t.Fatalf("player still running after stop")
+	}
+}
+
+func TestPickerPlaybackWithoutSource(t *testing.T) {
+	player := preview.New("true", time.Second)
+	m := newAlbumPickerModel(context.Background(), []model.Album{{CID: "a1", Name: "First"}}, nil, pickerOptions{player: player}, nil)
+	m.songsCache[0] = []model.Song{{CID: "s1", Name: "One"}}
+	m.openAlbumDetails(0, false)
+
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	if m.playingCID != "" || !strings.Contains(m.playbackLine(), "API client") {
+		t.Fatalf("expected an unavailable-source notice, got %q", m.playbackLine())
+	}
+}
+
+func TestPickerDropsStaleSongSource(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "played.log")
+	player := preview.New(`echo "$MSR_PREVIEW_URL" >> `+log+`; sleep 30`, 10*time.Second)
+	albums := []model.Album{{CID: "a1", Name: "First"}, {CID: "a2", Name: "Second"}}
+	m := newAlbumPickerModel(context.Background(), albums, nil, pickerOptions{player: player}, nil)
+	defer m.stopPlayback()
+	m.songsCache[0] = []model.Song{{CID: "s1", Name: "One"}, {CID: "s2", Name: "Two"}}
+	m.songsCache[1] = []model.Song{{CID: "s3", Name: "Three"}}
+	m.openAlbumDetails(0, false)
+
+	// The cursor moved to another song while the source resolved.
+	m.detailCursor = 1
+	m.handleSongSource(songSourceMsg{albumIdx: 0, song: model.Song{CID: "s1", Name: "One"}, url: "https://cdn/one.wav"})
+	if m.playingCID != "" {
+		t.Fatalf("stale source started playback of %q", m.playingCID)
+	}
+	if m.sourceURLs["s1"] != "https://cdn/one.wav" {
+		t.Fatalf("resolved source URL was not remembered")
+	}
+
+	// The user switched albums while the source resolved.
+	m.openAlbumDetails(1, false)
+	m.handleSongSource(songSourceMsg{albumIdx: 0, song: model.Song{CID: "s1", Name: "One"}, url: "https://cdn/one.wav"})
+	if m.playingCID != "" {
+		t.Fatalf("source from another album started playback of %q", m.playingCID)
+	}
+
+	if cmd := m.handleSongSource(songSourceMsg{albumIdx: 1, song: model.Song{CID: "s3", Name: "Three"}, url: "https://cdn/three.wav"}); cmd == nil || m.playingCID != "s3" {
+		t.Fatalf("source of the focused song did not start playback")
+	}
+}
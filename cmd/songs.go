@@ -0,0 +1,27 @@
+package main
+
+import (
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/query"
+)
+
+// songPicks maps album CIDs to the song CIDs chosen in the picker. Albums
+// without an entry are archived in full.
+type songPicks map[string]map[string]struct{}
+
+// songPlan decides which tracks of an album are archived. The --songs
+// selector applies to every album; picker choices narrow individual albums.
+type songPlan struct {
+	selector query.SongSelector
+	picks    songPicks
+}
+
+// includes reports whether song, at 1-based position track, is planned.
+func (p songPlan) includes(album model.Album, track int, song model.Song) bool {
+	if picked, ok := p.picks[album.CID]; ok {
+		if _, ok := picked[song.CID]; !ok {
+			return false
+		}
+	}
+	return p.selector.Match(track, song)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/query"
+)
+
+func TestPickerToggleSong(t *testing.T) {
+	albums := []model.Album{{CID: "a1", Name: "First"}, {CID: "a2", Name: "Second"}}
+	songs := []model.Song{{CID: "s1"}, {CID: "s2"}, {CID: "s3"}}
+	m := newAlbumPickerModel(context.Background(), albums, nil, pickerOptions{}, nil)
+
+	m.toggleSong(0, songs, 1)
+	if _, ok := m.selected[0]; !ok {
+		t.Fatalf("picking a song should select its album")
+	}
+	picks := m.selectedSongPicks()
+	if len(picks["a1"]) != 1 {
+		t.Fatalf("expected one picked song, got %v", picks)
+	}
+
+	m.toggleSong(0, songs, 0)
+	m.toggleSong(0, songs, 2)
+	if _, partial := m.songPicks[0]; partial {
+		t.Fatalf("picking every song should select the full album")
+	}
+
+	m.toggleSong(0, songs, 0)
+	if !m.songSelected(0, "s2") || m.songSelected(0, "s1") {
+		t.Fatalf("unpicking a song of a full album should keep the others")
+	}
+	m.toggleSong(0, songs, 1)
+	m.toggleSong(0, songs, 2)
+	if _, ok := m.selected[0]; ok {
+		t.Fatalf("unpicking every song should deselect the album")
+	}
+
+	m.toggleSong(1, songs, 0)
+	m.toggleSelection(1)
+	m.toggleSelection(1)
+	if m.selectedSongPicks() != nil {
+		t.Fatalf("toggling the album should clear song picks")
+	}
+}
+
+func TestSongPlanIncludes(t *testing.T) {
+	selector, err := query.ParseSongs("1-2")
+	if err != nil {
+		t.Fatalf("ParseSongs: %v", err)
+	}
+	plan := songPlan{
+		selector: selector,
+		picks:    songPicks{"a1": {"s2": {}}},
+	}
+	a1, a2 := model.Album{CID: "a1"}, model.Album{CID: "a2"}
+
+	if plan.includes(a1, 1, model.Song{CID: "s1"}) {
+		t.Fatalf("song outside picker choice should be excluded")
+	}
+	if !plan.includes(a1, 2, model.Song{CID: "s2"}) {
+		t.Fatalf("picked song within --songs should be included")
+	}
+	if !plan.includes(a2, 1, model.Song{CID: "x"}) || plan.includes(a2, 3, model.Song{CID: "y"}) {
+		t.Fatalf("--songs should apply to albums without picker choices")
+	}
+}
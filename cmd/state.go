@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"msr-archiver/internal/config"
+	"msr-archiver/internal/librarydb"
+	"msr-archiver/internal/logging"
+	"msr-archiver/internal/state"
+)
+
+const (
+	stateBackendSQLite = "sqlite"
+	stateBackendJSON   = "json"
+)
+
+// completionState records albums archived in full.
+type completionState interface {
+	IsCompleted(albumName string) bool
+	MarkCompleted(albumName string) error
+}
+
+// libraryState records archived tracks by album CID.
+type libraryState interface {
+	Album(cid string) (state.LibraryAlbum, bool)
+	Albums() []state.LibraryAlbum
+	PutAlbum(album state.LibraryAlbum) error
+}
+
+// archiveState is the completion and library state of a run. db is set for
+// the SQLite backend and also records run history.
+type archiveState struct {
+	completion completionState
+	library    libraryState
+	db         *librarydb.DB
+}
+
+// Close releases the library database, if any.
+func (s archiveState) Close() {
+	if s.db != nil {
+		_ = s.db.Close()
+	}
+}
+
+func resolveLibraryDBPath(cfg config.Config) string {
+	if strings.TrimSpace(cfg.LibraryDB) != "" {
+		return cfg.LibraryDB
+	}
+	return filepath.Join(cfg.OutputDir, librarydb.FileName)
+}
+
+// jsonImportSources are the JSON state files of an output directory.
+func jsonImportSources(cfg config.Config) librarydb.ImportSources {
+	return librarydb.ImportSources{
+		CompletedAlbums: filepath.Join(cfg.OutputDir, "completed_albums.json"),
+		Library:         filepath.Join(cfg.OutputDir, "library.json"),
+		AlbumCache:      resolveAlbumCachePath(cfg),
+	}
+}
+
+// openArchiveState opens the state backend selected by --state. A new
+// library database imports the JSON state files of the output directory.
+func openArchiveState(ctx context.Context, cfg config.Config, logger *logging.Logger) (archiveState, error) {
+	switch cfg.State {
+	case stateBackendJSON:
+		return openJSONState(cfg)
+	case stateBackendSQLite:
+	default:
+		return archiveState{}, fmt.Errorf("invalid --state %q: want %s or %s", cfg.State, stateBackendSQLite, stateBackendJSON)
+	}
+
+	db, err := librarydb.Open(ctx, resolveLibraryDBPath(cfg))
+	if err != nil {
+		return archiveState{}, err
+	}
+	if db.Created() {
+		stats, err := db.ImportJSON(ctx, jsonImportSources(cfg))
+		if err != nil {
+			db.Close()
+			_ = librarydb.Remove(db.Path())
+			return archiveState{}, fmt.Errorf("import JSON state into %s: %w", db.Path(), err)
+		}
+		if !stats.Empty() {
+			logger.Infof(
+				"Imported JSON state into %s: %d completed albums, %d archived albums, %d catalog albums, %d songs",
+				db.Path(), stats.Completed, stats.Archived, stats.Catalog, stats.Songs,
+			)
+		}
+	}
+	return archiveState{completion: db, library: db, db: db}, nil
+}
+
+// openReadOnlyState opens state for inspection commands without creating or
+// migrating anything. Without a library database the JSON files are read.
+func openReadOnlyState(ctx context.Context, cfg config.Config) (archiveState, error) {
+	if cfg.State == stateBackendSQLite {
+		path := resolveLibraryDBPath(cfg)
+		if _, err := os.Stat(path); err == nil {
+			db, err := librarydb.OpenReadOnly(ctx, path)
+			if err != nil {
+				return archiveState{}, err
+			}
+			return archiveState{completion: db, library: db, db: db}, nil
+		}
+	}
+	return openJSONState(cfg)
+}
+
+func openJSONState(cfg config.Config) (archiveState, error) {
+	store, err := state.NewStore(filepath.Join(cfg.OutputDir, "completed_albums.json"))
+	if err != nil {
+		return archiveState{}, fmt.Errorf("initialize completion state: %w", err)
+	}
+	lib, err := state.NewLibraryStore(filepath.Join(cfg.OutputDir, "library.json"))
+	if err != nil {
+		return archiveState{}, fmt.Errorf("initialize library state: %w", err)
+	}
+	return archiveState{completion: store, library: lib}, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"msr-archiver/internal/api"
+	"msr-archiver/internal/catalog"
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/watch"
+)
+
+// runWatch polls the catalog on an interval and archives new albums and
+// tracks added to recent albums until ctx is canceled.
+func runWatch(ctx context.Context, r *albumRunner, albumCache *catalog.Cache) error {
+	quiet, err := watch.ParseQuietHours(r.cfg.QuietHours)
+	if err != nil {
+		return err
+	}
+
+	status := watch.NewStatus(time.Now())
+	if r.cfg.HealthAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", status)
+		srv := &http.Server{Addr: r.cfg.HealthAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				r.logger.Errorf("Health endpoint failed: %v", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(shutdownCtx)
+		}()
+		r.logger.Infof("Health endpoint listening on http://%s/healthz", r.cfg.HealthAddr)
+	}
+
+	snapshot := catalog.NewCache(filepath.Join(r.cfg.OutputDir, watchSnapshotFile))
+	prev, _, err := snapshot.Load()
+	if err != nil {
+		// First watch run: albums already in the catalog cache are not new.
+		prev, _, _ = albumCache.Load()
+	}
+
+	r.logger.Infof("Watching catalog every %s", r.cfg.WatchInterval)
+	for {
+		if wait := quiet.Until(time.Now()); wait > 0 {
+			r.logger.Infof("Quiet hours active; next check in %s", wait.Round(time.Second))
+			status.SetNextCheck(time.Now().Add(wait))
+			if !sleepContext(ctx, wait) {
+				return nil
+			}
+			continue
+		}
+
+		status.BeginCheck()
+		checkStarted := time.Now().UTC()
+		albums, next, archived, checkErr := r.watchOnce(ctx, albumCache, prev)
+		if err := snapshot.Save(next); err != nil {
+			r.logger.Warnf("Persist watch snapshot failed: %v", err)
+		}
+		prev = next
+		if ctx.Err() != nil {
+			return nil
+		}
+		if checkErr != nil {
+			r.logger.Errorf("Catalog check failed: %v", checkErr)
+		}
+		if archived > 0 && r.cfg.Playlists {
+			writeLibraryIndex(r.cfg, r.logger, albums, r.library, checkStarted)
+		}
+
+		nextAt := time.Now().Add(r.cfg.WatchInterval)
+		status.EndCheck(time.Now(), archived, checkErr, nextAt)
+		r.logger.Infof("Next catalog check at %s", nextAt.Local().Format(time.RFC3339))
+		if !sleepContext(ctx, r.cfg.WatchInterval) {
+			return nil
+		}
+	}
+}
+
+// watchSnapshotFile holds the catalog as of the last watch check, minus
+// albums that appeared since and are not archived yet.
+const watchSnapshotFile = "watch_snapshot.json"
+
+// watchOnce refreshes the catalog, archives albums missing from the snapshot
+// prev that are not completed, and rechecks the most recent albums for added
+// tracks. Albums that were in prev but never archived were skipped on
+// purpose and are left alone. It returns the refreshed catalog, the next
+// snapshot and the number of albums that received new tracks. The next
+// snapshot leaves out albums that still failed, so they are retried on
+// later checks and after a restart. A failed catalog fetch returns prev.
+func (r *albumRunner) watchOnce(ctx context.Context, albumCache *catalog.Cache, prev []model.Album) ([]model.Album, []model.Album, int, error) {
+	albums, err := fetchAlbums(ctx, r.logger, r.apiClient)
+	if err != nil {
+		return nil, prev, 0, err
+	}
+	persistAlbums(r.logger, albumCache, albums)
+	r.recordCatalog(ctx, albums)
+
+	diff := catalog.Compare(prev, albums)
+	for _, a := range diff.Removed {
+		r.logger.Warnf("Album removed from catalog: %s (%s)", a.Name, a.CID)
+	}
+
+	cfg := r.cfg
+	added := make(map[string]struct{}, len(diff.Added))
+	var tasks []albumTask
+	for _, album := range diff.Added {
+		album := album
+		added[album.CID] = struct{}{}
+		if r.store.IsCompleted(album.Name) {
+			continue
+		}
+		r.logger.Infof("Album not archived yet: %s (%s)", album.Name, album.CID)
+		tasks = append(tasks, albumTask{album: album})
+	}
+
+	for i, album := range albums {
+		if i >= cfg.WatchRecheck {
+			break
+		}
+		if _, ok := added[album.CID]; ok || !r.store.IsCompleted(album.Name) {
+			continue
+		}
+		missing, err := r.missingTracks(ctx, album)
+		if err != nil {
+			r.logger.Warnf("[%s] Recheck songs failed: %v", album.Name, err)
+			continue
+		}
+		if missing > 0 {
+			r.logger.Infof("[%s] %d track(s) added since last archive", album.Name, missing)
+			tasks = append(tasks, albumTask{album: album, refresh: true})
+		}
+	}
+
+	if len(tasks) == 0 {
+		r.logger.Infof("No catalog changes")
+		return albums, r.watchSnapshot(albums, added), 0, nil
+	}
+
+	// Song counts are not known yet, so small-first keeps catalog order.
+	priorities, err := jobPriorities(cfg.JobOrder, tasks, nil)
+	if err != nil {
+		return albums, r.watchSnapshot(albums, added), 0, err
+	}
+	report, err := r.runAlbums(ctx, tasks, priorities)
+	return albums, r.watchSnapshot(albums, added), report.archived, err
+}
+
+// watchSnapshot returns albums without the added ones that are still not
+// completed.
+func (r *albumRunner) watchSnapshot(albums []model.Album, added map[string]struct{}) []model.Album {
+	out := make([]model.Album, 0, len(albums))
+	for _, album := range albums {
+		if _, ok := added[album.CID]; ok && !r.store.IsCompleted(album.Name) {
+			continue
+		}
+		out = append(out, album)
+	}
+	return out
+}
+
+// missingTracks counts songs of a completed album that are not recorded in
+// library state.
+func (r *albumRunner) missingTracks(ctx context.Context, album model.Album) (int, error) {
+	songs, err := withRetryResult(ctx, 3, func() ([]model.Song, error) {
+		// Bypass the response cache TTL so new tracks show up promptly.
+		return r.apiClient.GetAlbumSongs(api.Revalidate(ctx), album.CID)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	rec, ok := r.library.Album(album.CID)
+	if !ok {
+		// Archived before library state existed; nothing to compare against.
+		return 0, nil
+	}
+	known := make(map[string]struct{}, len(rec.Tracks))
+	for _, t := range rec.Tracks {
+		known[t.CID] = struct{}{}
+	}
+
+	missing := 0
+	for _, s := range songs {
+		if _, ok := known[s.CID]; !ok {
+			missing++
+		}
+	}
+	return missing, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"msr-archiver/internal/api"
+	"msr-archiver/internal/catalog"
+	"msr-archiver/internal/config"
+	"msr-archiver/internal/logging"
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func newWatchTestRunner(t *testing.T, handler roundTripFunc) *albumRunner {
+	t.Helper()
+	store, err := state.NewStore(filepath.Join(t.TempDir(), "completed_albums.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &albumRunner{
+		cfg:       config.Config{JobOrder: jobOrderListed},
+		logger:    logging.NewWithWriter(io.Discard),
+		apiClient: api.New(&http.Client{Transport: handler}),
+		store:     store,
+	}
+}
+
+func TestWatchOnceReportsFetchFailure(t *testing.T) {
+	r := newWatchTestRunner(t, func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	prev := []model.Album{{CID: "a1", Name: "One"}}
+	cache := catalog.NewCache(filepath.Join(t.TempDir(), "albums_cache.json"))
+	_, next, _, err := r.watchOnce(context.Background(), cache, prev)
+	if err == nil {
+		t.Fatalf("expected the fetch failure to be reported")
+	}
+	if len(next) != 1 || next[0].CID != "a1" {
+		t.Fatalf("snapshot after a failed fetch = %+v, want prev", next)
+	}
+}
+
+func TestWatchSnapshotKeepsUnarchivedAlbumsPending(t *testing.T) {
+	r := newWatchTestRunner(t, nil)
+	if err := r.store.MarkCompleted("Two"); err != nil {
+		t.Fatal(err)
+	}
+	albums := []model.Album{{CID: "a1", Name: "One"}, {CID: "a2", Name: "Two"}, {CID: "a3", Name: "Three"}}
+	added := map[string]struct{}{"a2": {}, "a3": {}}
+
+	next := r.watchSnapshot(albums, added)
+	if len(next) != 2 || next[0].CID != "a1" || next[1].CID != "a2" {
+		t.Fatalf("snapshot = %+v, want a1 and a2", next)
+	}
+	// The pending album is diffed as new again against the snapshot.
+	if diff := catalog.Compare(next, albums); len(diff.Added) != 1 || diff.Added[0].CID != "a3" {
+		t.Fatalf("added against snapshot = %+v", diff.Added)
+	}
+}
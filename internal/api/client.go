@@ -3,22 +3,112 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
+	"msr-archiver/internal/httpcache"
 	"msr-archiver/internal/model"
 )
 
 const baseURL = "https://monster-siren.hypergryph.com/api"
 
+// ErrBudgetExhausted is returned once a client has sent Options.Budget
+// requests.
+var ErrBudgetExhausted = errors.New("API request budget exhausted")
+
+// Options configures request pacing. The zero value sends requests
+// unthrottled and without a budget.
+type Options struct {
+	// Rate is the number of requests per second; Burst requests may be
+	// sent at once after an idle period.
+	Rate  float64
+	Burst int
+	// Budget caps the requests sent by the client. Zero is unlimited.
+	Budget int
+
+	// Cache stores album detail and song detail responses. Within their
+	// TTL cached responses are used without a request; afterwards they are
+	// revalidated with If-None-Match / If-Modified-Since. A TTL of zero
+	// revalidates every time.
+	Cache          *httpcache.Store
+	AlbumDetailTTL time.Duration
+	SongDetailTTL  time.Duration
+}
+
+// Stats counts the requests of a client.
+type Stats struct {
+	Requests     int
+	Throttled    int
+	ServerErrors int
+	Failed       int
+	Budget       int
+	// CacheHits were answered from the response cache without a request;
+	// Revalidated were confirmed unchanged by a 304 response.
+	CacheHits   int
+	Revalidated int
+	// Waited is the total time requests spent waiting for the limiter.
+	Waited time.Duration
+	// Rate and LowestRate are the current and lowest request rates, zero
+	// when unlimited.
+	Rate       float64
+	LowestRate float64
+}
+
+// StatusError reports a non-2xx response.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is the delay requested by the server, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request %s: unexpected status %d", e.URL, e.StatusCode)
+}
+
 // Client wraps calls to Monster Siren API.
 type Client struct {
 	httpClient *http.Client
+	limiter    *Limiter
+	budget     int
+	cache      *httpcache.Store
+	albumTTL   time.Duration
+	songTTL    time.Duration
+
+	mu    sync.Mutex
+	stats Stats
 }
 
 // New creates an API client.
 func New(httpClient *http.Client) *Client {
-	return &Client{httpClient: httpClient}
+	return NewWithOptions(httpClient, Options{})
+}
+
+// NewWithOptions creates an API client that paces its requests.
+func NewWithOptions(httpClient *http.Client, opts Options) *Client {
+	return &Client{
+		httpClient: httpClient,
+		limiter:    NewLimiter(opts.Rate, opts.Burst),
+		budget:     opts.Budget,
+		cache:      opts.Cache,
+		albumTTL:   opts.AlbumDetailTTL,
+		songTTL:    opts.SongDetailTTL,
+	}
+}
+
+// Stats returns the request counts so far.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	stats := c.stats
+	c.mu.Unlock()
+	stats.Budget = c.budget
+	stats.Rate, stats.LowestRate = c.limiter.Rate()
+	return stats
 }
 
 type apiResp[T any] struct {
@@ -33,7 +123,7 @@ type albumDetail struct {
 func (c *Client) GetAlbums(ctx context.Context) ([]model.Album, error) {
 	url := fmt.Sprintf("%s/albums", baseURL)
 	var out apiResp[[]model.Album]
-	if err := c.getJSON(ctx, url, &out); err != nil {
+	if err := c.getJSON(ctx, url, &out, nil); err != nil {
 		return nil, err
 	}
 	return out.Data, nil
@@ -43,7 +133,7 @@ func (c *Client) GetAlbums(ctx context.Context) ([]model.Album, error) {
 func (c *Client) GetAlbumSongs(ctx context.Context, albumCID string) ([]model.Song, error) {
 	url := fmt.Sprintf("%s/album/%s/detail", baseURL, albumCID)
 	var out apiResp[albumDetail]
-	if err := c.getJSON(ctx, url, &out); err != nil {
+	if err := c.getJSON(ctx, url, &out, &c.albumTTL); err != nil {
 		return nil, err
 	}
 	return out.Data.Songs, nil
@@ -53,32 +143,140 @@ func (c *Client) GetAlbumSongs(ctx context.Context, albumCID string) ([]model.So
 func (c *Client) GetSongDetail(ctx context.Context, songCID string) (model.SongDetail, error) {
 	url := fmt.Sprintf("%s/song/%s", baseURL, songCID)
 	var out apiResp[model.SongDetail]
-	if err := c.getJSON(ctx, url, &out); err != nil {
+	if err := c.getJSON(ctx, url, &out, &c.songTTL); err != nil {
 		return model.SongDetail{}, err
 	}
 	return out.Data, nil
 }
 
-func (c *Client) getJSON(ctx context.Context, url string, v any) error {
+type revalidateKey struct{}
+
+// Revalidate returns a context whose requests revalidate cached responses
+// even within their TTL, for checks that must see upstream changes.
+func Revalidate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revalidateKey{}, true)
+}
+
+// getJSON decodes the response of url into v. With a ttl and a cache, the
+// response is served from and stored in the cache.
+func (c *Client) getJSON(ctx context.Context, url string, v any, ttl *time.Duration) error {
+	var cached httpcache.Entry
+	var hasCached bool
+	cacheable := ttl != nil && c.cache != nil
+	if cacheable {
+		cached, hasCached = c.cache.Get(url)
+		revalidate, _ := ctx.Value(revalidateKey{}).(bool)
+		if hasCached && !revalidate && cached.Fresh(*ttl, time.Now()) {
+			if err := json.Unmarshal(cached.Body, v); err == nil {
+				c.record(func(s *Stats) { s.CacheHits++ })
+				return nil
+			}
+			hasCached = false
+		}
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Accept", "application/json")
+	if hasCached && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	if hasCached && cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	if err := c.reserve(); err != nil {
+		return fmt.Errorf("request %s: %w", url, err)
+	}
+	waited, err := c.limiter.Wait(ctx)
+	c.record(func(s *Stats) { s.Waited += waited })
+	if err != nil {
+		return fmt.Errorf("request %s: %w", url, err)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		if ctx.Err() == nil {
+			c.record(func(s *Stats) { s.Failed++ })
+			c.limiter.Backoff(0)
+		}
 		return fmt.Errorf("request %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		c.limiter.Recover()
+		if err := json.Unmarshal(cached.Body, v); err != nil {
+			return fmt.Errorf("decode cached %s: %w", url, err)
+		}
+		c.record(func(s *Stats) { s.Revalidated++ })
+		_ = c.cache.Touch(cached, time.Now())
+		return nil
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("request %s: unexpected status %d", url, resp.StatusCode)
+		statusErr := &StatusError{URL: url, StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			c.record(func(s *Stats) { s.Throttled++ })
+			c.limiter.Backoff(statusErr.RetryAfter)
+		case resp.StatusCode >= 500:
+			c.record(func(s *Stats) { s.ServerErrors++ })
+			c.limiter.Backoff(statusErr.RetryAfter)
+		}
+		return statusErr
 	}
+	c.limiter.Recover()
 
-	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", url, err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
 		return fmt.Errorf("decode %s: %w", url, err)
 	}
 
+	if cacheable && resp.StatusCode == http.StatusOK {
+		// A failed cache write only costs a request next time.
+		_ = c.cache.Put(httpcache.Entry{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			StoredAt:     time.Now().UTC(),
+			Body:         body,
+		})
+	}
+	return nil
+}
+
+// reserve counts a request against the budget.
+func (c *Client) reserve() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.budget > 0 && c.stats.Requests >= c.budget {
+		return ErrBudgetExhausted
+	}
+	c.stats.Requests++
 	return nil
 }
+
+func (c *Client) record(update func(*Stats)) {
+	c.mu.Lock()
+	update(&c.stats)
+	c.mu.Unlock()
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(0, time.Duration(secs)*time.Second)
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(0, at.Sub(now))
+	}
+	return 0
+}
@@ -2,10 +2,14 @@ package api
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
+
+	"msr-archiver/internal/httpcache"
 )
 
 type roundTripFunc func(*http.Request) (*http.Response, error)
@@ -102,3 +106,138 @@ func TestGetJSONDecodeError(t *testing.T) {
 		t.Fatalf("expected decode error, got %v", err)
 	}
 }
+
+func TestClientCountsThrottledAndFailedRequests(t *testing.T) {
+	statuses := []int{429, 503, 200}
+	calls := 0
+	client := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := response(statuses[calls], `{"data":[]}`)
+		calls++
+		return resp, nil
+	})}, Options{Rate: 1000, Burst: 10})
+
+	_, err := client.GetAlbums(context.Background())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 429 {
+		t.Fatalf("first call error = %v, want a 429 StatusError", err)
+	}
+	client.GetAlbums(context.Background())
+	if _, err := client.GetAlbums(context.Background()); err != nil {
+		t.Fatalf("third call failed: %v", err)
+	}
+
+	stats := client.Stats()
+	if stats.Requests != 3 || stats.Throttled != 1 || stats.ServerErrors != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats.LowestRate != 250 || stats.Rate <= stats.LowestRate {
+		t.Fatalf("rate = %v (lowest %v), want a backoff to 250 and partial recovery", stats.Rate, stats.LowestRate)
+	}
+}
+
+func TestClientStopsAtBudget(t *testing.T) {
+	client := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return response(200, `{"data":[]}`), nil
+	})}, Options{Budget: 2})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetAlbums(context.Background()); err != nil {
+			t.Fatalf("call %d failed: %v", i+1, err)
+		}
+	}
+	if _, err := client.GetAlbums(context.Background()); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("error = %v, want ErrBudgetExhausted", err)
+	}
+	if stats := client.Stats(); stats.Requests != 2 || stats.Budget != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"7":                             7 * time.Second,
+		"soon":                          0,
+		"Fri, 02 Jan 2026 03:04:35 GMT": 30 * time.Second,
+		"Fri, 02 Jan 2026 03:00:00 GMT": 0,
+	}
+	for in, want := range cases {
+		if got := parseRetryAfter(in, now); got != want {
+			t.Fatalf("parseRetryAfter(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestClientServesSongDetailFromCache(t *testing.T) {
+	calls := 0
+	client := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		resp := response(200, `{"data":{"sourceUrl":"https://x/song.wav"}}`)
+		resp.Header.Set("ETag", `"v1"`)
+		return resp, nil
+	})}, Options{Cache: httpcache.New(t.TempDir()), SongDetailTTL: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		detail, err := client.GetSongDetail(context.Background(), "s1")
+		if err != nil || detail.SourceURL != "https://x/song.wav" {
+			t.Fatalf("GetSongDetail = %+v, %v", detail, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("requests = %d, want 1 with a fresh cache", calls)
+	}
+	if stats := client.Stats(); stats.CacheHits != 2 || stats.Requests != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestClientRevalidatesStaleEntries(t *testing.T) {
+	var conditional []string
+	client := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if inm := req.Header.Get("If-None-Match"); inm != "" {
+			conditional = append(conditional, inm)
+			return response(http.StatusNotModified, ""), nil
+		}
+		resp := response(200, `{"data":{"songs":[{"cid":"s1","name":"Song"}]}}`)
+		resp.Header.Set("ETag", `"v1"`)
+		return resp, nil
+	})}, Options{Cache: httpcache.New(t.TempDir()), AlbumDetailTTL: 0})
+
+	for i := 0; i < 2; i++ {
+		songs, err := client.GetAlbumSongs(context.Background(), "a1")
+		if err != nil || len(songs) != 1 || songs[0].CID != "s1" {
+			t.Fatalf("GetAlbumSongs = %+v, %v", songs, err)
+		}
+	}
+	if len(conditional) != 1 || conditional[0] != `"v1"` {
+		t.Fatalf("conditional requests = %v, want one with the stored ETag", conditional)
+	}
+	if stats := client.Stats(); stats.Revalidated != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestClientRevalidateContextBypassesTTL(t *testing.T) {
+	calls := 0
+	client := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		body := `{"data":{"songs":[{"cid":"s1"}]}}`
+		if calls > 1 {
+			body = `{"data":{"songs":[{"cid":"s1"},{"cid":"s2"}]}}`
+		}
+		return response(200, body), nil
+	})}, Options{Cache: httpcache.New(t.TempDir()), AlbumDetailTTL: time.Hour})
+
+	client.GetAlbumSongs(context.Background(), "a1")
+	songs, err := client.GetAlbumSongs(Revalidate(context.Background()), "a1")
+	if err != nil || len(songs) != 2 {
+		t.Fatalf("revalidated GetAlbumSongs = %+v, %v", songs, err)
+	}
+	if songs, _ := client.GetAlbumSongs(context.Background(), "a1"); len(songs) != 2 {
+		t.Fatalf("cache was not updated by the revalidated response")
+	}
+	if calls != 2 {
+		t.Fatalf("requests = %d, want 2", calls)
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// minRateDivisor bounds backoff: the rate never drops below the
+	// configured rate divided by this.
+	minRateDivisor = 16
+	// recoverSteps is the number of successful requests it takes to climb
+	// from a backed-off rate back to the configured one, at most.
+	recoverSteps = 10
+	// maxRetryAfter caps pauses requested by Retry-After headers.
+	maxRetryAfter = 2 * time.Minute
+)
+
+// Limiter is a token bucket shared by all requests of a Client. Throttled
+// and failing responses halve the rate and honour Retry-After; successful
+// ones raise it back towards the configured rate.
+type Limiter struct {
+	mu          sync.Mutex
+	base        float64
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	minSeen     float64
+}
+
+// NewLimiter allows rate requests per second with bursts of burst requests.
+// A rate of zero or less disables limiting and returns nil.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		base:    rate,
+		rate:    rate,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+		minSeen: rate,
+	}
+}
+
+// Wait blocks until a request may be sent and returns how long it waited.
+func (l *Limiter) Wait(ctx context.Context) (time.Duration, error) {
+	if l == nil {
+		return 0, ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.refill(now)
+	l.tokens--
+	delay := time.Duration(0)
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	if pause := l.pausedUntil.Sub(now); pause > delay {
+		delay = pause
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return 0, ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return 0, ctx.Err()
+	case <-timer.C:
+		return delay, nil
+	}
+}
+
+// Backoff halves the rate after a throttled or failed response and pauses
+// all requests for retryAfter when the server asked for it.
+func (l *Limiter) Backoff(retryAfter time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.refill(now)
+	l.rate = max(l.rate/2, l.base/minRateDivisor)
+	l.minSeen = min(l.minSeen, l.rate)
+	if retryAfter > 0 {
+		until := now.Add(min(retryAfter, maxRetryAfter))
+		if until.After(l.pausedUntil) {
+			l.pausedUntil = until
+		}
+	}
+}
+
+// Recover raises a backed-off rate after a successful response.
+func (l *Limiter) Recover() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate < l.base {
+		l.refill(time.Now())
+		l.rate = min(l.base, l.rate+l.base/recoverSteps)
+	}
+}
+
+// Rate returns the current and the lowest rate so far in requests per
+// second. A nil Limiter reports zero.
+func (l *Limiter) Rate() (current, lowest float64) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate, l.minSeen
+}
+
+// refill adds the tokens earned since the last update. Callers hold l.mu.
+func (l *Limiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(l.burst, l.tokens+elapsed.Seconds()*l.rate)
+		l.last = now
+	}
+}
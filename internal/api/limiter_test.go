@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterPacesRequestsAfterBurst(t *testing.T) {
+	l := NewLimiter(100, 2)
+	started := time.Now()
+	for i := 0; i < 6; i++ {
+		if _, err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	// Two requests use the burst; the other four wait 10ms each.
+	if elapsed := time.Since(started); elapsed < 35*time.Millisecond {
+		t.Fatalf("6 requests at 100/s with burst 2 took %s", elapsed)
+	}
+}
+
+func TestLimiterBacksOffAndRecovers(t *testing.T) {
+	l := NewLimiter(10, 1)
+	l.Backoff(0)
+	l.Backoff(0)
+	if rate, lowest := l.Rate(); rate != 2.5 || lowest != 2.5 {
+		t.Fatalf("rate after two backoffs = %v (lowest %v), want 2.5", rate, lowest)
+	}
+	for i := 0; i < 100; i++ {
+		l.Backoff(0)
+	}
+	if rate, _ := l.Rate(); rate != 10.0/minRateDivisor {
+		t.Fatalf("rate floor = %v, want %v", rate, 10.0/minRateDivisor)
+	}
+	for i := 0; i < recoverSteps; i++ {
+		l.Recover()
+	}
+	if rate, _ := l.Rate(); rate != 10 {
+		t.Fatalf("rate after recovery = %v, want 10", rate)
+	}
+}
+
+func TestLimiterHonoursRetryAfter(t *testing.T) {
+	l := NewLimiter(1000, 10)
+	l.Backoff(50 * time.Millisecond)
+	waited, err := l.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if waited < 40*time.Millisecond {
+		t.Fatalf("waited %s, want the Retry-After pause", waited)
+	}
+}
+
+func TestLimiterWaitCanceled(t *testing.T) {
+	l := NewLimiter(1, 1)
+	l.Wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx); err == nil {
+		t.Fatalf("expected the context error")
+	}
+}
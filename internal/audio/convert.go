@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // CheckFFmpeg verifies ffmpeg is available.
@@ -26,3 +30,39 @@ func WAVToFLAC(ctx context.Context, wavPath, flacPath string) error {
 	}
 	return nil
 }
+
+// StreamWAVToFLAC encodes WAV data read from r into flacPath with ffmpeg. An
+// error reading r fails the conversion even if ffmpeg accepted the
+// truncated input.
+func StreamWAVToFLAC(ctx context.Context, r io.Reader, flacPath string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-f", "wav", "-i", "pipe:0", "-vn", "-compression_level", "12", "-f", "flac", flacPath)
+	cmd.Stdin = r
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg wav->flac stream failed: %w: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// ProbeDuration returns the media duration reported by ffprobe.
+func ProbeDuration(ctx context.Context, path string) (time.Duration, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("ffprobe duration failed: %w: %s", err, stderr.String())
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(stdout.String()), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse ffprobe duration %q: %w", strings.TrimSpace(stdout.String()), err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -5,14 +5,24 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"msr-archiver/internal/model"
 )
 
-// Cache persists fetched album catalog data.
+// Cache persists fetched album catalog data and album song lists.
+//
+// The song lists duplicate album detail responses in the API response
+// cache on purpose. That cache decides when a list is fresh enough to
+// download from and may be disabled or expire; the copy here never
+// expires, so the picker preview, preflight estimates and small-first job
+// order work offline and without spending API requests.
 type Cache struct {
 	path string
+
+	// mu serializes writes, which rewrite the whole file.
+	mu sync.Mutex
 }
 
 // NewCache creates an album catalog cache at a target path.
@@ -21,22 +31,24 @@ func NewCache(path string) *Cache {
 }
 
 type payload struct {
-	FetchedAt string        `json:"fetchedAt"`
-	Albums    []model.Album `json:"albums"`
+	FetchedAt string                 `json:"fetchedAt"`
+	Albums    []model.Album          `json:"albums"`
+	Songs     map[string]cachedSongs `json:"songs,omitempty"`
+}
+
+// cachedSongs is an album song list keyed by album CID in the cache file.
+type cachedSongs struct {
+	FetchedAt time.Time    `json:"fetchedAt"`
+	Songs     []model.Song `json:"songs"`
 }
 
 // Load reads cached albums. If the file does not exist, os.ErrNotExist is returned.
 func (c *Cache) Load() ([]model.Album, time.Time, error) {
-	b, err := os.ReadFile(c.path)
+	p, err := c.read()
 	if err != nil {
 		return nil, time.Time{}, err
 	}
 
-	var p payload
-	if err := json.Unmarshal(b, &p); err != nil {
-		return nil, time.Time{}, fmt.Errorf("parse album cache %s: %w", c.path, err)
-	}
-
 	var fetchedAt time.Time
 	if p.FetchedAt != "" {
 		parsed, err := time.Parse(time.RFC3339, p.FetchedAt)
@@ -49,12 +61,74 @@ func (c *Cache) Load() ([]model.Album, time.Time, error) {
 	return p.Albums, fetchedAt, nil
 }
 
-// Save writes album catalog data atomically.
+// Save writes album catalog data atomically. Cached song lists of albums
+// still in the catalog are kept.
 func (c *Cache) Save(albums []model.Album) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	p := payload{
 		FetchedAt: time.Now().UTC().Format(time.RFC3339),
 		Albums:    albums,
 	}
+	if prev, err := c.read(); err == nil && len(prev.Songs) > 0 {
+		p.Songs = make(map[string]cachedSongs)
+		for _, a := range albums {
+			if songs, ok := prev.Songs[a.CID]; ok {
+				p.Songs[a.CID] = songs
+			}
+		}
+	}
+	return c.write(p)
+}
+
+// LoadSongs returns cached song lists keyed by album CID. A missing cache
+// yields no songs.
+func (c *Cache) LoadSongs() (map[string][]model.Song, error) {
+	p, err := c.read()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	out := make(map[string][]model.Song, len(p.Songs))
+	for cid, s := range p.Songs {
+		out[cid] = s.Songs
+	}
+	return out, nil
+}
+
+// PutSongs records the song list of one album. The catalog must have been
+// saved first.
+func (c *Cache) PutSongs(albumCID string, songs []model.Song) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, err := c.read()
+	if err != nil {
+		return err
+	}
+	if p.Songs == nil {
+		p.Songs = make(map[string]cachedSongs)
+	}
+	p.Songs[albumCID] = cachedSongs{FetchedAt: time.Now().UTC(), Songs: songs}
+	return c.write(p)
+}
+
+func (c *Cache) read() (payload, error) {
+	b, err := os.ReadFile(c.path)
+	if err != nil {
+		return payload{}, err
+	}
+	var p payload
+	if err := json.Unmarshal(b, &p); err != nil {
+		return payload{}, fmt.Errorf("parse album cache %s: %w", c.path, err)
+	}
+	return p, nil
+}
+
+func (c *Cache) write(p payload) error {
 	b, err := json.MarshalIndent(p, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshal album cache: %w", err)
@@ -75,3 +75,35 @@ func TestCacheSaveWritesRecentFetchedAt(t *testing.T) {
 		t.Fatalf("unexpected stale fetchedAt: %s", fetchedAt)
 	}
 }
+
+func TestCacheSongsSurviveCatalogRefresh(t *testing.T) {
+	cache := NewCache(filepath.Join(t.TempDir(), "albums_cache.json"))
+
+	songs, err := cache.LoadSongs()
+	if err != nil || songs != nil {
+		t.Fatalf("LoadSongs on missing cache = %v, %v; want nil, nil", songs, err)
+	}
+
+	albums := []model.Album{{CID: "a1", Name: "Alpha"}, {CID: "a2", Name: "Beta"}}
+	if err := cache.Save(albums); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := cache.PutSongs("a1", []model.Song{{CID: "s1", Name: "One"}}); err != nil {
+		t.Fatalf("PutSongs failed: %v", err)
+	}
+	if err := cache.PutSongs("a2", []model.Song{{CID: "s2", Name: "Two"}}); err != nil {
+		t.Fatalf("PutSongs failed: %v", err)
+	}
+
+	// a2 left the catalog; its songs are dropped, a1's are kept.
+	if err := cache.Save(albums[:1]); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	songs, err = cache.LoadSongs()
+	if err != nil {
+		t.Fatalf("LoadSongs failed: %v", err)
+	}
+	if len(songs) != 1 || len(songs["a1"]) != 1 || songs["a1"][0].CID != "s1" {
+		t.Fatalf("unexpected cached songs: %+v", songs)
+	}
+}
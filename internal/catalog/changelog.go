@@ -0,0 +1,161 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"msr-archiver/internal/model"
+)
+
+// Changelog entry kinds for albums entering or leaving the catalog.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+)
+
+// ChangeEntry is one line of the append-only catalog changelog.
+type ChangeEntry struct {
+	At   time.Time `json:"at"`
+	Kind string    `json:"kind"`
+	CID  string    `json:"cid"`
+	Name string    `json:"name"`
+	Old  string    `json:"old,omitempty"`
+	New  string    `json:"new,omitempty"`
+}
+
+// Entries flattens a diff into changelog entries stamped with at.
+func (d Diff) Entries(at time.Time) []ChangeEntry {
+	at = at.UTC()
+	var out []ChangeEntry
+	for _, a := range d.Added {
+		out = append(out, ChangeEntry{At: at, Kind: ChangeAdded, CID: a.CID, Name: a.Name})
+	}
+	for _, a := range d.Removed {
+		out = append(out, ChangeEntry{At: at, Kind: ChangeRemoved, CID: a.CID, Name: a.Name})
+	}
+	for _, c := range d.Changed {
+		for _, kind := range c.Kinds {
+			e := ChangeEntry{At: at, Kind: kind, CID: c.New.CID, Name: c.New.Name}
+			switch kind {
+			case ChangeRenamed:
+				e.Old, e.New = c.Old.Name, c.New.Name
+			case ChangeCover:
+				e.Old, e.New = c.Old.CoverURL, c.New.CoverURL
+			case ChangeArtists:
+				e.Old, e.New = strings.Join(c.Old.Artistes, ", "), strings.Join(c.New.Artistes, ", ")
+			}
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ChangelogPath returns the changelog file stored next to the cache file.
+func (c *Cache) ChangelogPath() string {
+	dir := filepath.Dir(c.path)
+	base := strings.TrimSuffix(filepath.Base(c.path), filepath.Ext(c.path))
+	return filepath.Join(dir, base+"_changelog.jsonl")
+}
+
+// ErrUnreadableSnapshot reports that Refresh replaced a cached snapshot it
+// could not read, so no changes were logged.
+var ErrUnreadableSnapshot = errors.New("previous album cache unreadable; changes not logged")
+
+// Refresh diffs albums against the cached snapshot, appends the changes to the
+// changelog and saves albums as the new snapshot. Without a previous snapshot
+// nothing is logged. An unreadable snapshot is overwritten all the same, and
+// Refresh then returns an error matching ErrUnreadableSnapshot.
+func (c *Cache) Refresh(albums []model.Album) (Diff, error) {
+	prev, _, err := c.Load()
+	hasPrev := err == nil
+	var loadErr error
+	if err != nil && !os.IsNotExist(err) {
+		loadErr = fmt.Errorf("%w: %v", ErrUnreadableSnapshot, err)
+	}
+
+	var d Diff
+	if hasPrev {
+		d = Compare(prev, albums)
+		if err := c.appendChangelog(d.Entries(time.Now())); err != nil {
+			return d, err
+		}
+	}
+
+	if err := c.Save(albums); err != nil {
+		return d, err
+	}
+	return d, loadErr
+}
+
+func (c *Cache) appendChangelog(entries []ChangeEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("marshal changelog entry: %w", err)
+		}
+	}
+
+	path := c.ChangelogPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create changelog parent dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open changelog %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("append changelog %s: %w", path, err)
+	}
+	return nil
+}
+
+// Changes reads changelog entries recorded at or after since. A missing
+// changelog yields no entries.
+func (c *Cache) Changes(since time.Time) ([]ChangeEntry, error) {
+	path := c.ChangelogPath()
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open changelog %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var out []ChangeEntry
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var e ChangeEntry
+		if err := json.Unmarshal([]byte(text), &e); err != nil {
+			return nil, fmt.Errorf("parse changelog %s line %d: %w", path, line, err)
+		}
+		if e.At.Before(since) {
+			continue
+		}
+		out = append(out, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read changelog %s: %w", path, err)
+	}
+	return out, nil
+}
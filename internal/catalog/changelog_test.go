@@ -0,0 +1,96 @@
+package catalog
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"msr-archiver/internal/model"
+)
+
+func TestRefreshWithoutSnapshotLogsNothing(t *testing.T) {
+	cache := NewCache(filepath.Join(t.TempDir(), "albums_cache.json"))
+
+	d, err := cache.Refresh([]model.Album{{CID: "a1", Name: "Alpha"}})
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if !d.Empty() {
+		t.Fatalf("first refresh should not report changes: %+v", d)
+	}
+	if _, err := os.Stat(cache.ChangelogPath()); !os.IsNotExist(err) {
+		t.Fatalf("changelog should not exist yet, err=%v", err)
+	}
+}
+
+func TestRefreshAppendsChangelog(t *testing.T) {
+	cache := NewCache(filepath.Join(t.TempDir(), "albums_cache.json"))
+	if err := cache.Save([]model.Album{{CID: "a1", Name: "Alpha"}, {CID: "a2", Name: "Beta"}}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	if _, err := cache.Refresh([]model.Album{{CID: "a1", Name: "Alpha (Remaster)"}, {CID: "a3", Name: "Gamma"}}); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if _, err := cache.Refresh([]model.Album{{CID: "a1", Name: "Alpha (Remaster)"}, {CID: "a3", Name: "Gamma"}}); err != nil {
+		t.Fatalf("second Refresh failed: %v", err)
+	}
+
+	entries, err := cache.Changes(before)
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	kinds := map[string]ChangeEntry{}
+	for _, e := range entries {
+		kinds[e.Kind] = e
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	if kinds[ChangeAdded].CID != "a3" || kinds[ChangeRemoved].CID != "a2" {
+		t.Fatalf("unexpected added/removed entries: %+v", entries)
+	}
+	if e := kinds[ChangeRenamed]; e.Old != "Alpha" || e.New != "Alpha (Remaster)" {
+		t.Fatalf("unexpected rename entry: %+v", e)
+	}
+
+	later, err := cache.Changes(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	if len(later) != 0 {
+		t.Fatalf("expected no entries after since, got %+v", later)
+	}
+}
+
+func TestRefreshReplacesCorruptSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "albums_cache.json")
+	if err := os.WriteFile(path, []byte(`{"fetchedAt":"2026-01-0`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache(path)
+
+	d, err := cache.Refresh([]model.Album{{CID: "a1", Name: "Alpha"}})
+	if !errors.Is(err, ErrUnreadableSnapshot) || !d.Empty() {
+		t.Fatalf("Refresh = %+v, %v; want an empty diff and ErrUnreadableSnapshot", d, err)
+	}
+	if albums, _, err := cache.Load(); err != nil || len(albums) != 1 {
+		t.Fatalf("snapshot not replaced: %+v, %v", albums, err)
+	}
+	if _, err := os.Stat(cache.ChangelogPath()); !os.IsNotExist(err) {
+		t.Fatalf("changelog written for an unreadable snapshot")
+	}
+	if _, err := cache.Refresh([]model.Album{{CID: "a1", Name: "Alpha"}}); err != nil {
+		t.Fatalf("Refresh after recovery failed: %v", err)
+	}
+}
+
+func TestChangelogPath(t *testing.T) {
+	cache := NewCache(filepath.Join("out", "albums_cache.json"))
+	if got := cache.ChangelogPath(); got != filepath.Join("out", "albums_cache_changelog.jsonl") {
+		t.Fatalf("unexpected changelog path: %s", got)
+	}
+}
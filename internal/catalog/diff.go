@@ -0,0 +1,75 @@
+package catalog
+
+import (
+	"slices"
+
+	"msr-archiver/internal/model"
+)
+
+// Change kinds recorded for albums present in both snapshots.
+const (
+	ChangeRenamed = "renamed"
+	ChangeCover   = "cover_changed"
+	ChangeArtists = "artists_changed"
+)
+
+// AlbumChange describes how one album differs between snapshots.
+type AlbumChange struct {
+	Old   model.Album
+	New   model.Album
+	Kinds []string
+}
+
+// Diff lists catalog changes between two snapshots.
+type Diff struct {
+	Added   []model.Album
+	Removed []model.Album
+	Changed []AlbumChange
+}
+
+// Empty reports whether the snapshots contain the same albums.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Compare diffs two catalog snapshots by album CID. Added and changed albums
+// keep the order of next; removed albums keep the order of prev.
+func Compare(prev, next []model.Album) Diff {
+	prevByCID := make(map[string]model.Album, len(prev))
+	for _, a := range prev {
+		prevByCID[a.CID] = a
+	}
+	nextByCID := make(map[string]struct{}, len(next))
+	for _, a := range next {
+		nextByCID[a.CID] = struct{}{}
+	}
+
+	var d Diff
+	for _, a := range next {
+		old, ok := prevByCID[a.CID]
+		if !ok {
+			d.Added = append(d.Added, a)
+			continue
+		}
+
+		var kinds []string
+		if old.Name != a.Name {
+			kinds = append(kinds, ChangeRenamed)
+		}
+		if old.CoverURL != a.CoverURL {
+			kinds = append(kinds, ChangeCover)
+		}
+		if !slices.Equal(old.Artistes, a.Artistes) {
+			kinds = append(kinds, ChangeArtists)
+		}
+		if len(kinds) > 0 {
+			d.Changed = append(d.Changed, AlbumChange{Old: old, New: a, Kinds: kinds})
+		}
+	}
+	for _, a := range prev {
+		if _, ok := nextByCID[a.CID]; !ok {
+			d.Removed = append(d.Removed, a)
+		}
+	}
+	return d
+}
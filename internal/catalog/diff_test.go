@@ -0,0 +1,44 @@
+package catalog
+
+import (
+	"testing"
+
+	"msr-archiver/internal/model"
+)
+
+func TestCompare(t *testing.T) {
+	prev := []model.Album{{CID: "a1"}, {CID: "a2"}}
+	next := []model.Album{{CID: "a3"}, {CID: "a1"}}
+
+	d := Compare(prev, next)
+	if len(d.Added) != 1 || d.Added[0].CID != "a3" {
+		t.Fatalf("unexpected added albums: %+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].CID != "a2" {
+		t.Fatalf("unexpected removed albums: %+v", d.Removed)
+	}
+	if d.Empty() {
+		t.Fatalf("diff should not be empty")
+	}
+}
+
+func TestCompareIdentical(t *testing.T) {
+	albums := []model.Album{{CID: "a1"}}
+	if d := Compare(albums, albums); !d.Empty() {
+		t.Fatalf("expected empty diff, got %+v", d)
+	}
+}
+
+func TestCompareDetectsChangedAlbums(t *testing.T) {
+	prev := []model.Album{{CID: "a1", Name: "Old", CoverURL: "https://c/1", Artistes: []string{"A"}}}
+	next := []model.Album{{CID: "a1", Name: "New", CoverURL: "https://c/2", Artistes: []string{"A", "B"}}}
+
+	d := Compare(prev, next)
+	if len(d.Changed) != 1 {
+		t.Fatalf("expected one changed album, got %+v", d.Changed)
+	}
+	kinds := d.Changed[0].Kinds
+	if len(kinds) != 3 || kinds[0] != ChangeRenamed || kinds[1] != ChangeCover || kinds[2] != ChangeArtists {
+		t.Fatalf("unexpected change kinds: %v", kinds)
+	}
+}
@@ -2,52 +2,201 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"runtime"
+	"strings"
 	"time"
 )
 
 // Config contains runtime options for the downloader.
 type Config struct {
-	OutputDir      string
-	Workers        int
-	HTTPTimeout    time.Duration
-	Albums         string
-	ChooseAlbums   bool
-	RefreshAlbums  bool
-	AlbumCachePath string
-	AlbumCacheTTL  time.Duration
+	OutputDir           string
+	Workers             int
+	FailFast            bool
+	JobOrder            string
+	HTTPTimeout         time.Duration
+	APIRate             float64
+	APIBurst            int
+	APIBudget           int
+	DialTimeout         time.Duration
+	TLSTimeout          time.Duration
+	HeaderTimeout       time.Duration
+	IdleConnTimeout     time.Duration
+	StallTimeout        time.Duration
+	MinTransferRate     string
+	ResumeAttempts      int
+	MaxConnsPerHost     int
+	Proxy               string
+	CAFile              string
+	UserAgent           string
+	Albums              string
+	Songs               string
+	SelectionPreset     string
+	ChooseAlbums        bool
+	SearchTransliterate bool
+	PreviewCommand      string
+	PreviewLength       time.Duration
+	RefreshAlbums       bool
+	AlbumCachePath      string
+	State               string
+	LibraryDB           string
+	AlbumCacheTTL       time.Duration
+	APICache            bool
+	APICacheDir         string
+	AlbumDetailTTL      time.Duration
+	SongDetailTTL       time.Duration
+	CoverMaxSize        int
+	CoverFormat         string
+	CoverQuality        int
+	FolderJPG           bool
+	Loudness            string
+	Playlists           bool
+	NewPlaylist         bool
+	Validate            bool
+	StreamConvert       bool
+	MinFreeSpace        string
+	MaxLibrarySize      string
+	Preflight           bool
+	PreflightHead       bool
+	Watch               bool
+	WatchInterval       time.Duration
+	WatchRecheck        int
+	QuietHours          string
+	HealthAddr          string
+	Webhooks            string
+	NotifyCommand       string
+	NotifyEvents        string
+	NotifyAttempts      int
+	NotifyTimeout       time.Duration
 }
 
-// Parse reads CLI flags into Config.
-func Parse() Config {
-	defaultWorkers := runtime.NumCPU()
-	if defaultWorkers < 2 {
-		defaultWorkers = 2
+// ParseArgs resolves Config from args with precedence
+// flag > env > profile > file > default. getenv is used for MSR_* lookups.
+// Positional arguments are rejected.
+func ParseArgs(name string, args []string, getenv func(string) string) (Config, Effective, error) {
+	cfg, eff, rest, err := ParseCommand(name, args, getenv, nil)
+	if err != nil {
+		return Config{}, Effective{}, err
 	}
+	if len(rest) > 0 {
+		return Config{}, Effective{}, fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+	return cfg, eff, nil
+}
 
-	outputDir := flag.String("output", "./MonsterSiren", "output directory")
-	workers := flag.Int("workers", defaultWorkers, "number of concurrent album workers")
-	httpTimeout := flag.Duration("http-timeout", 2*time.Minute, "HTTP request timeout")
-	albums := flag.String("albums", "", "comma-separated album names or CIDs to download")
-	chooseAlbums := flag.Bool("choose-albums", true, "interactively choose albums to download (default: true; set --choose-albums=false to download all)")
-	refreshAlbums := flag.Bool("refresh-albums", false, "fetch album catalog from API and update cache")
-	albumCachePath := flag.String("album-cache", "", "album cache file path (default: <output>/albums_cache.json)")
-	albumCacheTTL := flag.Duration("album-cache-ttl", 24*time.Hour, "album cache max age before refresh (0 or negative disables TTL)")
+// ParseCommand is ParseArgs for subcommands: extra registers command-specific
+// flags on the same FlagSet, and positional arguments are returned.
+func ParseCommand(name string, args []string, getenv func(string) string, extra func(*flag.FlagSet)) (Config, Effective, []string, error) {
+	var cfg Config
+	var configPath, profile string
 
-	flag.Parse()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	bindFlags(fs, &cfg)
+	fs.StringVar(&configPath, "config", "", "config file path (TOML or YAML; default: $XDG_CONFIG_HOME/msr-archiver/config.toml)")
+	fs.StringVar(&profile, "profile", "", "named profile from the config file")
+	commandFlags := make(map[string]struct{})
+	if extra != nil {
+		before := make(map[string]struct{})
+		fs.VisitAll(func(f *flag.Flag) { before[f.Name] = struct{}{} })
+		extra(fs)
+		fs.VisitAll(func(f *flag.Flag) {
+			if _, ok := before[f.Name]; !ok {
+				commandFlags[f.Name] = struct{}{}
+			}
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, Effective{}, nil, err
+	}
 
-	if *workers < 1 {
-		*workers = 1
+	eff, err := applyLayers(fs, configPath, profile, getenv, commandFlags)
+	if err != nil {
+		return Config{}, Effective{}, nil, err
 	}
 
-	return Config{
-		OutputDir:      *outputDir,
-		Workers:        *workers,
-		HTTPTimeout:    *httpTimeout,
-		Albums:         *albums,
-		ChooseAlbums:   *chooseAlbums,
-		RefreshAlbums:  *refreshAlbums,
-		AlbumCachePath: *albumCachePath,
-		AlbumCacheTTL:  *albumCacheTTL,
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
 	}
+	if cfg.WatchInterval < time.Minute {
+		cfg.WatchInterval = time.Minute
+	}
+
+	return cfg, eff, fs.Args(), nil
+}
+
+// FlagNames returns the shared option names, for shell completion.
+func FlagNames() []string {
+	var cfg Config
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	bindFlags(fs, &cfg)
+	names := []string{"config", "profile"}
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	return names
+}
+
+func bindFlags(fs *flag.FlagSet, cfg *Config) {
+	defaultWorkers := runtime.NumCPU()
+	if defaultWorkers < 2 {
+		defaultWorkers = 2
+	}
+
+	fs.StringVar(&cfg.OutputDir, "output", "./MonsterSiren", "output directory")
+	fs.IntVar(&cfg.Workers, "workers", defaultWorkers, "number of concurrent album workers")
+	fs.BoolVar(&cfg.FailFast, "fail-fast", false, "stop starting albums and cancel running ones after the first album fails")
+	fs.StringVar(&cfg.JobOrder, "job-order", "listed", "order albums start in: listed (selection or --albums order) or small-first (fewest tracks first)")
+	fs.DurationVar(&cfg.HTTPTimeout, "http-timeout", 2*time.Minute, "overall timeout of API requests; downloads are bounded by --stall-timeout instead")
+	fs.Float64Var(&cfg.APIRate, "api-rate", 4, "API requests per second, slowed down automatically on 429/5xx responses (0 disables)")
+	fs.IntVar(&cfg.APIBurst, "api-burst", 4, "API requests allowed at once after an idle period")
+	fs.IntVar(&cfg.APIBudget, "api-budget", 0, "stop after this many API requests in one run (0 is unlimited)")
+	fs.DurationVar(&cfg.DialTimeout, "dial-timeout", 10*time.Second, "TCP connect timeout")
+	fs.DurationVar(&cfg.TLSTimeout, "tls-timeout", 10*time.Second, "TLS handshake timeout")
+	fs.DurationVar(&cfg.HeaderTimeout, "header-timeout", 30*time.Second, "timeout waiting for response headers after a request is sent")
+	fs.DurationVar(&cfg.IdleConnTimeout, "idle-conn-timeout", 90*time.Second, "how long idle pooled connections are kept")
+	fs.DurationVar(&cfg.StallTimeout, "stall-timeout", 30*time.Second, "abort a transfer that receives no data for this long (0 disables)")
+	fs.StringVar(&cfg.MinTransferRate, "min-transfer-rate", "32KiB", "slowest acceptable average download rate per second; bounds each transfer to --stall-timeout plus size/rate (0 disables)")
+	fs.IntVar(&cfg.ResumeAttempts, "resume-attempts", 3, "resume a stalled download with a Range request up to this many times")
+	fs.IntVar(&cfg.MaxConnsPerHost, "max-conns-per-host", 0, "maximum concurrent connections per host (0 is unlimited)")
+	fs.StringVar(&cfg.Proxy, "proxy", "", "proxy URL (http://, https:// or socks5://; default: HTTP_PROXY/HTTPS_PROXY environment)")
+	fs.StringVar(&cfg.CAFile, "ca-file", "", "PEM bundle of extra certificate authorities to trust")
+	fs.StringVar(&cfg.UserAgent, "user-agent", "msr-archiver", "User-Agent header sent with every request")
+	fs.StringVar(&cfg.Albums, "albums", "", "album selection query: names/CIDs, re:, artist:, new, latest:N, not:, @file (comma-separated)")
+	fs.StringVar(&cfg.Songs, "songs", "", "songs to archive within each album: track numbers or ranges (1-3,7), song CIDs or title substrings (comma-separated)")
+	fs.StringVar(&cfg.SelectionPreset, "selection-preset", "", "download a selection preset saved from the picker review screen")
+	fs.BoolVar(&cfg.ChooseAlbums, "choose-albums", true, "interactively choose albums to download (default: true; set --choose-albums=false to download all)")
+	fs.BoolVar(&cfg.SearchTransliterate, "search-transliterate", true, "let the picker filter match Chinese titles by pinyin and Japanese kana by romaji")
+	fs.StringVar(&cfg.PreviewCommand, "preview-command", "", "shell command that plays song previews in the picker, reading MSR_PREVIEW_URL, MSR_PREVIEW_START and MSR_PREVIEW_DURATION (default: ffplay)")
+	fs.DurationVar(&cfg.PreviewLength, "preview-length", 30*time.Second, "length of the picker song preview from the start of the track")
+	fs.BoolVar(&cfg.RefreshAlbums, "refresh-albums", false, "fetch album catalog from API and update cache")
+	fs.StringVar(&cfg.State, "state", "sqlite", "archive state backend: sqlite (library database) or json (completed_albums.json and library.json)")
+	fs.StringVar(&cfg.LibraryDB, "library-db", "", "library database path (default: <output>/library.db)")
+	fs.StringVar(&cfg.AlbumCachePath, "album-cache", "", "album cache file path (default: <output>/albums_cache.json)")
+	fs.DurationVar(&cfg.AlbumCacheTTL, "album-cache-ttl", 24*time.Hour, "album cache max age before refresh (0 or negative disables TTL)")
+	fs.BoolVar(&cfg.APICache, "api-cache", true, "cache album detail and song detail API responses on disk")
+	fs.StringVar(&cfg.APICacheDir, "api-cache-dir", "", "API response cache directory (default: <output>/api_cache)")
+	fs.DurationVar(&cfg.AlbumDetailTTL, "album-detail-ttl", 24*time.Hour, "reuse cached album song lists for this long before revalidating (0 always revalidates)")
+	fs.DurationVar(&cfg.SongDetailTTL, "song-detail-ttl", 24*time.Hour, "reuse cached song details for this long before revalidating (0 always revalidates)")
+	fs.IntVar(&cfg.CoverMaxSize, "cover-max-size", 1200, "maximum width/height in pixels of embedded cover art (0 keeps source size)")
+	fs.StringVar(&cfg.CoverFormat, "cover-format", "jpeg", "embedded cover art format: jpeg or png")
+	fs.IntVar(&cfg.CoverQuality, "cover-quality", 90, "JPEG quality (1-100) for embedded cover art and folder.jpg")
+	fs.BoolVar(&cfg.FolderJPG, "folder-jpg", true, "also write folder.jpg into each album directory")
+	fs.StringVar(&cfg.Loudness, "loudness", "off", "post-process albums with loudness analysis and REPLAYGAIN_* tags: off, replaygain (-18 LUFS) or r128 (-23 LUFS)")
+	fs.BoolVar(&cfg.Playlists, "playlists", true, "write per-album .m3u8 playlists and .cue sheets and index.json/index.html after each run")
+	fs.BoolVar(&cfg.NewPlaylist, "new-playlist", false, "also write new.m3u8 with tracks downloaded during this run")
+	fs.BoolVar(&cfg.Validate, "validate", true, "validate WAV headers and decode-check FLAC/MP3 output; invalid tracks are downloaded again")
+	fs.BoolVar(&cfg.StreamConvert, "stream-convert", true, "pipe WAV downloads straight into the FLAC encoder instead of writing the WAV to disk first")
+	fs.StringVar(&cfg.MinFreeSpace, "min-free-space", "1GiB", "free space to keep on the output filesystem; new tracks are not started below it (e.g. 5GiB, 0 disables)")
+	fs.StringVar(&cfg.MaxLibrarySize, "max-library-size", "", "stop starting new tracks once archived tracks reach this size (e.g. 500GiB; empty disables)")
+	fs.BoolVar(&cfg.Preflight, "preflight", true, "check estimated space of the selected albums against free space before downloading")
+	fs.BoolVar(&cfg.PreflightHead, "preflight-head", false, "size pending tracks with song detail and HEAD requests instead of library averages")
+	fs.BoolVar(&cfg.Watch, "watch", false, "run continuously, polling the catalog and archiving new albums and tracks")
+	fs.DurationVar(&cfg.WatchInterval, "watch-interval", time.Hour, "catalog polling interval in watch mode")
+	fs.IntVar(&cfg.WatchRecheck, "watch-recheck", 5, "number of most recent catalog albums rechecked for added tracks in watch mode")
+	fs.StringVar(&cfg.QuietHours, "quiet-hours", "", "daily local window without checks in watch mode, e.g. 01:00-07:00")
+	fs.StringVar(&cfg.HealthAddr, "health-addr", "127.0.0.1:8787", "watch-mode health endpoint address (empty disables)")
+	fs.StringVar(&cfg.Webhooks, "webhook", "", "comma-separated webhook URLs that receive run events as JSON POSTs")
+	fs.StringVar(&cfg.NotifyCommand, "notify-command", "", "shell command run per event with MSR_EVENT* environment variables")
+	fs.StringVar(&cfg.NotifyEvents, "notify-events", "", "comma-separated events to deliver: run_started, album_completed, album_failed, run_finished (default: all)")
+	fs.IntVar(&cfg.NotifyAttempts, "notify-attempts", 3, "delivery attempts per notification hook")
+	fs.DurationVar(&cfg.NotifyTimeout, "notify-timeout", 10*time.Second, "timeout per notification delivery attempt")
 }
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envFunc(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+const tomlConfig = `
+output = "/srv/msr"
+workers = 6
+http_timeout = "5m"
+choose-albums = false
+
+[profiles.ost-main]
+albums = ["A Walk in the Dust", "ab12cd34"]
+workers = 3
+`
+
+func TestParseArgsDefaults(t *testing.T) {
+	cfg, eff, err := ParseArgs("test", []string{"--config", writeConfig(t, "empty.toml", "")}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("ParseArgs failed: %v", err)
+	}
+	if cfg.OutputDir != "./MonsterSiren" || !cfg.ChooseAlbums || cfg.HTTPTimeout != 2*time.Minute {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+	for _, s := range eff.Settings {
+		if s.Source != SourceDefault {
+			t.Fatalf("expected only defaults, got %+v", s)
+		}
+	}
+}
+
+func TestParseArgsPrecedence(t *testing.T) {
+	path := writeConfig(t, "config.toml", tomlConfig)
+	env := envFunc(map[string]string{"MSR_WORKERS": "9", "MSR_PROFILE": "ost-main"})
+
+	cfg, eff, err := ParseArgs("test", []string{"--config", path, "--output", "/cli"}, env)
+	if err != nil {
+		t.Fatalf("ParseArgs failed: %v", err)
+	}
+	if cfg.OutputDir != "/cli" {
+		t.Fatalf("flag should win over file, got %q", cfg.OutputDir)
+	}
+	if cfg.Workers != 9 {
+		t.Fatalf("env should win over profile, got %d", cfg.Workers)
+	}
+	if cfg.Albums != "A Walk in the Dust,ab12cd34" {
+		t.Fatalf("profile list should be joined, got %q", cfg.Albums)
+	}
+	if cfg.HTTPTimeout != 5*time.Minute || cfg.ChooseAlbums {
+		t.Fatalf("file values not applied: %+v", cfg)
+	}
+	if eff.Profile != "ost-main" || eff.File != path {
+		t.Fatalf("unexpected effective metadata: %+v", eff)
+	}
+
+	sources := map[string]string{}
+	for _, s := range eff.Settings {
+		sources[s.Name] = s.Source
+	}
+	want := map[string]string{
+		"output":       SourceFlag,
+		"workers":      SourceEnv,
+		"albums":       SourceProfile,
+		"http-timeout": SourceFile,
+		"watch":        SourceDefault,
+	}
+	for name, source := range want {
+		if sources[name] != source {
+			t.Fatalf("source for %s = %q, want %q", name, sources[name], source)
+		}
+	}
+}
+
+func TestParseArgsProfileFlagOverridesEnv(t *testing.T) {
+	path := writeConfig(t, "config.toml", tomlConfig)
+	_, _, err := ParseArgs("test", []string{"--config", path, "--profile", "missing"}, envFunc(map[string]string{"MSR_PROFILE": "ost-main"}))
+	if err == nil || !strings.Contains(err.Error(), `profile "missing"`) {
+		t.Fatalf("expected missing profile error, got %v", err)
+	}
+}
+
+func TestParseArgsYAML(t *testing.T) {
+	path := writeConfig(t, "config.yaml", "output: /yaml\nprofile: fast\nprofiles:\n  fast:\n    workers: 12\n")
+	cfg, _, err := ParseArgs("test", []string{"--config", path}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("ParseArgs failed: %v", err)
+	}
+	if cfg.OutputDir != "/yaml" || cfg.Workers != 12 {
+		t.Fatalf("unexpected yaml config: %+v", cfg)
+	}
+}
+
+func TestParseArgsRejectsUnknownOption(t *testing.T) {
+	path := writeConfig(t, "config.toml", "outptu = \"/typo\"\n")
+	if _, _, err := ParseArgs("test", []string{"--config", path}, envFunc(nil)); err == nil {
+		t.Fatalf("expected unknown option error")
+	}
+}
+
+func TestParseArgsInvalidEnvValue(t *testing.T) {
+	path := writeConfig(t, "config.toml", "")
+	_, _, err := ParseArgs("test", []string{"--config", path}, envFunc(map[string]string{"MSR_WORKERS": "many"}))
+	if err == nil || !strings.Contains(err.Error(), "workers") {
+		t.Fatalf("expected invalid env value error, got %v", err)
+	}
+}
+
+func TestParseArgsMissingExplicitConfig(t *testing.T) {
+	_, _, err := ParseArgs("test", []string{"--config", filepath.Join(t.TempDir(), "nope.toml")}, envFunc(nil))
+	if err == nil {
+		t.Fatalf("expected error for missing explicit config file")
+	}
+}
+
+func TestEffectiveWriteTo(t *testing.T) {
+	eff := Effective{Settings: []Setting{{Name: "output", Value: "/x", Source: SourceFlag}}}
+	var buf bytes.Buffer
+	if _, err := eff.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "# config file: (none)") || !strings.Contains(out, `output = "/x"`) || !strings.Contains(out, "# flag") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("album-cache-ttl"); got != "MSR_ALBUM_CACHE_TTL" {
+		t.Fatalf("unexpected env name: %s", got)
+	}
+}
+
+func TestEnvNamesAvoidNotifyVariables(t *testing.T) {
+	// Notify command hooks export MSR_EVENT and MSR_EVENT_*; an option with
+	// such an env name would read them in a nested run.
+	var cfg Config
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	bindFlags(fs, &cfg)
+	fs.VisitAll(func(f *flag.Flag) {
+		if env := EnvName(f.Name); strings.HasPrefix(env, "MSR_EVENT") {
+			t.Errorf("option %q uses env name %s, reserved for notify hooks", f.Name, env)
+		}
+	})
+}
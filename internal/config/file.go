@@ -0,0 +1,307 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Setting sources reported by Effective.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceProfile = "profile"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting is one resolved option and where its value came from.
+type Setting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// Effective describes how a Config was resolved.
+type Effective struct {
+	File     string
+	Profile  string
+	Settings []Setting
+}
+
+// WriteTo prints the effective configuration, one option per line.
+func (e Effective) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	file := e.File
+	if file == "" {
+		file = "(none)"
+	}
+	profile := e.Profile
+	if profile == "" {
+		profile = "(none)"
+	}
+	fmt.Fprintf(&b, "# config file: %s\n# profile: %s\n", file, profile)
+
+	width := 0
+	for _, s := range e.Settings {
+		width = max(width, len(s.Name))
+	}
+	for _, s := range e.Settings {
+		fmt.Fprintf(&b, "%-*s = %-24q # %s\n", width, s.Name, s.Value, s.Source)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// EnvName returns the environment variable that overrides a flag.
+func EnvName(flagName string) string {
+	return "MSR_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// DefaultPath returns the XDG config file location, preferring an existing
+// config.toml, then config.yaml/config.yml.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	base := filepath.Join(dir, "msr-archiver")
+	for _, name := range []string{"config.toml", "config.yaml", "config.yml"} {
+		p := filepath.Join(base, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return filepath.Join(base, "config.toml")
+}
+
+// fileConfig holds top-level options and named profiles from a config file.
+type fileConfig struct {
+	values         map[string]string
+	profiles       map[string]map[string]string
+	defaultProfile string
+}
+
+// applyLayers fills flags that were not set on the command line from env,
+// profile and file values. Flags in skip are command-specific and are only
+// settable on the command line.
+func applyLayers(fs *flag.FlagSet, configPath, profile string, getenv func(string) string, skip map[string]struct{}) (Effective, error) {
+	explicit := make(map[string]struct{})
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = struct{}{} })
+
+	var eff Effective
+	path := configPath
+	required := path != ""
+	if path == "" {
+		path = getenv("MSR_CONFIG")
+		required = path != ""
+	}
+	if path == "" {
+		path = DefaultPath()
+	}
+
+	var fc fileConfig
+	if path != "" {
+		loaded, err := loadFile(path)
+		switch {
+		case err == nil:
+			fc = loaded
+			eff.File = path
+		case os.IsNotExist(err) && !required:
+		default:
+			return Effective{}, err
+		}
+	}
+
+	if profile == "" {
+		profile = getenv("MSR_PROFILE")
+	}
+	if profile == "" {
+		profile = fc.defaultProfile
+	}
+	var profileValues map[string]string
+	if profile != "" {
+		values, ok := fc.profiles[profile]
+		if !ok {
+			return Effective{}, fmt.Errorf("profile %q not found in config file %s", profile, displayPath(eff.File))
+		}
+		profileValues = values
+		eff.Profile = profile
+	}
+
+	known := make(map[string]struct{})
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := skip[f.Name]; !ok {
+			known[f.Name] = struct{}{}
+		}
+	})
+	for key := range fc.values {
+		if _, ok := known[key]; !ok {
+			return Effective{}, fmt.Errorf("config file %s: unknown option %q", eff.File, key)
+		}
+	}
+	for key := range profileValues {
+		if _, ok := known[key]; !ok {
+			return Effective{}, fmt.Errorf("config file %s: profile %q: unknown option %q", eff.File, profile, key)
+		}
+	}
+
+	var setErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if setErr != nil || f.Name == "config" || f.Name == "profile" {
+			return
+		}
+		if _, ok := skip[f.Name]; ok {
+			return
+		}
+		source := SourceDefault
+		if _, ok := explicit[f.Name]; ok {
+			source = SourceFlag
+		} else {
+			value, layer, ok := layeredValue(f.Name, fc.values, profileValues, getenv)
+			if ok {
+				if err := fs.Set(f.Name, value); err != nil {
+					setErr = fmt.Errorf("%s value for %s: %w", layer, f.Name, err)
+					return
+				}
+				source = layer
+			}
+		}
+		eff.Settings = append(eff.Settings, Setting{Name: f.Name, Value: f.Value.String(), Source: source})
+	})
+	if setErr != nil {
+		return Effective{}, setErr
+	}
+	return eff, nil
+}
+
+// layeredValue returns the highest-precedence non-flag value for name.
+func layeredValue(name string, file, profile map[string]string, getenv func(string) string) (string, string, bool) {
+	if v := getenv(EnvName(name)); v != "" {
+		return v, SourceEnv, true
+	}
+	if v, ok := profile[name]; ok {
+		return v, SourceProfile, true
+	}
+	if v, ok := file[name]; ok {
+		return v, SourceFile, true
+	}
+	return "", "", false
+}
+
+func loadFile(path string) (fileConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fileConfig{}, err
+		}
+		return fileConfig{}, fmt.Errorf("read config file %s: %w", path, err)
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(b, &raw); err != nil {
+			return fileConfig{}, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	default:
+		if err := toml.Unmarshal(b, &raw); err != nil {
+			return fileConfig{}, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	fc := fileConfig{profiles: make(map[string]map[string]string)}
+	for key, v := range raw {
+		switch normalizeKey(key) {
+		case "profiles":
+			profiles, ok := v.(map[string]any)
+			if !ok {
+				return fileConfig{}, fmt.Errorf("config file %s: profiles must be a table", path)
+			}
+			for name, pv := range profiles {
+				table, ok := pv.(map[string]any)
+				if !ok {
+					return fileConfig{}, fmt.Errorf("config file %s: profile %q must be a table", path, name)
+				}
+				values, err := flattenValues(table)
+				if err != nil {
+					return fileConfig{}, fmt.Errorf("config file %s: profile %q: %w", path, name, err)
+				}
+				fc.profiles[name] = values
+			}
+			delete(raw, key)
+		case "profile":
+			name, ok := v.(string)
+			if !ok {
+				return fileConfig{}, fmt.Errorf("config file %s: profile must be a string", path)
+			}
+			fc.defaultProfile = name
+			delete(raw, key)
+		}
+	}
+
+	values, err := flattenValues(raw)
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+	fc.values = values
+	return fc, nil
+}
+
+func flattenValues(table map[string]any) (map[string]string, error) {
+	out := make(map[string]string, len(table))
+	for key, v := range table {
+		s, err := scalarString(v)
+		if err != nil {
+			return nil, fmt.Errorf("option %q: %w", key, err)
+		}
+		out[normalizeKey(key)] = s
+	}
+	return out, nil
+}
+
+func scalarString(v any) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case int:
+		return strconv.Itoa(t), nil
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case []any:
+		parts := make([]string, 0, len(t))
+		for _, item := range t {
+			s, err := scalarString(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "_", "-")
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(none)"
+	}
+	return path
+}
@@ -0,0 +1,208 @@
+package cover
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// Supported encoder formats for embedded cover art.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// Options controls how album cover art is processed.
+type Options struct {
+	// MaxDimension caps the width and height of the embedded cover. Zero or
+	// negative keeps the source resolution.
+	MaxDimension int
+	// Format selects the embedded cover encoder: FormatJPEG or FormatPNG.
+	Format string
+	// JPEGQuality is used for JPEG outputs (1-100).
+	JPEGQuality int
+	// FolderJPG additionally writes folder.jpg for players that look for it.
+	FolderJPG bool
+}
+
+// Result lists the files produced for an album cover.
+type Result struct {
+	// FullPath is the full-resolution cover.* file in its source format.
+	FullPath string
+	// FolderPath is folder.jpg, or empty when disabled.
+	FolderPath string
+	// EmbedPath is the resized cover intended for embedding into tracks.
+	EmbedPath string
+	// Hash is the SHA-256 of the source image bytes.
+	Hash string
+}
+
+// Pipeline converts downloaded covers into their on-disk variants. Encoded
+// outputs are memoized by source hash so identical covers are encoded once.
+type Pipeline struct {
+	opts Options
+
+	mu     sync.Mutex
+	embeds map[string][]byte
+	folder map[string][]byte
+}
+
+// NewPipeline creates a cover Pipeline with normalized options.
+func NewPipeline(opts Options) (*Pipeline, error) {
+	opts.Format = strings.ToLower(strings.TrimSpace(opts.Format))
+	switch opts.Format {
+	case "", "jpg", FormatJPEG:
+		opts.Format = FormatJPEG
+	case FormatPNG:
+	default:
+		return nil, fmt.Errorf("unsupported cover format %q (want jpeg or png)", opts.Format)
+	}
+	if opts.JPEGQuality < 1 || opts.JPEGQuality > 100 {
+		opts.JPEGQuality = 90
+	}
+
+	return &Pipeline{
+		opts:   opts,
+		embeds: make(map[string][]byte),
+		folder: make(map[string][]byte),
+	}, nil
+}
+
+// EmbedExt returns the file extension used for embedded covers.
+func (p *Pipeline) EmbedExt() string {
+	if p.opts.Format == FormatPNG {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// Process reads the source image at srcPath and writes cover.*, folder.jpg and
+// the embedded cover variant into dir.
+func (p *Pipeline) Process(srcPath, dir string) (Result, error) {
+	raw, err := os.ReadFile(srcPath)
+	if err != nil {
+		return Result{}, fmt.Errorf("read cover source: %w", err)
+	}
+
+	sum := sha256.Sum256(raw)
+	hash := hex.EncodeToString(sum[:])
+
+	img, format, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return Result{}, fmt.Errorf("decode image: %w", err)
+	}
+
+	fullExt := ".jpg"
+	if format == "png" {
+		fullExt = ".png"
+	}
+	res := Result{
+		FullPath:  filepath.Join(dir, "cover"+fullExt),
+		EmbedPath: filepath.Join(dir, ".cover-embed"+p.EmbedExt()),
+		Hash:      hash,
+	}
+
+	if err := writeIfChanged(res.FullPath, raw); err != nil {
+		return Result{}, fmt.Errorf("write full-resolution cover: %w", err)
+	}
+
+	embed, err := p.encoded(p.embeds, hash, func() ([]byte, error) {
+		return encode(resize(img, p.opts.MaxDimension), p.opts.Format, p.opts.JPEGQuality)
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("encode embedded cover: %w", err)
+	}
+	if err := writeIfChanged(res.EmbedPath, embed); err != nil {
+		return Result{}, fmt.Errorf("write embedded cover: %w", err)
+	}
+
+	if p.opts.FolderJPG {
+		folder, err := p.encoded(p.folder, hash, func() ([]byte, error) {
+			if format == "jpeg" {
+				return raw, nil
+			}
+			return encode(img, FormatJPEG, p.opts.JPEGQuality)
+		})
+		if err != nil {
+			return Result{}, fmt.Errorf("encode folder.jpg: %w", err)
+		}
+		res.FolderPath = filepath.Join(dir, "folder.jpg")
+		if err := writeIfChanged(res.FolderPath, folder); err != nil {
+			return Result{}, fmt.Errorf("write folder.jpg: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+func (p *Pipeline) encoded(memo map[string][]byte, hash string, build func() ([]byte, error)) ([]byte, error) {
+	p.mu.Lock()
+	if b, ok := memo[hash]; ok {
+		p.mu.Unlock()
+		return b, nil
+	}
+	p.mu.Unlock()
+
+	b, err := build()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	memo[hash] = b
+	p.mu.Unlock()
+	return b, nil
+}
+
+func resize(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if maxDim <= 0 || (w <= maxDim && h <= maxDim) {
+		return img
+	}
+
+	nw, nh := maxDim, maxDim
+	if w > h {
+		nh = max(1, h*maxDim/w)
+	} else if h > w {
+		nw = max(1, w*maxDim/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case FormatPNG:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	default:
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeIfChanged skips rewriting files whose contents already match, so
+// reruns do not touch covers that were produced before.
+func writeIfChanged(path string, data []byte) error {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	return os.WriteFile(path, data, 0o644)
+}
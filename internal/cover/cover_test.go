@@ -0,0 +1,174 @@
+package cover
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write png: %v", err)
+	}
+}
+
+func decodeFile(t *testing.T, path string) (image.Image, string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	img, format, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return img, format
+}
+
+func TestProcessResizesEmbedAndKeepsFullResolution(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writePNG(t, src, 400, 200)
+
+	p, err := NewPipeline(Options{MaxDimension: 100, Format: "jpeg", JPEGQuality: 80, FolderJPG: true})
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+
+	res, err := p.Process(src, dir)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	if filepath.Base(res.FullPath) != "cover.png" {
+		t.Fatalf("unexpected full cover path: %s", res.FullPath)
+	}
+	full, _ := decodeFile(t, res.FullPath)
+	if full.Bounds().Dx() != 400 || full.Bounds().Dy() != 200 {
+		t.Fatalf("full cover should keep source size, got %v", full.Bounds())
+	}
+
+	embed, format := decodeFile(t, res.EmbedPath)
+	if format != "jpeg" {
+		t.Fatalf("expected jpeg embed, got %s", format)
+	}
+	if embed.Bounds().Dx() != 100 || embed.Bounds().Dy() != 50 {
+		t.Fatalf("unexpected embed size: %v", embed.Bounds())
+	}
+
+	if _, format := decodeFile(t, res.FolderPath); format != "jpeg" {
+		t.Fatalf("folder.jpg should be jpeg, got %s", format)
+	}
+}
+
+func TestProcessPNGWithoutFolder(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writePNG(t, src, 64, 64)
+
+	p, err := NewPipeline(Options{Format: "png"})
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	res, err := p.Process(src, dir)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if res.FolderPath != "" {
+		t.Fatalf("folder.jpg should be disabled, got %s", res.FolderPath)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "folder.jpg")); !os.IsNotExist(err) {
+		t.Fatalf("folder.jpg should not exist, err=%v", err)
+	}
+	if filepath.Ext(res.EmbedPath) != ".png" {
+		t.Fatalf("unexpected embed path: %s", res.EmbedPath)
+	}
+}
+
+func TestProcessReusesEncodedCoverForIdenticalSource(t *testing.T) {
+	dirA := t.TempDir()
+	dirB := t.TempDir()
+	srcA := filepath.Join(dirA, "src")
+	writePNG(t, srcA, 32, 32)
+	b, err := os.ReadFile(srcA)
+	if err != nil {
+		t.Fatalf("read source: %v", err)
+	}
+	srcB := filepath.Join(dirB, "src")
+	if err := os.WriteFile(srcB, b, 0o644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	p, err := NewPipeline(Options{Format: "jpeg"})
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	resA, err := p.Process(srcA, dirA)
+	if err != nil {
+		t.Fatalf("Process A failed: %v", err)
+	}
+	resB, err := p.Process(srcB, dirB)
+	if err != nil {
+		t.Fatalf("Process B failed: %v", err)
+	}
+	if resA.Hash != resB.Hash {
+		t.Fatalf("identical sources should share a hash")
+	}
+	if len(p.embeds) != 1 {
+		t.Fatalf("expected one memoized embed, got %d", len(p.embeds))
+	}
+}
+
+func TestProcessCopiesJPEGSourceToFolder(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	if err := os.WriteFile(src, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	p, err := NewPipeline(Options{FolderJPG: true})
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	res, err := p.Process(src, dir)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if filepath.Base(res.FullPath) != "cover.jpg" {
+		t.Fatalf("unexpected full cover path: %s", res.FullPath)
+	}
+	folder, err := os.ReadFile(res.FolderPath)
+	if err != nil {
+		t.Fatalf("read folder.jpg: %v", err)
+	}
+	if !bytes.Equal(folder, buf.Bytes()) {
+		t.Fatalf("folder.jpg should be a byte copy of a jpeg source")
+	}
+}
+
+func TestNewPipelineRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewPipeline(Options{Format: "webp"}); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+}
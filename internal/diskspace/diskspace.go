@@ -0,0 +1,184 @@
+// Package diskspace reports free space on the output filesystem and enforces
+// the library size and free space limits of a run.
+package diskspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned by Stat on platforms without a free space query.
+var ErrUnsupported = errors.New("free space query not supported on this platform")
+
+// ErrLimit is wrapped by Guard errors when a limit would be exceeded.
+var ErrLimit = errors.New("disk space limit reached")
+
+// Usage describes a filesystem.
+type Usage struct {
+	// Free is the space available to unprivileged users.
+	Free  int64
+	Total int64
+}
+
+// Stat returns usage of the filesystem holding path. A path that does not
+// exist yet is resolved to its nearest existing parent.
+func Stat(path string) (Usage, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return Usage{}, err
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	u, err := statfs(dir)
+	if err != nil {
+		return Usage{}, fmt.Errorf("stat filesystem of %s: %w", dir, err)
+	}
+	return u, nil
+}
+
+var sizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tib": 1 << 40,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+}
+
+// ParseSize parses sizes such as "500GiB", "1.5T" or "200MB". Single-letter
+// and *iB units are binary, *B units decimal. An empty string is zero.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	split := len(s)
+	for split > 0 && !(s[split-1] >= '0' && s[split-1] <= '9' || s[split-1] == '.') {
+		split--
+	}
+	num, unit := strings.TrimSpace(s[:split]), strings.ToLower(strings.TrimSpace(s[split:]))
+	mult, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, s[split:])
+	}
+	value, err := strconv.ParseFloat(num, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * float64(mult)), nil
+}
+
+// FormatSize renders n bytes with binary units, e.g. "1.5 GiB".
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	idx := 0
+	for value >= unit && idx < len(units)-1 {
+		value /= unit
+		idx++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[idx])
+}
+
+// Limits bounds disk usage of a run. Zero disables a limit.
+type Limits struct {
+	// MinFree is the free space that must remain on the output filesystem.
+	MinFree int64
+	// MaxLibrary caps the total size of archived tracks.
+	MaxLibrary int64
+}
+
+// Guard checks Limits before new work starts. It tracks the library size as
+// tracks are written and is safe for concurrent use. A nil Guard allows
+// everything.
+type Guard struct {
+	dir    string
+	limits Limits
+	stat   func(string) (Usage, error)
+
+	mu      sync.Mutex
+	library int64
+}
+
+// NewGuard creates a Guard for the filesystem holding dir. librarySize is the
+// size of the already archived library.
+func NewGuard(dir string, limits Limits, librarySize int64) *Guard {
+	return &Guard{dir: dir, limits: limits, stat: Stat, library: librarySize}
+}
+
+// Check returns an error wrapping ErrLimit if writing need more bytes would
+// break a limit. Platforms without a free space query only enforce
+// MaxLibrary.
+func (g *Guard) Check(need int64) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	library := g.library
+	g.mu.Unlock()
+
+	if g.limits.MaxLibrary > 0 && library+need > g.limits.MaxLibrary {
+		return fmt.Errorf("%w: library is %s and would exceed the %s maximum",
+			ErrLimit, FormatSize(library), FormatSize(g.limits.MaxLibrary))
+	}
+	if g.limits.MinFree <= 0 {
+		return nil
+	}
+	u, err := g.stat(g.dir)
+	if errors.Is(err, ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if u.Free-need < g.limits.MinFree {
+		return fmt.Errorf("%w: %s free, %s needed while keeping %s free",
+			ErrLimit, FormatSize(u.Free), FormatSize(need), FormatSize(g.limits.MinFree))
+	}
+	return nil
+}
+
+// Add records n bytes written to the library.
+func (g *Guard) Add(n int64) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.library += n
+	g.mu.Unlock()
+}
+
+// LibrarySize returns the tracked library size.
+func (g *Guard) LibrarySize() int64 {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.library
+}
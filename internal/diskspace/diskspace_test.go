@@ -0,0 +1,82 @@
+package diskspace
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"":        0,
+		"512":     512,
+		"10K":     10 << 10,
+		"1.5GiB":  3 << 29,
+		"2 g":     2 << 30,
+		"200MB":   200e6,
+		"1TiB":    1 << 40,
+		"0.5 tb ": 5e11,
+	}
+	for in, want := range cases {
+		got, err := ParseSize(in)
+		if err != nil {
+			t.Errorf("ParseSize(%q) error: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseSize(%q) = %d, want %d", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"GiB", "12XB", "-3G", "1..2M"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestGuardLimits(t *testing.T) {
+	g := NewGuard("/music", Limits{MinFree: 100, MaxLibrary: 1000}, 900)
+	free := int64(500)
+	g.stat = func(string) (Usage, error) { return Usage{Free: free}, nil }
+
+	if err := g.Check(50); err != nil {
+		t.Fatalf("Check(50) = %v, want nil", err)
+	}
+	g.Add(60)
+	if err := g.Check(50); !errors.Is(err, ErrLimit) {
+		t.Fatalf("Check over max library = %v, want ErrLimit", err)
+	}
+
+	g = NewGuard("/music", Limits{MinFree: 100}, 0)
+	g.stat = func(string) (Usage, error) { return Usage{Free: free}, nil }
+	if err := g.Check(400); err != nil {
+		t.Fatalf("Check(400) = %v, want nil", err)
+	}
+	if err := g.Check(401); !errors.Is(err, ErrLimit) {
+		t.Fatalf("Check below min free = %v, want ErrLimit", err)
+	}
+
+	g.stat = func(string) (Usage, error) { return Usage{}, ErrUnsupported }
+	if err := g.Check(1 << 40); err != nil {
+		t.Fatalf("Check without statfs = %v, want nil", err)
+	}
+
+	var nilGuard *Guard
+	if err := nilGuard.Check(1 << 40); err != nil {
+		t.Fatalf("nil Guard Check = %v", err)
+	}
+}
+
+func TestStatMissingPathUsesParent(t *testing.T) {
+	u, err := Stat(filepath.Join(t.TempDir(), "not", "yet", "created"))
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip("statfs not supported")
+	}
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if u.Free <= 0 || u.Total < u.Free {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+}
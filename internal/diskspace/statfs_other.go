@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+package diskspace
+
+func statfs(string) (Usage, error) {
+	return Usage{}, ErrUnsupported
+}
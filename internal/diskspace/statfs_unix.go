@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package diskspace
+
+import "golang.org/x/sys/unix"
+
+func statfs(dir string) (Usage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return Usage{}, err
+	}
+	bsize := int64(st.Bsize)
+	return Usage{Free: int64(st.Bavail) * bsize, Total: int64(st.Blocks) * bsize}, nil
+}
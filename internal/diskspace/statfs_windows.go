@@ -0,0 +1,17 @@
+//go:build windows
+
+package diskspace
+
+import "golang.org/x/sys/windows"
+
+func statfs(dir string) (Usage, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return Usage{}, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, &total, &totalFree); err != nil {
+		return Usage{}, err
+	}
+	return Usage{Free: int64(free), Total: int64(total)}, nil
+}
@@ -1,16 +1,18 @@
 package download
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"msr-archiver/internal/audio"
+	"msr-archiver/internal/validate"
 )
 
 // ProgressUpdate carries per-file download progress information.
@@ -27,16 +29,45 @@ type FileDownloadResult struct {
 	ContentType  string
 	BytesWritten int64
 	Duration     time.Duration
+	// Detection is set for songs; it records the sniffed format.
+	Detection Detection
+}
+
+// Options tunes Downloader behavior.
+type Options struct {
+	// Validate checks WAV headers before conversion and decode-checks the
+	// finished FLAC/MP3 file. Requires ffmpeg.
+	Validate bool
+	// Stream pipes WAV downloads into the FLAC encoder instead of writing
+	// the WAV to disk first.
+	Stream bool
+	// StallTimeout aborts a transfer when a read receives no data for this
+	// long. Zero disables the watchdog.
+	StallTimeout time.Duration
+	// MinRate is the slowest acceptable average transfer rate in bytes per
+	// second. With a known size it bounds the whole transfer to
+	// StallTimeout plus size/MinRate. Zero disables the bound.
+	MinRate int64
+	// ResumeAttempts is how often a stalled transfer is resumed with a
+	// Range request before giving up. Servers must advertise byte ranges
+	// and a validator (ETag or Last-Modified).
+	ResumeAttempts int
 }
 
 // Downloader streams files from HTTP endpoints.
 type Downloader struct {
 	httpClient *http.Client
+	opts       Options
 }
 
 // New creates a Downloader.
 func New(httpClient *http.Client) *Downloader {
-	return &Downloader{httpClient: httpClient}
+	return NewWithOptions(httpClient, Options{})
+}
+
+// NewWithOptions creates a Downloader with explicit options.
+func NewWithOptions(httpClient *http.Client, opts Options) *Downloader {
+	return &Downloader{httpClient: httpClient, opts: opts}
 }
 
 // DownloadToFile downloads a URL to a given destination path.
@@ -52,21 +83,12 @@ func (d *Downloader) DownloadToFile(ctx context.Context, url, dstPath string) (s
 func (d *Downloader) DownloadToFileWithProgress(ctx context.Context, url, dstPath string, progress ProgressFunc) (FileDownloadResult, error) {
 	started := time.Now()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, err := d.get(ctx, url)
 	if err != nil {
-		return FileDownloadResult{}, fmt.Errorf("create request: %w", err)
-	}
-
-	resp, err := d.httpClient.Do(req)
-	if err != nil {
-		return FileDownloadResult{}, fmt.Errorf("download %s: %w", url, err)
+		return FileDownloadResult{}, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return FileDownloadResult{}, fmt.Errorf("download %s: unexpected status %d", url, resp.StatusCode)
-	}
-
 	if err := os.MkdirAll(filepath.Dir(dstPath), 0o755); err != nil {
 		return FileDownloadResult{}, fmt.Errorf("create parent dirs: %w", err)
 	}
@@ -77,15 +99,13 @@ func (d *Downloader) DownloadToFileWithProgress(ctx context.Context, url, dstPat
 	}
 	defer out.Close()
 
-	totalBytes := resp.ContentLength
-	if totalBytes <= 0 {
-		totalBytes = -1
-	}
-
-	bytesWritten, err := copyWithProgress(out, resp.Body, totalBytes, progress)
+	bytesWritten, err := d.copyResponse(ctx, url, resp, resp.Body, out, progress)
 	if err != nil {
 		return FileDownloadResult{}, fmt.Errorf("write file %s: %w", dstPath, err)
 	}
+	if err := validate.ContentLength(resp.ContentLength, bytesWritten); err != nil {
+		return FileDownloadResult{}, fmt.Errorf("download %s: %w", url, err)
+	}
 
 	return FileDownloadResult{
 		ContentType:  resp.Header.Get("Content-Type"),
@@ -94,6 +114,48 @@ func (d *Downloader) DownloadToFileWithProgress(ctx context.Context, url, dstPat
 	}, nil
 }
 
+// get issues a GET request and fails on non-2xx responses. The caller closes
+// the body.
+func (d *Downloader) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", url, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// ContentLength returns the size of url from a HEAD request, or -1 when the
+// server does not report it.
+func (d *Downloader) ContentLength(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("head %s: %w", url, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("head %s: unexpected status %d", url, resp.StatusCode)
+	}
+	if resp.ContentLength < 0 {
+		return -1, nil
+	}
+	return resp.ContentLength, nil
+}
+
 // DownloadSong downloads a song and converts WAV to FLAC to match previous behavior.
 func (d *Downloader) DownloadSong(ctx context.Context, dir, name, sourceURL string) (string, string, error) {
 	path, fileType, _, err := d.DownloadSongWithProgress(ctx, dir, name, sourceURL, nil)
@@ -106,20 +168,52 @@ func (d *Downloader) DownloadSong(ctx context.Context, dir, name, sourceURL stri
 // DownloadSongWithProgress downloads a song and reports file progress.
 func (d *Downloader) DownloadSongWithProgress(ctx context.Context, dir, name, sourceURL string, progress ProgressFunc) (string, string, FileDownloadResult, error) {
 	base := filepath.Join(dir, MakeValid(name))
-	wavPath := base + ".wav"
-	mp3Path := base + ".mp3"
+	if d.opts.Stream {
+		return d.streamSong(ctx, base, sourceURL, progress)
+	}
+	partPath := base + ".part"
+
+	dl, err := d.DownloadToFileWithProgress(ctx, sourceURL, partPath, progress)
+	if err != nil {
+		_ = os.Remove(partPath)
+		return "", "", FileDownloadResult{}, err
+	}
 
-	dl, err := d.DownloadToFileWithProgress(ctx, sourceURL, wavPath, progress)
+	head, err := readHead(partPath)
 	if err != nil {
+		_ = os.Remove(partPath)
 		return "", "", FileDownloadResult{}, err
 	}
+	dl.Detection = Detect(dl.ContentType, head)
+	if err := dl.Detection.Err(); err != nil {
+		_ = os.Remove(partPath)
+		return "", "", FileDownloadResult{}, fmt.Errorf("download %s: %w", sourceURL, err)
+	}
+
+	if dl.Detection.Format != FormatWAV {
+		fileType := dl.Detection.Format.Extension()
+		outPath := base + fileType
+		if err := os.Rename(partPath, outPath); err != nil {
+			_ = os.Remove(partPath)
+			return "", "", FileDownloadResult{}, fmt.Errorf("rename to %s: %w", fileType, err)
+		}
+		if err := d.validateOutput(ctx, outPath, fileType); err != nil {
+			return "", "", FileDownloadResult{}, err
+		}
+		return outPath, fileType, dl, nil
+	}
+
+	wavPath := base + ".wav"
+	if err := os.Rename(partPath, wavPath); err != nil {
+		_ = os.Remove(partPath)
+		return "", "", FileDownloadResult{}, fmt.Errorf("rename to wav: %w", err)
+	}
 
-	contentType := dl.ContentType
-	if strings.Contains(strings.ToLower(contentType), "audio/mpeg") {
-		if err := os.Rename(wavPath, mp3Path); err != nil {
-			return "", "", FileDownloadResult{}, fmt.Errorf("rename to mp3: %w", err)
+	if d.opts.Validate {
+		if err := validate.WAV(wavPath); err != nil {
+			_ = os.Remove(wavPath)
+			return "", "", FileDownloadResult{}, fmt.Errorf("validate %s: %w", wavPath, err)
 		}
-		return mp3Path, ".mp3", dl, nil
 	}
 
 	flacPath := base + ".flac"
@@ -129,46 +223,145 @@ func (d *Downloader) DownloadSongWithProgress(ctx context.Context, dir, name, so
 	if err := os.Remove(wavPath); err != nil {
 		return "", "", FileDownloadResult{}, fmt.Errorf("remove wav file: %w", err)
 	}
+	if err := d.validateOutput(ctx, flacPath, ".flac"); err != nil {
+		return "", "", FileDownloadResult{}, err
+	}
 
 	return flacPath, ".flac", dl, nil
 }
 
-func copyWithProgress(dst io.Writer, src io.Reader, totalBytes int64, progress ProgressFunc) (int64, error) {
-	buf := make([]byte, 32*1024)
-	var bytesWritten int64
-	var lastProgress time.Time
-
-	for {
-		n, readErr := src.Read(buf)
-		if n > 0 {
-			written, writeErr := dst.Write(buf[:n])
-			if written > 0 {
-				bytesWritten += int64(written)
-			}
-			if writeErr != nil {
-				return bytesWritten, writeErr
-			}
-			if written != n {
-				return bytesWritten, io.ErrShortWrite
-			}
-
-			if progress != nil {
-				now := time.Now()
-				if lastProgress.IsZero() || now.Sub(lastProgress) >= 700*time.Millisecond {
-					progress(ProgressUpdate{BytesWritten: bytesWritten, TotalBytes: totalBytes})
-					lastProgress = now
-				}
-			}
+// streamSong pipes a WAV response straight into the FLAC encoder so the WAV
+// never touches disk; other formats are written as-is. Output goes to a
+// .part file that is renamed once complete and removed on failure.
+func (d *Downloader) streamSong(ctx context.Context, base, sourceURL string, progress ProgressFunc) (string, string, FileDownloadResult, error) {
+	started := time.Now()
+
+	resp, err := d.get(ctx, sourceURL)
+	if err != nil {
+		return "", "", FileDownloadResult{}, err
+	}
+	defer resp.Body.Close()
+
+	body := bufio.NewReaderSize(resp.Body, 64*1024)
+	contentType := resp.Header.Get("Content-Type")
+	head, err := d.peekHead(body, resp.Body, sniffLen)
+	if err != nil {
+		return "", "", FileDownloadResult{}, fmt.Errorf("download %s: %w", sourceURL, err)
+	}
+	detection := Detect(contentType, head)
+	if err := detection.Err(); err != nil {
+		return "", "", FileDownloadResult{}, fmt.Errorf("download %s: %w", sourceURL, err)
+	}
+	if d.opts.Validate && detection.Format == FormatWAV {
+		if err := validate.WAVHeader(head, resp.ContentLength); err != nil {
+			return "", "", FileDownloadResult{}, fmt.Errorf("download %s: %w", sourceURL, err)
 		}
+	}
+	fileType := detection.Format.Extension()
+
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		return "", "", FileDownloadResult{}, fmt.Errorf("create parent dirs: %w", err)
+	}
+	finalPath := base + fileType
+	partPath := finalPath + ".part"
+
+	copyBody := func(dst io.Writer) (int64, error) {
+		return d.copyResponse(ctx, sourceURL, resp, body, dst, progress)
+	}
+	var n int64
+	if detection.Format == FormatWAV {
+		n, err = encodeStream(ctx, partPath, copyBody)
+	} else {
+		n, err = writeStream(partPath, copyBody)
+	}
+	if err == nil {
+		err = validate.ContentLength(resp.ContentLength, n)
+	}
+	if err != nil {
+		_ = os.Remove(partPath)
+		return "", "", FileDownloadResult{}, fmt.Errorf("download %s: %w", sourceURL, err)
+	}
+	if err := os.Rename(partPath, finalPath); err != nil {
+		_ = os.Remove(partPath)
+		return "", "", FileDownloadResult{}, fmt.Errorf("finalize %s: %w", finalPath, err)
+	}
+	if err := d.validateOutput(ctx, finalPath, fileType); err != nil {
+		return "", "", FileDownloadResult{}, err
+	}
+
+	return finalPath, fileType, FileDownloadResult{
+		ContentType:  contentType,
+		BytesWritten: n,
+		Duration:     time.Since(started),
+		Detection:    detection,
+	}, nil
+}
+
+// readHead returns up to sniffLen leading bytes of path.
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return head[:n], nil
+}
 
-		if readErr != nil {
-			if readErr == io.EOF {
-				if progress != nil {
-					progress(ProgressUpdate{BytesWritten: bytesWritten, TotalBytes: totalBytes})
-				}
-				return bytesWritten, nil
-			}
-			return bytesWritten, readErr
+func writeStream(path string, copyBody func(io.Writer) (int64, error)) (int64, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("create file %s: %w", path, err)
+	}
+	n, err := copyBody(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// encodeStream feeds the body to the FLAC encoder through a pipe and returns
+// the number of source bytes consumed.
+func encodeStream(ctx context.Context, flacPath string, copyBody func(io.Writer) (int64, error)) (int64, error) {
+	type copyResult struct {
+		n   int64
+		err error
+	}
+
+	pr, pw := io.Pipe()
+	copied := make(chan copyResult, 1)
+	go func() {
+		n, err := copyBody(pw)
+		pw.CloseWithError(err)
+		copied <- copyResult{n, err}
+	}()
+
+	encErr := audio.StreamWAVToFLAC(ctx, pr, flacPath)
+	// Unblock the copy if the encoder stopped reading early.
+	pr.Close()
+	res := <-copied
+	if res.err != nil && !errors.Is(res.err, io.ErrClosedPipe) {
+		return res.n, res.err
+	}
+	return res.n, encErr
+}
+
+// validateOutput decode-checks a finished file and removes it when invalid so
+// a retry starts from scratch.
+func (d *Downloader) validateOutput(ctx context.Context, path, fileType string) error {
+	if !d.opts.Validate {
+		return nil
+	}
+	if err := validate.Decode(ctx, path, fileType); err != nil {
+		if errors.Is(err, validate.ErrInvalid) {
+			_ = os.Remove(path)
 		}
+		return fmt.Errorf("validate %s: %w", path, err)
 	}
+	return nil
 }
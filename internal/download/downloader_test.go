@@ -2,12 +2,18 @@ package download
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+
+	"msr-archiver/internal/validate"
 )
 
 type roundTripFunc func(*http.Request) (*http.Response, error)
@@ -157,3 +163,163 @@ func TestDownloadSongWithProgressReturnsStats(t *testing.T) {
 		t.Fatalf("expected final progress bytes %d, got %d", len(body), latest.BytesWritten)
 	}
 }
+
+func TestDownloadToFileDetectsShortBody(t *testing.T) {
+	d := newDownloader(func(req *http.Request) (*http.Response, error) {
+		resp := response(200, "audio/wav", "short")
+		resp.ContentLength = 100
+		return resp, nil
+	})
+
+	_, err := d.DownloadToFile(context.Background(), "https://example.test/audio", filepath.Join(t.TempDir(), "song.wav"))
+	if !errors.Is(err, validate.ErrInvalid) {
+		t.Fatalf("expected validation error for short body, got %v", err)
+	}
+}
+
+func TestDownloadSongValidationRejectsTruncatedWAV(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", stream), func(t *testing.T) {
+			stubFFmpeg(t, false)
+			d := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return response(200, "audio/wav", "RIFF\xff\x00\x00\x00WAVE"), nil
+			})}, Options{Validate: true, Stream: stream})
+
+			dir := t.TempDir()
+			_, _, err := d.DownloadSong(context.Background(), dir, "song", "https://example.test/song")
+			if !errors.Is(err, validate.ErrInvalid) {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 0 {
+				t.Fatalf("invalid wav left %d file(s) behind", len(entries))
+			}
+		})
+	}
+}
+
+func TestDownloadSongStreamSkipsWAVHeaderCheckWithoutValidate(t *testing.T) {
+	stubFFmpeg(t, false)
+	body := "RIFF\xff\x00\x00\x00WAVE"
+	d := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return response(200, "audio/wav", body), nil
+	})}, Options{Stream: true})
+
+	path, _, err := d.DownloadSong(context.Background(), t.TempDir(), "song", "https://example.test/song")
+	if err != nil {
+		t.Fatalf("DownloadSong failed: %v", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != body {
+		t.Fatalf("encoder did not receive the stream")
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	d := newDownloader(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodHead {
+			t.Fatalf("unexpected method %s", req.Method)
+		}
+		resp := response(200, "audio/wav", "")
+		resp.ContentLength = 4096
+		if strings.HasSuffix(req.URL.Path, "/chunked") {
+			resp.ContentLength = -1
+		}
+		return resp, nil
+	})
+
+	n, err := d.ContentLength(context.Background(), "https://example.test/song.wav")
+	if err != nil || n != 4096 {
+		t.Fatalf("ContentLength = %d, %v; want 4096", n, err)
+	}
+	n, err = d.ContentLength(context.Background(), "https://example.test/chunked")
+	if err != nil || n != -1 {
+		t.Fatalf("ContentLength unknown = %d, %v; want -1", n, err)
+	}
+}
+
+// stubFFmpeg puts an ffmpeg on PATH that copies stdin to its last argument,
+// or fails after reading its input when fail is set.
+func stubFFmpeg(t *testing.T, fail bool) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	script := "#!/bin/sh\nfor a; do out=\"$a\"; done\ncat > \"$out\"\n"
+	if fail {
+		script = "#!/bin/sh\ncat > /dev/null\necho 'invalid data' >&2\nexit 1\n"
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func wavBody(dataLen int) string {
+	b := make([]byte, 44+dataLen)
+	copy(b[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(b[4:8], uint32(36+dataLen))
+	copy(b[8:16], "WAVEfmt ")
+	copy(b[36:40], "data")
+	binary.LittleEndian.PutUint32(b[40:44], uint32(dataLen))
+	return string(b)
+}
+
+func TestDownloadSongStreamsWAVIntoEncoder(t *testing.T) {
+	stubFFmpeg(t, false)
+	body := wavBody(1000)
+	d := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return response(200, "audio/wav", body), nil
+	})}, Options{Stream: true})
+
+	dir := t.TempDir()
+	path, fileType, result, err := d.DownloadSongWithProgress(context.Background(), dir, "song", "https://example.test/song", nil)
+	if err != nil {
+		t.Fatalf("DownloadSongWithProgress failed: %v", err)
+	}
+	if fileType != ".flac" || filepath.Base(path) != "song.flac" {
+		t.Fatalf("unexpected output %s (%s)", path, fileType)
+	}
+	if result.BytesWritten != int64(len(body)) {
+		t.Fatalf("BytesWritten = %d, want %d", result.BytesWritten, len(body))
+	}
+	b, err := os.ReadFile(path)
+	if err != nil || string(b) != body {
+		t.Fatalf("encoder did not receive the stream: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected only the flac in %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestDownloadSongStreamFailureLeavesNoFiles(t *testing.T) {
+	cases := map[string]struct {
+		body       string
+		length     int64
+		failEncode bool
+	}{
+		"truncated body": {body: wavBody(1000)[:500], length: 1044},
+		"encoder error":  {body: wavBody(1000), length: 1044, failEncode: true},
+		"not wav":        {body: "<html>gateway error</html>", length: 26},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			stubFFmpeg(t, tc.failEncode)
+			d := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				resp := response(200, "audio/wav", tc.body)
+				resp.ContentLength = tc.length
+				return resp, nil
+			})}, Options{Stream: true, Validate: true})
+
+			dir := t.TempDir()
+			if _, _, err := d.DownloadSong(context.Background(), dir, "song", "https://example.test/song"); err == nil {
+				t.Fatalf("expected an error")
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 0 {
+				t.Fatalf("failed stream left %d file(s) behind", len(entries))
+			}
+		})
+	}
+}
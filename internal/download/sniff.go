@@ -0,0 +1,133 @@
+package download
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"msr-archiver/internal/validate"
+)
+
+// Format is an audio container recognized by the downloader.
+type Format int
+
+// Recognized formats.
+const (
+	FormatUnknown Format = iota
+	FormatWAV
+	FormatMP3
+	FormatFLAC
+	FormatOgg
+)
+
+// sniffLen is how many leading bytes Sniff needs.
+const sniffLen = 12
+
+func (f Format) String() string {
+	switch f {
+	case FormatWAV:
+		return "WAV"
+	case FormatMP3:
+		return "MP3"
+	case FormatFLAC:
+		return "FLAC"
+	case FormatOgg:
+		return "Ogg"
+	default:
+		return "unknown"
+	}
+}
+
+// Extension returns the file extension a format is stored with. WAV is
+// converted, so it maps to its FLAC output.
+func (f Format) Extension() string {
+	switch f {
+	case FormatMP3:
+		return ".mp3"
+	case FormatOgg:
+		return ".ogg"
+	case FormatWAV, FormatFLAC:
+		return ".flac"
+	default:
+		return ""
+	}
+}
+
+// Sniff identifies a format from the first bytes of a file.
+func Sniff(head []byte) Format {
+	switch {
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return FormatWAV
+	case len(head) >= 4 && string(head[0:4]) == "fLaC":
+		return FormatFLAC
+	case len(head) >= 4 && string(head[0:4]) == "OggS":
+		return FormatOgg
+	case len(head) >= 3 && string(head[0:3]) == "ID3":
+		return FormatMP3
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 && head[1]&0x06 != 0:
+		// MPEG audio frame sync with a valid layer.
+		return FormatMP3
+	}
+	return FormatUnknown
+}
+
+// FormatFromContentType maps a Content-Type header to a format. Generic
+// types such as application/octet-stream are unknown.
+func FormatFromContentType(contentType string) Format {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	switch mediaType {
+	case "audio/mpeg", "audio/mp3", "audio/mpeg3", "audio/x-mpeg":
+		return FormatMP3
+	case "audio/wav", "audio/x-wav", "audio/wave", "audio/vnd.wave":
+		return FormatWAV
+	case "audio/flac", "audio/x-flac":
+		return FormatFLAC
+	case "audio/ogg", "application/ogg", "audio/vorbis", "audio/opus":
+		return FormatOgg
+	}
+	return FormatUnknown
+}
+
+// Detection is the outcome of format detection for a response.
+type Detection struct {
+	// Format decides the pipeline: the sniffed format when the content is
+	// recognized, otherwise the one named by the header.
+	Format Format
+	Header Format
+	Sniff  Format
+}
+
+// Detect combines the Content-Type header and the first bytes of the body.
+func Detect(contentType string, head []byte) Detection {
+	d := Detection{Header: FormatFromContentType(contentType), Sniff: Sniff(head)}
+	d.Format = d.Sniff
+	if d.Format == FormatUnknown {
+		d.Format = d.Header
+	}
+	return d
+}
+
+// Mismatch reports whether the header names a different format than the
+// content has.
+func (d Detection) Mismatch() bool {
+	return d.Header != FormatUnknown && d.Sniff != FormatUnknown && d.Header != d.Sniff
+}
+
+// Err returns an error when no format could be determined.
+func (d Detection) Err() error {
+	if d.Format == FormatUnknown {
+		return fmt.Errorf("%w: unrecognized audio format", validate.ErrInvalid)
+	}
+	return nil
+}
+
+// String describes the detection for logs.
+func (d Detection) String() string {
+	if d.Mismatch() {
+		return fmt.Sprintf("%s (Content-Type says %s)", d.Sniff, d.Header)
+	}
+	return d.Format.String()
+}
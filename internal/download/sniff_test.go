@@ -0,0 +1,95 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"msr-archiver/internal/validate"
+)
+
+func TestSniff(t *testing.T) {
+	cases := map[string]Format{
+		"RIFF\x24\x00\x00\x00WAVEfmt ": FormatWAV,
+		"fLaC\x00\x00\x00\x22":         FormatFLAC,
+		"OggS\x00\x02":                 FormatOgg,
+		"ID3\x03\x00":                  FormatMP3,
+		"\xff\xfb\x90\x64":             FormatMP3,
+		"\xff\xe0":                     FormatUnknown, // sync with reserved layer
+		"RIFF\x24\x00\x00\x00AVI ":     FormatUnknown,
+		"<html>":                       FormatUnknown,
+		"":                             FormatUnknown,
+	}
+	for head, want := range cases {
+		if got := Sniff([]byte(head)); got != want {
+			t.Errorf("Sniff(%q) = %s, want %s", head, got, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		contentType string
+		head        string
+		want        Format
+		mismatch    bool
+	}{
+		{"audio/mpeg", "ID3\x04", FormatMP3, false},
+		{"application/octet-stream", "ID3\x04", FormatMP3, false},
+		{"audio/wav", "ID3\x04", FormatMP3, true},
+		{"audio/mpeg; charset=binary", "", FormatMP3, false},
+		{"audio/x-wav", "RIFF\x00\x00\x00\x00WAVE", FormatWAV, false},
+		{"audio/flac", "fLaC", FormatFLAC, false},
+		{"application/octet-stream", "<html>", FormatUnknown, false},
+	}
+	for _, tc := range cases {
+		d := Detect(tc.contentType, []byte(tc.head))
+		if d.Format != tc.want || d.Mismatch() != tc.mismatch {
+			t.Errorf("Detect(%q, %q) = %+v (mismatch %v), want %s (mismatch %v)",
+				tc.contentType, tc.head, d, d.Mismatch(), tc.want, tc.mismatch)
+		}
+	}
+	if err := Detect("text/html", []byte("<html>")).Err(); !errors.Is(err, validate.ErrInvalid) {
+		t.Fatalf("unknown format error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestDownloadSongSniffsMP3ServedAsOctetStream(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		d := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return response(200, "application/octet-stream", "ID3\x04\x00fake-mp3"), nil
+		})}, Options{Stream: stream})
+
+		dir := t.TempDir()
+		path, fileType, dl, err := d.DownloadSongWithProgress(context.Background(), dir, "song", "https://example.test/song", nil)
+		if err != nil {
+			t.Fatalf("stream=%v: DownloadSongWithProgress failed: %v", stream, err)
+		}
+		if fileType != ".mp3" || filepath.Base(path) != "song.mp3" || dl.Detection.Format != FormatMP3 {
+			t.Fatalf("stream=%v: got %s (%s, %s), want song.mp3", stream, path, fileType, dl.Detection)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Fatalf("stream=%v: expected only the mp3, got %d entries", stream, len(entries))
+		}
+	}
+}
+
+func TestDownloadSongReportsMismatch(t *testing.T) {
+	d := newDownloader(func(req *http.Request) (*http.Response, error) {
+		return response(200, "audio/wav", "fLaC\x00\x00\x00\x22"), nil
+	})
+	path, fileType, dl, err := d.DownloadSongWithProgress(context.Background(), t.TempDir(), "song", "https://example.test/song", nil)
+	if err != nil {
+		t.Fatalf("DownloadSongWithProgress failed: %v", err)
+	}
+	if fileType != ".flac" || filepath.Base(path) != "song.flac" {
+		t.Fatalf("FLAC content should be stored without conversion, got %s", path)
+	}
+	if !dl.Detection.Mismatch() || dl.Detection.Header != FormatWAV {
+		t.Fatalf("expected a header/content mismatch, got %+v", dl.Detection)
+	}
+}
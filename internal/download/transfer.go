@@ -0,0 +1,293 @@
+package download
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled matches transfers aborted by the stall watchdog.
+var ErrStalled = errors.New("transfer stalled")
+
+// StallError reports a transfer aborted for lack of progress.
+type StallError struct {
+	// Idle is set when a read received no data for this long.
+	Idle time.Duration
+	// Budget is set when the size-scaled transfer budget ran out.
+	Budget time.Duration
+	// Written is the number of bytes received before the abort.
+	Written int64
+}
+
+func (e *StallError) Error() string {
+	if e.Budget > 0 {
+		return fmt.Sprintf("transfer stalled: not finished within %s (%d bytes received)", e.Budget, e.Written)
+	}
+	return fmt.Sprintf("transfer stalled: no data for %s (%d bytes received)", e.Idle, e.Written)
+}
+
+// Is makes StallError match ErrStalled.
+func (e *StallError) Is(target error) bool {
+	return target == ErrStalled
+}
+
+// transferLimits bounds one body copy. Zero values disable a bound.
+type transferLimits struct {
+	idle   time.Duration
+	budget time.Duration
+}
+
+// limits derives the bounds of a transfer of expected bytes: the idle
+// timeout, plus a budget that grows with size at Options.MinRate.
+func (d *Downloader) limits(expected int64) transferLimits {
+	l := transferLimits{idle: d.opts.StallTimeout}
+	if d.opts.MinRate > 0 && expected > 0 {
+		l.budget = d.opts.StallTimeout + time.Duration(float64(expected)/float64(d.opts.MinRate)*float64(time.Second))
+	}
+	return l
+}
+
+// watchdog closes a body whose reads stop making progress. Only time spent
+// blocked in Read counts, so a slow consumer such as the encoder does not
+// trip it.
+type watchdog struct {
+	limits  transferLimits
+	closer  io.Closer
+	started time.Time
+
+	readStart atomic.Int64
+	mu        sync.Mutex
+	stalled   *StallError
+	done      chan struct{}
+}
+
+func startWatchdog(closer io.Closer, limits transferLimits) *watchdog {
+	w := &watchdog{limits: limits, closer: closer, started: time.Now(), done: make(chan struct{})}
+	if closer == nil || (limits.idle <= 0 && limits.budget <= 0) {
+		return w
+	}
+
+	interval := time.Second
+	if limits.idle > 0 {
+		interval = min(interval, max(limits.idle/4, 10*time.Millisecond))
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case now := <-ticker.C:
+				if w.check(now) {
+					return
+				}
+			}
+		}
+	}()
+	return w
+}
+
+func (w *watchdog) check(now time.Time) bool {
+	start := w.readStart.Load()
+	if start == 0 {
+		return false
+	}
+	var stall *StallError
+	switch {
+	case w.limits.idle > 0 && now.Sub(time.Unix(0, start)) > w.limits.idle:
+		stall = &StallError{Idle: w.limits.idle}
+	case w.limits.budget > 0 && now.Sub(w.started) > w.limits.budget:
+		stall = &StallError{Budget: w.limits.budget}
+	default:
+		return false
+	}
+	w.mu.Lock()
+	w.stalled = stall
+	w.mu.Unlock()
+	_ = w.closer.Close()
+	return true
+}
+
+func (w *watchdog) enterRead() { w.readStart.Store(time.Now().UnixNano()) }
+func (w *watchdog) leaveRead() { w.readStart.Store(0) }
+func (w *watchdog) stop()      { close(w.done) }
+
+// err returns the stall that aborted the transfer, if any.
+func (w *watchdog) err(written int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stalled == nil {
+		return nil
+	}
+	stall := *w.stalled
+	stall.Written = written
+	return &stall
+}
+
+// copyWithProgress copies src to dst, reporting progress with offset added
+// for resumed transfers. closer is closed by the watchdog to abort a stalled
+// read, which then fails with a *StallError.
+func copyWithProgress(dst io.Writer, src io.Reader, closer io.Closer, offset, totalBytes int64, progress ProgressFunc, limits transferLimits) (int64, error) {
+	wd := startWatchdog(closer, limits)
+	defer wd.stop()
+
+	buf := make([]byte, 32*1024)
+	var bytesWritten int64
+	var lastProgress time.Time
+
+	for {
+		wd.enterRead()
+		n, readErr := src.Read(buf)
+		wd.leaveRead()
+		if n > 0 {
+			written, writeErr := dst.Write(buf[:n])
+			if written > 0 {
+				bytesWritten += int64(written)
+			}
+			if writeErr != nil {
+				return bytesWritten, writeErr
+			}
+			if written != n {
+				return bytesWritten, io.ErrShortWrite
+			}
+
+			if progress != nil {
+				now := time.Now()
+				if lastProgress.IsZero() || now.Sub(lastProgress) >= 700*time.Millisecond {
+					progress(ProgressUpdate{BytesWritten: offset + bytesWritten, TotalBytes: totalBytes})
+					lastProgress = now
+				}
+			}
+		}
+
+		if readErr != nil {
+			if readErr == io.EOF {
+				if progress != nil {
+					progress(ProgressUpdate{BytesWritten: offset + bytesWritten, TotalBytes: totalBytes})
+				}
+				return bytesWritten, nil
+			}
+			if err := wd.err(offset + bytesWritten); err != nil {
+				return bytesWritten, err
+			}
+			return bytesWritten, readErr
+		}
+	}
+}
+
+// peekHead returns up to n leading bytes of src without consuming them. The
+// read runs under the idle stall limit so a server that sends headers and
+// then nothing cannot hang the download before the copy starts. A body
+// shorter than n is not an error.
+func (d *Downloader) peekHead(src *bufio.Reader, closer io.Closer, n int) ([]byte, error) {
+	wd := startWatchdog(closer, transferLimits{idle: d.opts.StallTimeout})
+	defer wd.stop()
+
+	wd.enterRead()
+	head, err := src.Peek(n)
+	wd.leaveRead()
+	if err != nil && err != io.EOF {
+		if stall := wd.err(0); stall != nil {
+			return nil, stall
+		}
+		return nil, err
+	}
+	return head, nil
+}
+
+// copyResponse copies the body of resp, read through src (which may buffer
+// resp.Body), to dst. A stalled transfer is resumed with a Range request for
+// the remaining bytes up to Options.ResumeAttempts times.
+func (d *Downloader) copyResponse(ctx context.Context, url string, resp *http.Response, src io.Reader, dst io.Writer, progress ProgressFunc) (int64, error) {
+	totalBytes := resp.ContentLength
+	if totalBytes <= 0 {
+		totalBytes = -1
+	}
+	validator := resp.Header.Get("ETag")
+	if validator == "" {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	canResume := strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes") && validator != "" && totalBytes > 0
+
+	var written int64
+	body := resp.Body
+	for attempt := 0; ; attempt++ {
+		n, err := copyWithProgress(dst, src, body, written, totalBytes, progress, d.limits(totalBytes-written))
+		written += n
+		if body != resp.Body {
+			body.Close()
+		}
+		if err == nil || !errors.Is(err, ErrStalled) || !canResume || attempt >= d.opts.ResumeAttempts {
+			return written, err
+		}
+
+		next, resumeErr := d.resumeFrom(ctx, url, validator, written, totalBytes)
+		if resumeErr != nil {
+			return written, fmt.Errorf("%w; resume failed: %v", err, resumeErr)
+		}
+		body, src = next, next
+	}
+}
+
+// resumeFrom requests url from byte offset. The server must answer with the
+// matching partial content of the same representation.
+func (d *Downloader) resumeFrom(ctx context.Context, url, validator string, offset, total int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	req.Header.Set("If-Range", validator)
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("server answered %d instead of partial content", resp.StatusCode)
+	}
+	start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if !ok || start != offset || (size >= 0 && size != total) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+	}
+	return resp.Body, nil
+}
+
+// parseContentRange parses "bytes START-END/SIZE"; SIZE may be "*" (-1).
+func parseContentRange(v string) (start, size int64, ok bool) {
+	rest, found := strings.CutPrefix(v, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	span, total, found := strings.Cut(rest, "/")
+	if !found {
+		return 0, 0, false
+	}
+	first, _, found := strings.Cut(span, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if total == "*" {
+		return start, -1, true
+	}
+	size, err = strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
@@ -0,0 +1,208 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stallingServer sends the first half of content and then hangs until the
+// test ends. Range requests are served normally when ranges is set.
+func stallingServer(t *testing.T, content string, ranges bool) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	release := make(chan struct{})
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if ranges {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("Range") != "" {
+				http.ServeContent(w, r, "song.mp3", time.Time{}, strings.NewReader(content))
+				return
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write([]byte(content[:len(content)/2]))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		srv.Close()
+	})
+	return srv, &requests
+}
+
+func TestStalledDownloadResumesWithRange(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	srv, requests := stallingServer(t, content, true)
+	d := NewWithOptions(srv.Client(), Options{StallTimeout: 100 * time.Millisecond, ResumeAttempts: 1})
+
+	var last ProgressUpdate
+	dst := filepath.Join(t.TempDir(), "song.mp3")
+	result, err := d.DownloadToFileWithProgress(context.Background(), srv.URL, dst, func(u ProgressUpdate) { last = u })
+	if err != nil {
+		t.Fatalf("DownloadToFileWithProgress failed: %v", err)
+	}
+	if result.BytesWritten != int64(len(content)) {
+		t.Fatalf("BytesWritten = %d, want %d", result.BytesWritten, len(content))
+	}
+	if got, _ := os.ReadFile(dst); string(got) != content {
+		t.Fatalf("resumed file differs from the source")
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("requests = %d, want the original and one resume", requests.Load())
+	}
+	if last.BytesWritten != int64(len(content)) || last.TotalBytes != int64(len(content)) {
+		t.Fatalf("final progress = %+v", last)
+	}
+}
+
+func TestStalledDownloadWithoutRangesFails(t *testing.T) {
+	content := strings.Repeat("x", 4096)
+	srv, requests := stallingServer(t, content, false)
+	d := NewWithOptions(srv.Client(), Options{StallTimeout: 100 * time.Millisecond, ResumeAttempts: 3})
+
+	_, err := d.DownloadToFileWithProgress(context.Background(), srv.URL, filepath.Join(t.TempDir(), "song.mp3"), nil)
+	if !errors.Is(err, ErrStalled) {
+		t.Fatalf("error = %v, want ErrStalled", err)
+	}
+	var stall *StallError
+	if !errors.As(err, &stall) || stall.Written != int64(len(content)/2) || stall.Idle == 0 {
+		t.Fatalf("stall details = %+v", stall)
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("requests = %d, want no resume without range support", requests.Load())
+	}
+}
+
+func TestStreamedSniffStallFails(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Header().Set("Content-Length", "1044")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		srv.Close()
+	})
+	d := NewWithOptions(srv.Client(), Options{Stream: true, StallTimeout: 100 * time.Millisecond})
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := d.DownloadSong(context.Background(), t.TempDir(), "song", srv.URL)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrStalled) {
+			t.Fatalf("error = %v, want ErrStalled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sniffing a stalled body did not time out")
+	}
+}
+
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestStreamedSniffReportsReadError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	d := NewWithOptions(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := response(200, "audio/wav", "")
+		resp.Body = io.NopCloser(failingReader{readErr})
+		return resp, nil
+	})}, Options{Stream: true})
+
+	_, _, err := d.DownloadSong(context.Background(), t.TempDir(), "song", "https://example.test/song")
+	if !errors.Is(err, readErr) {
+		t.Fatalf("error = %v, want the read error", err)
+	}
+}
+
+type slowWriter struct {
+	bytes.Buffer
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.Buffer.Write(p)
+}
+
+// chunkReader returns at most size bytes per Read.
+type chunkReader struct {
+	r    io.Reader
+	size int
+}
+
+func (c chunkReader) Read(p []byte) (int, error) {
+	return c.r.Read(p[:min(len(p), c.size)])
+}
+
+func TestStallWatchdogIgnoresSlowConsumer(t *testing.T) {
+	src := chunkReader{r: strings.NewReader(strings.Repeat("x", 64)), size: 8}
+	dst := &slowWriter{delay: 20 * time.Millisecond}
+
+	n, err := copyWithProgress(dst, src, io.NopCloser(nil), 0, 64, nil, transferLimits{idle: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("copyWithProgress failed: %v", err)
+	}
+	if n != 64 || dst.Len() != 64 {
+		t.Fatalf("copied %d bytes, want 64", n)
+	}
+}
+
+func TestTransferBudgetScalesWithSize(t *testing.T) {
+	d := NewWithOptions(nil, Options{StallTimeout: 10 * time.Second, MinRate: 1 << 20})
+
+	if got := d.limits(0).budget; got != 0 {
+		t.Fatalf("budget without a known size = %s, want none", got)
+	}
+	if got := d.limits(50 << 20).budget; got != 60*time.Second {
+		t.Fatalf("budget for 50MiB = %s, want 60s", got)
+	}
+	if got := NewWithOptions(nil, Options{StallTimeout: time.Second}).limits(50 << 20).budget; got != 0 {
+		t.Fatalf("budget without MinRate = %s, want none", got)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	cases := []struct {
+		in          string
+		start, size int64
+		ok          bool
+	}{
+		{"bytes 100-199/200", 100, 200, true},
+		{"bytes 0-9/*", 0, -1, true},
+		{"bytes */200", 0, 0, false},
+		{"items 0-9/10", 0, 0, false},
+	}
+	for _, tc := range cases {
+		start, size, ok := parseContentRange(tc.in)
+		if ok != tc.ok || (ok && (start != tc.start || size != tc.size)) {
+			t.Fatalf("parseContentRange(%q) = %d, %d, %v", tc.in, start, size, ok)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package fuzzy scores search patterns against album and song titles. A
+// pattern is split into whitespace-separated tokens that must each match as
+// a subsequence; matches at word starts and consecutive runes score higher.
+// CJK titles can optionally be matched by their pinyin or romaji readings.
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// Scoring weights.
+const (
+	scoreMatch       = 16
+	bonusBoundary    = 8
+	bonusConsecutive = 6
+	bonusFirstRune   = 4
+	penaltyGap       = 1
+	maxGapPenalty    = 12
+)
+
+// Result is a successful match.
+type Result struct {
+	Score int
+	// Positions are matched rune indexes into the original text, ascending.
+	Positions []int
+}
+
+// Matcher matches patterns against texts. It caches transliterations and is
+// not safe for concurrent use.
+type Matcher struct {
+	transliterate bool
+	cache         map[string][]variant
+}
+
+// NewMatcher creates a Matcher. With transliterate set, Han characters also
+// match their toneless pinyin and kana their romaji.
+func NewMatcher(transliterate bool) *Matcher {
+	return &Matcher{transliterate: transliterate, cache: make(map[string][]variant)}
+}
+
+// variant is a searchable form of a text. origin maps each rune back to its
+// index in the original text; boundary marks word starts.
+type variant struct {
+	runes    []rune
+	origin   []int
+	boundary []bool
+}
+
+// Match scores pattern against text. Every token of pattern must match.
+func (m *Matcher) Match(pattern, text string) (Result, bool) {
+	tokens := strings.Fields(strings.ToLower(pattern))
+	if len(tokens) == 0 {
+		return Result{}, true
+	}
+
+	variants := m.variants(text)
+	total := 0
+	seen := make(map[int]struct{})
+	for _, token := range tokens {
+		tok := []rune(token)
+		best, bestPos, ok := 0, []int(nil), false
+		for _, v := range variants {
+			score, pos, matched := matchToken(tok, v)
+			if matched && (!ok || score > best) {
+				best, bestPos, ok = score, pos, true
+			}
+		}
+		if !ok {
+			return Result{}, false
+		}
+		total += best
+		for _, p := range bestPos {
+			seen[p] = struct{}{}
+		}
+	}
+
+	positions := make([]int, 0, len(seen))
+	for p := range seen {
+		positions = append(positions, p)
+	}
+	sort.Ints(positions)
+	return Result{Score: total, Positions: positions}, true
+}
+
+func (m *Matcher) variants(text string) []variant {
+	if v, ok := m.cache[text]; ok {
+		return v
+	}
+	src := []rune(text)
+	out := []variant{plainVariant(src)}
+	if m.transliterate {
+		if v, ok := translitVariant(src); ok {
+			out = append(out, v)
+		}
+	}
+	m.cache[text] = out
+	return out
+}
+
+func plainVariant(src []rune) variant {
+	v := variant{
+		runes:    make([]rune, len(src)),
+		origin:   make([]int, len(src)),
+		boundary: make([]bool, len(src)),
+	}
+	for i, r := range src {
+		v.runes[i] = unicode.ToLower(r)
+		v.origin[i] = i
+		v.boundary[i] = i == 0 || isWordStart(src[i-1], r)
+	}
+	return v
+}
+
+func isWordStart(prev, r rune) bool {
+	switch {
+	case isCJK(r):
+		return true
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev):
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case unicode.IsLower(prev) && unicode.IsUpper(r):
+		return true
+	case isCJK(prev):
+		return true
+	}
+	return false
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// translitVariant spells Han characters as pinyin and kana as romaji. Each
+// reading starts a word so "sai chang" or "saichang" both match 塞壬唱片.
+func translitVariant(src []rune) (variant, bool) {
+	var v variant
+	changed := false
+	args := pinyin.NewArgs()
+	for i, r := range src {
+		reading := ""
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if p := pinyin.SinglePinyin(r, args); len(p) > 0 {
+				reading = p[0]
+			}
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			reading = kanaRomaji(r)
+		}
+		if reading == "" {
+			v.runes = append(v.runes, unicode.ToLower(r))
+			v.origin = append(v.origin, i)
+			v.boundary = append(v.boundary, i == 0 || isWordStart(src[i-1], r))
+			continue
+		}
+		changed = true
+		for j, rr := range reading {
+			v.runes = append(v.runes, rr)
+			v.origin = append(v.origin, i)
+			v.boundary = append(v.boundary, j == 0)
+		}
+	}
+	return v, changed
+}
+
+// matchToken finds the best-scoring subsequence match of tok in v. It tries
+// each occurrence of the first rune as a start and extends greedily, which
+// favors tight matches without a full alignment search.
+func matchToken(tok []rune, v variant) (int, []int, bool) {
+	best, bestPos, ok := 0, []int(nil), false
+	for start := range v.runes {
+		if v.runes[start] != tok[0] {
+			continue
+		}
+		score, pos, matched := scoreFrom(tok, v, start)
+		if matched && (!ok || score > best) {
+			best, bestPos, ok = score, pos, true
+		}
+	}
+	if !ok {
+		return 0, nil, false
+	}
+
+	// Map to original rune indexes; transliterated runes share an origin.
+	orig := make([]int, 0, len(bestPos))
+	for _, p := range bestPos {
+		o := v.origin[p]
+		if len(orig) == 0 || orig[len(orig)-1] != o {
+			orig = append(orig, o)
+		}
+	}
+	return best, orig, true
+}
+
+func scoreFrom(tok []rune, v variant, start int) (int, []int, bool) {
+	pos := make([]int, 0, len(tok))
+	score := 0
+	ti := 0
+	prev := -1
+	for i := start; i < len(v.runes) && ti < len(tok); i++ {
+		if v.runes[i] != tok[ti] {
+			continue
+		}
+		score += scoreMatch
+		if v.boundary[i] {
+			score += bonusBoundary
+		}
+		if i == 0 {
+			score += bonusFirstRune
+		}
+		if prev >= 0 {
+			if i == prev+1 {
+				score += bonusConsecutive
+			} else {
+				score -= min(maxGapPenalty, (i-prev-1)*penaltyGap)
+			}
+		}
+		pos = append(pos, i)
+		prev = i
+		ti++
+	}
+	if ti < len(tok) {
+		return 0, nil, false
+	}
+	return score, pos, true
+}
@@ -0,0 +1,64 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchTokens(t *testing.T) {
+	m := NewMatcher(false)
+
+	res, ok := m.Match("walk dust", "A Walk in the Dust")
+	if !ok {
+		t.Fatalf("expected token match")
+	}
+	if want := []int{2, 3, 4, 5, 14, 15, 16, 17}; !reflect.DeepEqual(res.Positions, want) {
+		t.Fatalf("positions = %v, want %v", res.Positions, want)
+	}
+
+	if _, ok := m.Match("walk moon", "A Walk in the Dust"); ok {
+		t.Fatalf("every token must match")
+	}
+	if res, ok := m.Match("", "anything"); !ok || res.Score != 0 {
+		t.Fatalf("empty pattern should match with zero score")
+	}
+}
+
+func TestMatchScoresTightMatchesHigher(t *testing.T) {
+	m := NewMatcher(false)
+
+	tight, ok := m.Match("dust", "A Walk in the Dust")
+	if !ok {
+		t.Fatalf("expected match")
+	}
+	loose, ok := m.Match("dust", "Dawn under stars tonight")
+	if !ok {
+		t.Fatalf("expected subsequence match")
+	}
+	if tight.Score <= loose.Score {
+		t.Fatalf("contiguous word match scored %d, scattered match %d", tight.Score, loose.Score)
+	}
+}
+
+func TestMatchTransliterated(t *testing.T) {
+	plain := NewMatcher(false)
+	if _, ok := plain.Match("sai ren", "塞壬唱片-MSR"); ok {
+		t.Fatalf("pinyin should not match without transliteration")
+	}
+
+	m := NewMatcher(true)
+	res, ok := m.Match("sairen", "塞壬唱片-MSR")
+	if !ok {
+		t.Fatalf("expected pinyin match")
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(res.Positions, want) {
+		t.Fatalf("positions = %v, want %v", res.Positions, want)
+	}
+
+	if _, ok := m.Match("changpian msr", "塞壬唱片-MSR"); !ok {
+		t.Fatalf("expected mixed pinyin and latin match")
+	}
+	if _, ok := m.Match("sakura", "サクラ"); !ok {
+		t.Fatalf("expected romaji match for katakana")
+	}
+}
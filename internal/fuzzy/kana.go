@@ -0,0 +1,34 @@
+package fuzzy
+
+// hiraganaRomaji spells hiragana in Hepburn romaji. Small kana and the
+// sokuon are folded into their full-size readings, which is close enough for
+// search.
+var hiraganaRomaji = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo", 'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "wi", 'ゑ': "we", 'を': "wo", 'ん': "n",
+	'ゔ': "vu", 'っ': "tsu",
+}
+
+// kanaRomaji returns the romaji reading of a hiragana or katakana rune, or ""
+// for marks without a reading such as the long vowel mark.
+func kanaRomaji(r rune) string {
+	// Katakana mirror hiragana 0x60 code points higher.
+	if r >= 'ァ' && r <= 'ヴ' {
+		r -= 0x60
+	}
+	return hiraganaRomaji[r]
+}
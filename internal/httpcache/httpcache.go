@@ -0,0 +1,102 @@
+// Package httpcache persists HTTP response bodies with their validators so
+// API responses can be reused within a TTL and revalidated with
+// If-None-Match / If-Modified-Since afterwards.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry is one cached response.
+type Entry struct {
+	URL          string          `json:"url"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"lastModified,omitempty"`
+	StoredAt     time.Time       `json:"storedAt"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// Fresh reports whether e was stored less than ttl before now.
+func (e Entry) Fresh(ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(e.StoredAt) < ttl
+}
+
+// Store keeps one JSON file per URL in a directory, so concurrent workers
+// never rewrite each other's entries.
+type Store struct {
+	dir string
+}
+
+// New creates a store in dir. The directory is created on first write.
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the cache directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Get returns the entry for url. Missing and unreadable entries are
+// reported as absent; a corrupt entry is simply fetched again.
+func (s *Store) Get(url string) (Entry, bool) {
+	b, err := os.ReadFile(s.path(url))
+	if err != nil {
+		return Entry{}, false
+	}
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil || e.URL != url {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// Put writes the entry for e.URL atomically.
+func (s *Store) Put(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal cache entry: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("create response cache dir: %w", err)
+	}
+
+	path := s.path(e.URL)
+	tmp, err := os.CreateTemp(s.dir, filepath.Base(path)+".tmp.*")
+	if err != nil {
+		return fmt.Errorf("create temporary cache entry: %w", err)
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("write temporary cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("close temporary cache entry: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("atomic replace cache entry: %w", err)
+	}
+	return nil
+}
+
+// Touch marks the entry for url as stored now, after a successful
+// revalidation.
+func (s *Store) Touch(e Entry, now time.Time) error {
+	e.StoredAt = now.UTC()
+	return s.Put(e)
+}
+
+func (s *Store) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:16])+".json")
+}
@@ -0,0 +1,54 @@
+package httpcache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "cache"))
+	if _, ok := s.Get("https://example.test/a"); ok {
+		t.Fatalf("empty store returned an entry")
+	}
+
+	stored := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	in := Entry{URL: "https://example.test/a", ETag: `"v1"`, StoredAt: stored, Body: []byte(`{"data":1}`)}
+	if err := s.Put(in); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	out, ok := s.Get(in.URL)
+	if !ok || out.ETag != in.ETag || string(out.Body) != string(in.Body) || !out.StoredAt.Equal(stored) {
+		t.Fatalf("Get = %+v, %v", out, ok)
+	}
+	if _, ok := s.Get("https://example.test/b"); ok {
+		t.Fatalf("entry returned for another URL")
+	}
+
+	if err := s.Touch(out, stored.Add(time.Hour)); err != nil {
+		t.Fatalf("Touch failed: %v", err)
+	}
+	if touched, _ := s.Get(in.URL); !touched.StoredAt.Equal(stored.Add(time.Hour)) {
+		t.Fatalf("Touch did not update StoredAt: %s", touched.StoredAt)
+	}
+}
+
+func TestStoreIgnoresCorruptEntries(t *testing.T) {
+	s := New(t.TempDir())
+	url := "https://example.test/a"
+	if err := os.WriteFile(s.path(url), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get(url); ok {
+		t.Fatalf("corrupt entry was returned")
+	}
+}
+
+func TestEntryFreshness(t *testing.T) {
+	now := time.Now()
+	e := Entry{StoredAt: now.Add(-time.Hour)}
+	if !e.Fresh(2*time.Hour, now) || e.Fresh(30*time.Minute, now) || e.Fresh(0, now) {
+		t.Fatalf("unexpected freshness for an entry stored an hour ago")
+	}
+}
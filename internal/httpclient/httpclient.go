@@ -0,0 +1,125 @@
+// Package httpclient builds the HTTP transport shared by API calls and
+// downloads: connection pooling, timeouts per phase, proxies and custom CAs.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent identifies requests when Options.UserAgent is empty.
+const DefaultUserAgent = "msr-archiver"
+
+// Options configures the transport. Zero durations and limits disable the
+// corresponding bound.
+type Options struct {
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+
+	MaxConnsPerHost     int
+	MaxIdleConnsPerHost int
+
+	// Proxy is an http, https or socks5 proxy URL. Empty uses the
+	// HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment.
+	Proxy string
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile    string
+	UserAgent string
+}
+
+// NewTransport builds a RoundTripper from opts.
+func NewTransport(opts Options) (http.RoundTripper, error) {
+	proxy := http.ProxyFromEnvironment
+	if strings.TrimSpace(opts.Proxy) != "" {
+		u, err := parseProxy(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CAFile != "" {
+		pool, err := loadCAFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	dialer := &net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}
+	base := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opts.ResponseHeaderTimeout,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxIdleConns:          100,
+		ExpectContinueTimeout: time.Second,
+		// A custom dialer and TLS config disable HTTP/2 unless requested.
+		ForceAttemptHTTP2: true,
+	}
+
+	userAgent := opts.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	return &transport{base: base, userAgent: userAgent}, nil
+}
+
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: scheme must be http, https or socks5", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", raw)
+	}
+	return u, nil
+}
+
+func loadCAFile(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA bundle %s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// transport sets the User-Agent on requests that do not carry one.
+type transport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
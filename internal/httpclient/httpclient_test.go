@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTransportSetsUserAgent(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.UserAgent())
+	}))
+	defer srv.Close()
+
+	rt, err := NewTransport(Options{UserAgent: "archiver-test/1.0"})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	client := &http.Client{Transport: rt}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("User-Agent", "explicit")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(got) != 2 || got[0] != "archiver-test/1.0" || got[1] != "explicit" {
+		t.Fatalf("User-Agent headers = %v", got)
+	}
+}
+
+func TestCAFileTrustsServer(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	rt, _ := NewTransport(Options{})
+	if _, err := (&http.Client{Transport: rt}).Get(srv.URL); err == nil {
+		t.Fatalf("expected an untrusted certificate error without the CA")
+	}
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(block), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rt, err := NewTransport(Options{CAFile: caPath})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET with CA bundle failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewTransportRejectsBadOptions(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, opts := range map[string]Options{
+		"proxy scheme": {Proxy: "ftp://proxy:21"},
+		"proxy host":   {Proxy: "socks5://"},
+		"missing CA":   {CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		"empty CA":     {CAFile: empty},
+	} {
+		if _, err := NewTransport(opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := NewTransport(Options{Proxy: "socks5://127.0.0.1:1080"}); err != nil {
+		t.Errorf("socks5 proxy rejected: %v", err)
+	}
+}
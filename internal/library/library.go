@@ -0,0 +1,296 @@
+package library
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+// File names written at the output root.
+const (
+	IndexJSONName   = "index.json"
+	IndexHTMLName   = "index.html"
+	NewPlaylistName = "new.m3u8"
+)
+
+// Options controls playlist and index generation.
+type Options struct {
+	OutputDir string
+	// Catalog provides album order, cover URLs and artists.
+	Catalog []model.Album
+	// Albums are the archived albums recorded in library state.
+	Albums []state.LibraryAlbum
+	// NewSince enables new.m3u8 with tracks downloaded at or after this
+	// time. Zero disables it.
+	NewSince time.Time
+}
+
+// Summary reports what Generate wrote.
+type Summary struct {
+	Playlists int
+	CueSheets int
+	Albums    int
+	Tracks    int
+	NewTracks int
+}
+
+// Index is the library-wide catalog written to index.json.
+type Index struct {
+	GeneratedAt time.Time    `json:"generatedAt"`
+	Albums      []IndexAlbum `json:"albums"`
+}
+
+// IndexAlbum is one archived album in Index.
+type IndexAlbum struct {
+	CID             string               `json:"cid"`
+	Name            string               `json:"name"`
+	Artists         []string             `json:"artists,omitempty"`
+	CoverURL        string               `json:"coverUrl,omitempty"`
+	Dir             string               `json:"dir"`
+	Playlist        string               `json:"playlist"`
+	CueSheet        string               `json:"cueSheet"`
+	CompletedAt     time.Time            `json:"completedAt"`
+	DurationSeconds float64              `json:"durationSeconds"`
+	Tracks          []state.LibraryTrack `json:"tracks"`
+}
+
+// Generate writes per-album playlists and CUE sheets, index.json, index.html
+// and optionally new.m3u8 from library state.
+func Generate(opts Options) (Summary, error) {
+	idx := BuildIndex(opts.Catalog, opts.Albums, time.Now().UTC())
+
+	var sum Summary
+	for _, a := range idx.Albums {
+		if err := writeFile(filepath.Join(opts.OutputDir, a.Playlist), AlbumPlaylist(a)); err != nil {
+			return sum, fmt.Errorf("write playlist for %q: %w", a.Name, err)
+		}
+		sum.Playlists++
+		if err := writeFile(filepath.Join(opts.OutputDir, a.CueSheet), AlbumCue(a)); err != nil {
+			return sum, fmt.Errorf("write cue sheet for %q: %w", a.Name, err)
+		}
+		sum.CueSheets++
+		sum.Albums++
+		sum.Tracks += len(a.Tracks)
+	}
+
+	b, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return sum, fmt.Errorf("marshal library index: %w", err)
+	}
+	if err := writeFile(filepath.Join(opts.OutputDir, IndexJSONName), b); err != nil {
+		return sum, fmt.Errorf("write library index: %w", err)
+	}
+
+	page, err := IndexHTML(idx)
+	if err != nil {
+		return sum, fmt.Errorf("render library index html: %w", err)
+	}
+	if err := writeFile(filepath.Join(opts.OutputDir, IndexHTMLName), page); err != nil {
+		return sum, fmt.Errorf("write library index html: %w", err)
+	}
+
+	if !opts.NewSince.IsZero() {
+		// A run without new tracks removes the previous run's list rather than
+		// leaving it to look current.
+		newPath := filepath.Join(opts.OutputDir, NewPlaylistName)
+		playlist, n := NewPlaylist(idx, opts.NewSince)
+		if n > 0 {
+			err = writeFile(newPath, playlist)
+		} else if err = os.Remove(newPath); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			return sum, fmt.Errorf("write new-tracks playlist: %w", err)
+		}
+		sum.NewTracks = n
+	}
+
+	return sum, nil
+}
+
+// BuildIndex orders archived albums by catalog position. Albums that are no
+// longer in the catalog are appended in library order.
+func BuildIndex(catalog []model.Album, albums []state.LibraryAlbum, now time.Time) Index {
+	byCID := make(map[string]state.LibraryAlbum, len(albums))
+	for _, a := range albums {
+		byCID[a.CID] = a
+	}
+
+	idx := Index{GeneratedAt: now, Albums: make([]IndexAlbum, 0, len(albums))}
+	seen := make(map[string]struct{}, len(albums))
+	for _, c := range catalog {
+		a, ok := byCID[c.CID]
+		if !ok {
+			continue
+		}
+		seen[c.CID] = struct{}{}
+		idx.Albums = append(idx.Albums, indexAlbum(a, c.CoverURL))
+	}
+	for _, a := range albums {
+		if _, ok := seen[a.CID]; ok {
+			continue
+		}
+		idx.Albums = append(idx.Albums, indexAlbum(a, ""))
+	}
+	return idx
+}
+
+func indexAlbum(a state.LibraryAlbum, coverURL string) IndexAlbum {
+	var total float64
+	for _, t := range a.Tracks {
+		total += t.DurationSeconds
+	}
+	base := filepath.Join(a.Dir, filepath.Base(a.Dir))
+	return IndexAlbum{
+		CID:             a.CID,
+		Name:            a.Name,
+		Artists:         a.Artists,
+		CoverURL:        coverURL,
+		Dir:             a.Dir,
+		Playlist:        filepath.ToSlash(base + ".m3u8"),
+		CueSheet:        filepath.ToSlash(base + ".cue"),
+		CompletedAt:     a.CompletedAt,
+		DurationSeconds: total,
+		Tracks:          a.Tracks,
+	}
+}
+
+// AlbumPlaylist renders an extended M3U8 playlist with paths relative to the
+// album directory, in track order.
+func AlbumPlaylist(a IndexAlbum) []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&b, "#PLAYLIST:%s\n", a.Name)
+	for _, t := range a.Tracks {
+		writeEntry(&b, t, filepath.Base(t.Path))
+	}
+	return b.Bytes()
+}
+
+// AlbumCue renders a CUE sheet with one FILE per track, relative to the
+// album directory, so players that read CUE sheets show the album in order.
+func AlbumCue(a IndexAlbum) []byte {
+	var b bytes.Buffer
+	if len(a.Artists) > 0 {
+		fmt.Fprintf(&b, "PERFORMER %s\n", cueString(strings.Join(a.Artists, ", ")))
+	}
+	fmt.Fprintf(&b, "TITLE %s\n", cueString(a.Name))
+	for i, t := range a.Tracks {
+		fileType := "WAVE"
+		if strings.EqualFold(path.Ext(t.Path), ".mp3") {
+			fileType = "MP3"
+		}
+		fmt.Fprintf(&b, "FILE %s %s\n", cueString(path.Base(t.Path)), fileType)
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(&b, "    TITLE %s\n", cueString(t.Title))
+		if len(t.Artists) > 0 {
+			fmt.Fprintf(&b, "    PERFORMER %s\n", cueString(strings.Join(t.Artists, ", ")))
+		}
+		b.WriteString("    INDEX 01 00:00:00\n")
+	}
+	return b.Bytes()
+}
+
+// cueString quotes s for a CUE sheet, which has no escape for quotes.
+func cueString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
+
+// NewPlaylist renders an M3U8 playlist of tracks downloaded at or after
+// since, with paths relative to the output root. Tracks kept from earlier
+// runs when an album is resumed or refreshed are not new. It returns the
+// number of tracks included.
+func NewPlaylist(idx Index, since time.Time) ([]byte, int) {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	n := 0
+	for _, a := range idx.Albums {
+		for _, t := range a.Tracks {
+			if t.AddedAt.Before(since) {
+				continue
+			}
+			writeEntry(&b, t, t.Path)
+			n++
+		}
+	}
+	return b.Bytes(), n
+}
+
+func writeEntry(b *bytes.Buffer, t state.LibraryTrack, path string) {
+	seconds := -1
+	if t.DurationSeconds > 0 {
+		seconds = int(math.Round(t.DurationSeconds))
+	}
+	title := t.Title
+	if len(t.Artists) > 0 {
+		title = strings.Join(t.Artists, ", ") + " - " + t.Title
+	}
+	fmt.Fprintf(b, "#EXTINF:%d,%s\n%s\n", seconds, title, filepath.ToSlash(path))
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"join":     strings.Join,
+	"duration": formatDuration,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>MSR Archive</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td { padding: 0.1em 0.8em; }
+</style>
+</head>
+<body>
+<h1>MSR Archive</h1>
+<p>{{len .Albums}} albums, generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}</p>
+{{range .Albums}}
+<h2><a href="{{.Playlist}}">{{.Name}}</a></h2>
+<p>{{join .Artists ", "}} &middot; {{len .Tracks}} tracks &middot; {{duration .DurationSeconds}}</p>
+<table>
+{{range .Tracks}}<tr><td>{{.Number}}</td><td><a href="{{.Path}}">{{.Title}}</a></td><td>{{duration .DurationSeconds}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// IndexHTML renders a static HTML listing of the index.
+func IndexHTML(idx Index) ([]byte, error) {
+	var b bytes.Buffer
+	if err := indexTemplate.Execute(&b, idx); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func formatDuration(seconds float64) string {
+	if seconds <= 0 {
+		return "--:--"
+	}
+	total := int(math.Round(seconds))
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, (total/60)%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
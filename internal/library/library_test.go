@@ -0,0 +1,179 @@
+package library
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+func sampleAlbums(completedAt time.Time) []state.LibraryAlbum {
+	return []state.LibraryAlbum{
+		{
+			CID:         "a2",
+			Name:        "Second",
+			Dir:         "Second",
+			CompletedAt: completedAt,
+			Tracks: []state.LibraryTrack{
+				{Number: 1, Title: "Intro", Artists: []string{"MSR"}, Path: "Second/Intro.flac", DurationSeconds: 61.4, AddedAt: completedAt},
+				{Number: 2, Title: "Outro", Path: "Second/Outro.mp3", AddedAt: completedAt},
+			},
+		},
+		{
+			CID:         "a1",
+			Name:        "First",
+			Dir:         "First",
+			CompletedAt: completedAt.Add(-48 * time.Hour),
+			Tracks: []state.LibraryTrack{
+				{Number: 1, Title: "Only", Path: "First/Only.flac", DurationSeconds: 3700, AddedAt: completedAt.Add(-48 * time.Hour)},
+			},
+		},
+	}
+}
+
+func TestBuildIndexUsesCatalogOrder(t *testing.T) {
+	catalog := []model.Album{{CID: "a1", Name: "First", CoverURL: "https://cover/1"}, {CID: "a2", Name: "Second"}}
+	idx := BuildIndex(catalog, sampleAlbums(time.Now()), time.Now())
+
+	if len(idx.Albums) != 2 || idx.Albums[0].CID != "a1" || idx.Albums[1].CID != "a2" {
+		t.Fatalf("unexpected album order: %+v", idx.Albums)
+	}
+	if idx.Albums[0].CoverURL != "https://cover/1" {
+		t.Fatalf("expected cover from catalog, got %q", idx.Albums[0].CoverURL)
+	}
+	if idx.Albums[0].Playlist != "First/First.m3u8" {
+		t.Fatalf("unexpected playlist path: %q", idx.Albums[0].Playlist)
+	}
+}
+
+func TestBuildIndexKeepsAlbumsMissingFromCatalog(t *testing.T) {
+	idx := BuildIndex([]model.Album{{CID: "a2"}}, sampleAlbums(time.Now()), time.Now())
+	if len(idx.Albums) != 2 || idx.Albums[1].CID != "a1" {
+		t.Fatalf("expected removed album appended, got %+v", idx.Albums)
+	}
+}
+
+func TestAlbumPlaylist(t *testing.T) {
+	idx := BuildIndex(nil, sampleAlbums(time.Now()), time.Now())
+	got := string(AlbumPlaylist(idx.Albums[0]))
+
+	want := "#EXTM3U\n#PLAYLIST:Second\n#EXTINF:61,MSR - Intro\nIntro.flac\n#EXTINF:-1,Outro\nOutro.mp3\n"
+	if got != want {
+		t.Fatalf("unexpected playlist:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	runStarted := time.Now().Add(-time.Hour)
+
+	sum, err := Generate(Options{
+		OutputDir: dir,
+		Albums:    sampleAlbums(time.Now()),
+		NewSince:  runStarted,
+	})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if sum.Playlists != 2 || sum.CueSheets != 2 || sum.Tracks != 3 || sum.NewTracks != 2 {
+		t.Fatalf("unexpected summary: %+v", sum)
+	}
+
+	for _, p := range []string{"Second/Second.m3u8", "First/First.m3u8", "Second/Second.cue", IndexHTMLName, NewPlaylistName} {
+		if _, err := os.Stat(filepath.Join(dir, p)); err != nil {
+			t.Fatalf("expected %s to exist: %v", p, err)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, IndexJSONName))
+	if err != nil {
+		t.Fatalf("read index.json: %v", err)
+	}
+	var idx Index
+	if err := json.Unmarshal(b, &idx); err != nil {
+		t.Fatalf("parse index.json: %v", err)
+	}
+	if len(idx.Albums) != 2 {
+		t.Fatalf("unexpected index albums: %+v", idx.Albums)
+	}
+
+	newList, err := os.ReadFile(filepath.Join(dir, NewPlaylistName))
+	if err != nil {
+		t.Fatalf("read new playlist: %v", err)
+	}
+	if !strings.Contains(string(newList), "Second/Intro.flac") || strings.Contains(string(newList), "First/Only.flac") {
+		t.Fatalf("new playlist should only include albums completed this run:\n%s", newList)
+	}
+}
+
+func TestNewPlaylistSkipsTracksKeptFromEarlierRuns(t *testing.T) {
+	runStarted := time.Now().Add(-time.Hour)
+	albums := sampleAlbums(time.Now())
+	// A resumed album is recorded again this run, but only Outro is new.
+	albums[0].Tracks[0].AddedAt = runStarted.Add(-24 * time.Hour)
+
+	got, n := NewPlaylist(BuildIndex(nil, albums, time.Now()), runStarted)
+	if n != 1 || strings.Contains(string(got), "Intro") || !strings.Contains(string(got), "Second/Outro.mp3") {
+		t.Fatalf("NewPlaylist = %d tracks:\n%s", n, got)
+	}
+}
+
+func TestGenerateRemovesStaleNewPlaylist(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, NewPlaylistName)
+	if err := os.WriteFile(stale, []byte("#EXTM3U\nold.flac\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := Generate(Options{OutputDir: dir, Albums: sampleAlbums(time.Now().Add(-72 * time.Hour)), NewSince: time.Now()})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if sum.NewTracks != 0 {
+		t.Fatalf("NewTracks = %d, want 0", sum.NewTracks)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("stale %s should be removed, got err=%v", NewPlaylistName, err)
+	}
+}
+
+func TestAlbumCue(t *testing.T) {
+	albums := sampleAlbums(time.Now())
+	albums[0].Artists = []string{"MSR"}
+	albums[0].Tracks[1].Title = `Say "Hi"`
+	idx := BuildIndex(nil, albums, time.Now())
+	if idx.Albums[0].CueSheet != "Second/Second.cue" {
+		t.Fatalf("unexpected cue sheet path: %q", idx.Albums[0].CueSheet)
+	}
+	got := string(AlbumCue(idx.Albums[0]))
+
+	want := `PERFORMER "MSR"
+TITLE "Second"
+FILE "Intro.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "Intro"
+    PERFORMER "MSR"
+    INDEX 01 00:00:00
+FILE "Outro.mp3" MP3
+  TRACK 02 AUDIO
+    TITLE "Say 'Hi'"
+    INDEX 01 00:00:00
+`
+	if got != want {
+		t.Fatalf("unexpected cue sheet:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	cases := map[float64]string{0: "--:--", 61.4: "1:01", 3700: "1:01:40"}
+	for in, want := range cases {
+		if got := formatDuration(in); got != want {
+			t.Fatalf("formatDuration(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
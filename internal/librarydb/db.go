@@ -0,0 +1,432 @@
+// Package librarydb keeps archive state in a single SQLite database: the
+// album catalog, songs, archived files, completion markers, and the history
+// of runs and their events. It replaces completed_albums.json and
+// library.json, which were rewritten in full on every update.
+package librarydb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+// FileName is the default database file name in the output directory.
+const FileName = "library.db"
+
+// DB is an open library database. It implements the completion and library
+// state used by the archiver.
+type DB struct {
+	sql      *sql.DB
+	path     string
+	readOnly bool
+	// created is set when Open created the database file.
+	created bool
+
+	// completed caches completion markers; IsCompleted runs for every
+	// album on every picker render.
+	mu        sync.Mutex
+	completed map[string]struct{}
+}
+
+// Open opens or creates the database at path and applies pending
+// migrations.
+func Open(ctx context.Context, path string) (*DB, error) {
+	_, statErr := os.Stat(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create library database dir: %w", err)
+	}
+	db, err := open(ctx, path, false)
+	if err != nil {
+		return nil, err
+	}
+	db.created = os.IsNotExist(statErr)
+	if err := db.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := db.loadCompleted(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenReadOnly opens an existing database without modifying it. Writes,
+// including migrations, fail.
+func OpenReadOnly(ctx context.Context, path string) (*DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("open library database: %w", err)
+	}
+	db, err := open(ctx, path, true)
+	if err != nil {
+		return nil, err
+	}
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version != len(migrations) {
+		db.Close()
+		return nil, fmt.Errorf("library database %s has schema version %d, want %d; run a download to migrate it", path, version, len(migrations))
+	}
+	if err := db.loadCompleted(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func open(ctx context.Context, path string, readOnly bool) (*DB, error) {
+	q := url.Values{}
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "foreign_keys(1)")
+	if readOnly {
+		q.Set("mode", "ro")
+		q.Add("_pragma", "query_only(1)")
+	} else {
+		q.Add("_pragma", "journal_mode(WAL)")
+		q.Add("_pragma", "synchronous(NORMAL)")
+	}
+	// A relative path would be written as file://dir/..., which SQLite
+	// reads as a URI authority and rejects.
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolve library database path %s: %w", path, err)
+	}
+	uriPath := filepath.ToSlash(abs)
+	if !strings.HasPrefix(uriPath, "/") {
+		uriPath = "/" + uriPath
+	}
+	dsn := (&url.URL{Scheme: "file", Path: uriPath, RawQuery: q.Encode()}).String()
+
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open library database %s: %w", path, err)
+	}
+	// One connection serializes writers from concurrent album workers
+	// instead of failing them with SQLITE_BUSY.
+	conn.SetMaxOpenConns(1)
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("open library database %s: %w", path, err)
+	}
+	return &DB{sql: conn, path: path, readOnly: readOnly, completed: make(map[string]struct{})}, nil
+}
+
+// Remove deletes the database file at path together with its -wal and -shm
+// files. Missing files are ignored.
+func Remove(path string) error {
+	var errs []error
+	for _, p := range []string{path, path + "-wal", path + "-shm"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	return db.sql.Close()
+}
+
+// Path returns the database file path.
+func (db *DB) Path() string {
+	return db.path
+}
+
+// Created reports whether Open created a new database file.
+func (db *DB) Created() bool {
+	return db.created
+}
+
+func (db *DB) loadCompleted(ctx context.Context) error {
+	rows, err := db.sql.QueryContext(ctx, `SELECT name FROM completed_albums`)
+	if err != nil {
+		return fmt.Errorf("read completed albums: %w", err)
+	}
+	defer rows.Close()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("read completed albums: %w", err)
+		}
+		db.completed[name] = struct{}{}
+	}
+	return rows.Err()
+}
+
+// IsCompleted reports whether an album has already been processed.
+func (db *DB) IsCompleted(albumName string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, ok := db.completed[albumName]
+	return ok
+}
+
+// MarkCompleted records an album as completed.
+func (db *DB) MarkCompleted(albumName string) error {
+	if db.IsCompleted(albumName) {
+		return nil
+	}
+	if _, err := db.sql.Exec(
+		`INSERT INTO completed_albums (name, completed_at) VALUES (?, ?) ON CONFLICT (name) DO NOTHING`,
+		albumName, formatTime(time.Now()),
+	); err != nil {
+		return fmt.Errorf("mark album %q completed: %w", albumName, err)
+	}
+
+	db.mu.Lock()
+	db.completed[albumName] = struct{}{}
+	db.mu.Unlock()
+	return nil
+}
+
+// SaveCatalog records catalog metadata of albums. Archive state of known
+// albums is kept.
+func (db *DB) SaveCatalog(ctx context.Context, albums []model.Album) error {
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		now := formatTime(time.Now())
+		for _, a := range albums {
+			if err := upsertAlbum(ctx, tx, a.CID, a.Name, a.Artistes, a.CoverURL, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveSongs records the song list of an album from the catalog.
+func (db *DB) SaveSongs(ctx context.Context, albumCID string, songs []model.Song) error {
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		for i, s := range songs {
+			if err := upsertSong(ctx, tx, s.CID, albumCID, i+1, s.Name, s.Artistes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Album returns the archived album for a CID. Albums only known from the
+// catalog are not returned.
+func (db *DB) Album(cid string) (state.LibraryAlbum, bool) {
+	albums, err := db.libraryAlbums(context.Background(), cid)
+	if err != nil || len(albums) == 0 {
+		return state.LibraryAlbum{}, false
+	}
+	return albums[0], true
+}
+
+// Albums returns all archived albums sorted by CID.
+func (db *DB) Albums() []state.LibraryAlbum {
+	albums, _ := db.libraryAlbums(context.Background(), "")
+	return albums
+}
+
+// PutAlbum records an archived album and replaces its file list.
+func (db *DB) PutAlbum(album state.LibraryAlbum) error {
+	ctx := context.Background()
+	return db.tx(ctx, func(tx *sql.Tx) error {
+		now := formatTime(time.Now())
+		if err := upsertAlbum(ctx, tx, album.CID, album.Name, album.Artists, "", now); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE albums SET dir = ?, archived_at = ? WHERE cid = ?`,
+			album.Dir, formatTime(album.CompletedAt), album.CID,
+		); err != nil {
+			return fmt.Errorf("record album %q: %w", album.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM files WHERE album_cid = ?`, album.CID); err != nil {
+			return fmt.Errorf("record album %q: %w", album.Name, err)
+		}
+		for _, t := range album.Tracks {
+			if err := upsertSong(ctx, tx, t.CID, album.CID, t.Number, t.Title, t.Artists); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO files (song_cid, album_cid, path, file_type, duration_seconds, recorded_at)
+				 VALUES (?, ?, ?, ?, ?, ?)
+				 ON CONFLICT (path) DO UPDATE SET song_cid = excluded.song_cid, album_cid = excluded.album_cid,
+				   file_type = excluded.file_type, duration_seconds = excluded.duration_seconds, recorded_at = excluded.recorded_at`,
+				t.CID, album.CID, t.Path, t.FileType, t.DurationSeconds, recordedAt(t, now),
+			); err != nil {
+				return fmt.Errorf("record track %q: %w", t.Title, err)
+			}
+		}
+		return nil
+	})
+}
+
+// recordedAt is the files.recorded_at value for t: when it was downloaded,
+// or now for tracks recorded before that was tracked.
+func recordedAt(t state.LibraryTrack, now string) string {
+	if t.AddedAt.IsZero() {
+		return now
+	}
+	return formatTime(t.AddedAt)
+}
+
+// libraryAlbums loads archived albums, all of them when cid is empty.
+func (db *DB) libraryAlbums(ctx context.Context, cid string) ([]state.LibraryAlbum, error) {
+	query := `SELECT cid, name, artists, dir, archived_at FROM albums WHERE archived_at IS NOT NULL`
+	var args []any
+	if cid != "" {
+		query += ` AND cid = ?`
+		args = append(args, cid)
+	}
+	query += ` ORDER BY cid`
+
+	rows, err := db.sql.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("read library albums: %w", err)
+	}
+	var albums []state.LibraryAlbum
+	byCID := make(map[string]int)
+	for rows.Next() {
+		var a state.LibraryAlbum
+		var artists, archivedAt string
+		if err := rows.Scan(&a.CID, &a.Name, &artists, &a.Dir, &archivedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("read library albums: %w", err)
+		}
+		a.Artists = decodeList(artists)
+		a.CompletedAt = parseTime(archivedAt)
+		a.Tracks = []state.LibraryTrack{}
+		byCID[a.CID] = len(albums)
+		albums = append(albums, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read library albums: %w", err)
+	}
+	if len(albums) == 0 {
+		return albums, nil
+	}
+
+	query = `SELECT f.album_cid, s.number, f.song_cid, s.name, s.artists, f.path, f.file_type, f.duration_seconds, f.recorded_at
+		FROM files f JOIN songs s ON s.cid = f.song_cid`
+	args = nil
+	if cid != "" {
+		query += ` WHERE f.album_cid = ?`
+		args = append(args, cid)
+	}
+	rows, err = db.sql.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("read library tracks: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var albumCID, artists, recorded string
+		var t state.LibraryTrack
+		if err := rows.Scan(&albumCID, &t.Number, &t.CID, &t.Title, &artists, &t.Path, &t.FileType, &t.DurationSeconds, &recorded); err != nil {
+			return nil, fmt.Errorf("read library tracks: %w", err)
+		}
+		t.Artists = decodeList(artists)
+		t.AddedAt = parseTime(recorded)
+		if i, ok := byCID[albumCID]; ok {
+			albums[i].Tracks = append(albums[i].Tracks, t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read library tracks: %w", err)
+	}
+	for i := range albums {
+		sort.Slice(albums[i].Tracks, func(a, b int) bool { return albums[i].Tracks[a].Number < albums[i].Tracks[b].Number })
+	}
+	return albums, nil
+}
+
+func upsertAlbum(ctx context.Context, tx *sql.Tx, cid, name string, artists []string, coverURL, now string) error {
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO albums (cid, name, artists, cover_url, updated_at) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (cid) DO UPDATE SET name = excluded.name, artists = excluded.artists,
+		   cover_url = CASE WHEN excluded.cover_url = '' THEN albums.cover_url ELSE excluded.cover_url END,
+		   updated_at = excluded.updated_at`,
+		cid, name, encodeList(artists), coverURL, now,
+	); err != nil {
+		return fmt.Errorf("record album %q: %w", name, err)
+	}
+	return nil
+}
+
+func upsertSong(ctx context.Context, tx *sql.Tx, cid, albumCID string, number int, name string, artists []string) error {
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO songs (cid, album_cid, number, name, artists) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (cid) DO UPDATE SET album_cid = excluded.album_cid, number = excluded.number,
+		   name = excluded.name, artists = excluded.artists`,
+		cid, albumCID, number, name, encodeList(artists),
+	); err != nil {
+		return fmt.Errorf("record song %q: %w", name, err)
+	}
+	return nil
+}
+
+// tx runs fn in a transaction.
+func (db *DB) tx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := db.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// encodeList stores string lists as JSON arrays, which SQLite's json_each
+// can query.
+func encodeList(values []string) string {
+	if len(values) == 0 {
+		return "[]"
+	}
+	b, _ := json.Marshal(values)
+	return string(b)
+}
+
+func decodeList(raw string) []string {
+	var values []string
+	if err := json.Unmarshal([]byte(raw), &values); err != nil || len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,321 @@
+package librarydb
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"msr-archiver/internal/catalog"
+	"msr-archiver/internal/model"
+	"msr-archiver/internal/state"
+)
+
+func openTestDB(t *testing.T) (*DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	db, err := Open(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+func TestOpenMigratesOnce(t *testing.T) {
+	ctx := context.Background()
+	db, path := openTestDB(t)
+	if !db.Created() {
+		t.Fatalf("new database not reported as created")
+	}
+	if v, err := db.SchemaVersion(ctx); err != nil || v != len(migrations) {
+		t.Fatalf("SchemaVersion = %d, %v", v, err)
+	}
+	db.Close()
+
+	again, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer again.Close()
+	if again.Created() {
+		t.Fatalf("existing database reported as created")
+	}
+	if v, _ := again.SchemaVersion(ctx); v != len(migrations) {
+		t.Fatalf("SchemaVersion after reopen = %d", v)
+	}
+}
+
+func TestOpenRelativePath(t *testing.T) {
+	ctx := context.Background()
+	t.Chdir(t.TempDir())
+	path := filepath.Join("MonsterSiren", FileName)
+	if err := os.Mkdir("MonsterSiren", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := db.MarkCompleted("Album"); err != nil {
+		t.Fatalf("MarkCompleted failed: %v", err)
+	}
+	db.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("database not created at %s: %v", path, err)
+	}
+
+	ro, err := OpenReadOnly(ctx, path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly failed: %v", err)
+	}
+	defer ro.Close()
+	if !ro.IsCompleted("Album") {
+		t.Fatalf("completion not persisted through relative path")
+	}
+}
+
+func TestCompletionPersists(t *testing.T) {
+	db, path := openTestDB(t)
+	if db.IsCompleted("Album") {
+		t.Fatalf("empty database reports a completed album")
+	}
+	if err := db.MarkCompleted("Album"); err != nil {
+		t.Fatalf("MarkCompleted failed: %v", err)
+	}
+	if err := db.MarkCompleted("Album"); err != nil {
+		t.Fatalf("second MarkCompleted failed: %v", err)
+	}
+	db.Close()
+
+	ro, err := OpenReadOnly(context.Background(), path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly failed: %v", err)
+	}
+	defer ro.Close()
+	if !ro.IsCompleted("Album") {
+		t.Fatalf("completion was not persisted")
+	}
+}
+
+func TestPutAlbumRoundTrip(t *testing.T) {
+	db, _ := openTestDB(t)
+	album := state.LibraryAlbum{
+		CID:         "a1",
+		Name:        "Album",
+		Artists:     []string{"Artist"},
+		Dir:         "Album",
+		CompletedAt: time.Date(2026, 4, 1, 8, 0, 0, 0, time.UTC),
+		Tracks: []state.LibraryTrack{
+			{Number: 2, CID: "s2", Title: "Second", Path: "Album/Second.mp3", FileType: ".mp3", DurationSeconds: 61.5, AddedAt: time.Date(2026, 4, 1, 7, 59, 0, 0, time.UTC)},
+			{Number: 1, CID: "s1", Title: "First", Artists: []string{"Guest"}, Path: "Album/First.flac", FileType: ".flac", AddedAt: time.Date(2026, 3, 30, 9, 0, 0, 0, time.UTC)},
+		},
+	}
+	if err := db.PutAlbum(album); err != nil {
+		t.Fatalf("PutAlbum failed: %v", err)
+	}
+
+	got, ok := db.Album("a1")
+	if !ok {
+		t.Fatalf("Album not found")
+	}
+	want := album
+	want.Tracks = []state.LibraryTrack{album.Tracks[1], album.Tracks[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Album = %+v\nwant %+v", got, want)
+	}
+
+	// Re-recording an album replaces its file list.
+	album.Tracks = album.Tracks[1:]
+	if err := db.PutAlbum(album); err != nil {
+		t.Fatalf("PutAlbum failed: %v", err)
+	}
+	if all := db.Albums(); len(all) != 1 || len(all[0].Tracks) != 1 {
+		t.Fatalf("Albums = %+v", all)
+	}
+
+	// Catalog-only albums are not part of the library.
+	if err := db.SaveCatalog(context.Background(), []model.Album{{CID: "a2", Name: "Other"}}); err != nil {
+		t.Fatalf("SaveCatalog failed: %v", err)
+	}
+	if _, ok := db.Album("a2"); ok || len(db.Albums()) != 1 {
+		t.Fatalf("catalog-only album reported as archived")
+	}
+}
+
+func TestImportJSON(t *testing.T) {
+	dir := t.TempDir()
+	cache := catalog.NewCache(filepath.Join(dir, "albums_cache.json"))
+	if err := cache.Save([]model.Album{{CID: "a1", Name: "Album"}, {CID: "a2", Name: "Other"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.PutSongs("a2", []model.Song{{CID: "s9", Name: "Song"}}); err != nil {
+		t.Fatal(err)
+	}
+	lib, err := state.NewLibraryStore(filepath.Join(dir, "library.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lib.PutAlbum(state.LibraryAlbum{CID: "a1", Name: "Album", Tracks: []state.LibraryTrack{
+		{Number: 1, CID: "s1", Title: "First", Path: "Album/First.flac", FileType: ".flac"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	completed, _ := json.Marshal([]string{"Album"})
+	if err := os.WriteFile(filepath.Join(dir, "completed_albums.json"), completed, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, _ := openTestDB(t)
+	src := ImportSources{
+		CompletedAlbums: filepath.Join(dir, "completed_albums.json"),
+		Library:         filepath.Join(dir, "library.json"),
+		AlbumCache:      filepath.Join(dir, "albums_cache.json"),
+	}
+	stats, err := db.ImportJSON(context.Background(), src)
+	if err != nil {
+		t.Fatalf("ImportJSON failed: %v", err)
+	}
+	if stats != (ImportStats{Catalog: 2, Songs: 1, Archived: 1, Completed: 1}) {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if !db.IsCompleted("Album") {
+		t.Fatalf("completion not imported")
+	}
+	if a, ok := db.Album("a1"); !ok || len(a.Tracks) != 1 || a.Tracks[0].Title != "First" {
+		t.Fatalf("library album = %+v, %v", a, ok)
+	}
+
+	// A second import upserts instead of duplicating.
+	if _, err := db.ImportJSON(context.Background(), src); err != nil {
+		t.Fatalf("second ImportJSON failed: %v", err)
+	}
+	res, err := db.Query(context.Background(), `SELECT (SELECT COUNT(*) FROM albums), (SELECT COUNT(*) FROM songs), (SELECT COUNT(*) FROM files)`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if got := res.Rows[0]; got[0] != int64(2) || got[1] != int64(2) || got[2] != int64(1) {
+		t.Fatalf("row counts = %v", got)
+	}
+}
+
+func TestImportSkipsMissingFiles(t *testing.T) {
+	db, _ := openTestDB(t)
+	dir := t.TempDir()
+	stats, err := db.ImportJSON(context.Background(), ImportSources{
+		CompletedAlbums: filepath.Join(dir, "completed_albums.json"),
+		Library:         filepath.Join(dir, "library.json"),
+		AlbumCache:      filepath.Join(dir, "albums_cache.json"),
+	})
+	if err != nil || !stats.Empty() {
+		t.Fatalf("ImportJSON = %+v, %v", stats, err)
+	}
+}
+
+func TestRunHistoryAndReadOnlyQuery(t *testing.T) {
+	ctx := context.Background()
+	db, path := openTestDB(t)
+	id, err := db.StartRun(ctx, 2, time.Now())
+	if err != nil {
+		t.Fatalf("StartRun failed: %v", err)
+	}
+	if err := db.AddEvent(ctx, Event{RunID: id, Type: "album_failed", AlbumCID: "a1", Album: "Album", Message: "boom"}); err != nil {
+		t.Fatalf("AddEvent failed: %v", err)
+	}
+	if err := db.FinishRun(ctx, id, 1, 1, "boom"); err != nil {
+		t.Fatalf("FinishRun failed: %v", err)
+	}
+	db.Close()
+
+	ro, err := OpenReadOnly(ctx, path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly failed: %v", err)
+	}
+	defer ro.Close()
+	res, err := ro.Query(ctx, `SELECT r.archived, r.failed, e.type, e.message FROM runs r JOIN events e ON e.run_id = r.id`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(res.Rows) != 1 || !reflect.DeepEqual(res.Columns, []string{"archived", "failed", "type", "message"}) {
+		t.Fatalf("result = %+v", res)
+	}
+	if row := res.Rows[0]; row[0] != int64(1) || row[1] != int64(1) || row[2] != "album_failed" || row[3] != "boom" {
+		t.Fatalf("row = %v", row)
+	}
+	if _, err := ro.Query(ctx, `DELETE FROM runs`); err == nil {
+		t.Fatalf("read-only database accepted a write")
+	}
+}
+
+func TestQueryRefusesStatementsThatWrite(t *testing.T) {
+	ctx := context.Background()
+	db, path := openTestDB(t)
+	db.Close()
+	ro, err := OpenReadOnly(ctx, path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly failed: %v", err)
+	}
+	defer ro.Close()
+
+	dir := filepath.Dir(path)
+	other := filepath.Join(dir, "other.db")
+	for _, q := range []string{
+		`PRAGMA query_only = 0`,
+		`ATTACH DATABASE '` + other + `' AS e`,
+		`VACUUM INTO '` + other + `'`,
+		`SELECT 1; ATTACH DATABASE '` + other + `' AS e`,
+		`/* SELECT */ DELETE FROM runs`,
+		`-- SELECT
+		 DROP TABLE runs`,
+		`WITH x AS (SELECT 1) DELETE FROM runs`,
+		``,
+	} {
+		if _, err := ro.Query(ctx, q); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", q)
+		}
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Fatalf("refused statements created %s", other)
+	}
+
+	for _, q := range []string{
+		`SELECT 1;`,
+		`  -- count runs
+		 select count(*) FROM runs WHERE 'a;b' <> "c;d" /* ; */ ;`,
+		`WITH x AS (SELECT 1 AS n) SELECT n FROM x`,
+		`VALUES (1), (2)`,
+		`EXPLAIN QUERY PLAN SELECT * FROM albums`,
+	} {
+		if _, err := ro.Query(ctx, q); err != nil {
+			t.Errorf("Query(%q) failed: %v", q, err)
+		}
+	}
+}
+
+func TestRemoveDeletesSidecarFiles(t *testing.T) {
+	db, path := openTestDB(t)
+	if err := db.MarkCompleted("Album"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.WriteFile(path+suffix, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Remove(path); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	for _, p := range []string{path, path + "-wal", path + "-shm"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("%s still exists", p)
+		}
+	}
+	if err := Remove(path); err != nil {
+		t.Fatalf("Remove of missing files failed: %v", err)
+	}
+}
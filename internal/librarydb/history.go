@@ -0,0 +1,60 @@
+package librarydb
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Event is one entry in the history of a run.
+type Event struct {
+	// RunID is zero for events outside a run.
+	RunID    int64
+	At       time.Time
+	Type     string
+	AlbumCID string
+	Album    string
+	Tracks   int
+	Message  string
+}
+
+// StartRun records the start of a run over albums and returns its ID.
+func (db *DB) StartRun(ctx context.Context, albums int, started time.Time) (int64, error) {
+	res, err := db.sql.ExecContext(ctx,
+		`INSERT INTO runs (started_at, albums) VALUES (?, ?)`,
+		formatTime(started), albums,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("record run start: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// FinishRun records the outcome of a run.
+func (db *DB) FinishRun(ctx context.Context, id int64, archived, failed int, runErr string) error {
+	if _, err := db.sql.ExecContext(ctx,
+		`UPDATE runs SET finished_at = ?, archived = ?, failed = ?, error = ? WHERE id = ?`,
+		formatTime(time.Now()), archived, failed, runErr, id,
+	); err != nil {
+		return fmt.Errorf("record run finish: %w", err)
+	}
+	return nil
+}
+
+// AddEvent appends an event to the history.
+func (db *DB) AddEvent(ctx context.Context, ev Event) error {
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	var runID any
+	if ev.RunID != 0 {
+		runID = ev.RunID
+	}
+	if _, err := db.sql.ExecContext(ctx,
+		`INSERT INTO events (run_id, at, type, album_cid, album, tracks, message) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		runID, formatTime(ev.At), ev.Type, ev.AlbumCID, ev.Album, ev.Tracks, ev.Message,
+	); err != nil {
+		return fmt.Errorf("record %s event: %w", ev.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package librarydb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"msr-archiver/internal/catalog"
+	"msr-archiver/internal/state"
+)
+
+// ImportSources names the JSON state files to import. Empty paths and
+// missing files are skipped.
+type ImportSources struct {
+	CompletedAlbums string
+	Library         string
+	AlbumCache      string
+}
+
+// ImportStats counts imported rows.
+type ImportStats struct {
+	Catalog   int
+	Songs     int
+	Archived  int
+	Completed int
+}
+
+// Empty reports whether nothing was imported.
+func (s ImportStats) Empty() bool {
+	return s == ImportStats{}
+}
+
+// ImportJSON merges the JSON state files into the database. Importing the
+// same files again is harmless: rows are upserted.
+func (db *DB) ImportJSON(ctx context.Context, src ImportSources) (ImportStats, error) {
+	var stats ImportStats
+
+	if src.AlbumCache != "" {
+		if err := db.importAlbumCache(ctx, src.AlbumCache, &stats); err != nil {
+			return stats, err
+		}
+	}
+
+	if src.Library != "" {
+		if _, err := os.Stat(src.Library); err == nil {
+			lib, err := state.NewLibraryStore(src.Library)
+			if err != nil {
+				return stats, err
+			}
+			for _, album := range lib.Albums() {
+				if err := db.PutAlbum(album); err != nil {
+					return stats, err
+				}
+				stats.Archived++
+			}
+			if err := db.recordImport(ctx, src.Library, stats.Archived); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	if src.CompletedAlbums != "" {
+		names, err := readCompleted(src.CompletedAlbums)
+		if err != nil {
+			return stats, err
+		}
+		for _, name := range names {
+			if err := db.MarkCompleted(name); err != nil {
+				return stats, err
+			}
+			stats.Completed++
+		}
+		if names != nil {
+			if err := db.recordImport(ctx, src.CompletedAlbums, stats.Completed); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+func (db *DB) importAlbumCache(ctx context.Context, path string, stats *ImportStats) error {
+	cache := catalog.NewCache(path)
+	albums, _, err := cache.Load()
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := db.SaveCatalog(ctx, albums); err != nil {
+		return err
+	}
+	stats.Catalog = len(albums)
+
+	songs, err := cache.LoadSongs()
+	if err != nil {
+		return err
+	}
+	for albumCID, list := range songs {
+		if err := db.SaveSongs(ctx, albumCID, list); err != nil {
+			return err
+		}
+		stats.Songs += len(list)
+	}
+	return db.recordImport(ctx, path, stats.Catalog+stats.Songs)
+}
+
+// readCompleted reads completed_albums.json. A missing file yields nil.
+func readCompleted(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read state file %s: %w", path, err)
+	}
+	names := []string{}
+	if err := json.Unmarshal(b, &names); err != nil {
+		return nil, fmt.Errorf("parse state file %s: %w", path, err)
+	}
+	return names, nil
+}
+
+func (db *DB) recordImport(ctx context.Context, source string, rows int) error {
+	if _, err := db.sql.ExecContext(ctx,
+		`INSERT INTO imports (source, imported_at, rows) VALUES (?, ?, ?)
+		 ON CONFLICT (source) DO UPDATE SET imported_at = excluded.imported_at, rows = excluded.rows`,
+		source, formatTime(time.Now()), rows,
+	); err != nil {
+		return fmt.Errorf("record import of %s: %w", source, err)
+	}
+	return nil
+}
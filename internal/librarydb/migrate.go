@@ -0,0 +1,132 @@
+package librarydb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migrations are applied in order; migrations[i] upgrades the schema from
+// version i to i+1. Released migrations must never change, only be appended
+// to.
+var migrations = []string{
+	// 1: initial schema.
+	`CREATE TABLE albums (
+		cid         TEXT PRIMARY KEY,
+		name        TEXT NOT NULL,
+		artists     TEXT NOT NULL DEFAULT '[]',
+		cover_url   TEXT NOT NULL DEFAULT '',
+		dir         TEXT NOT NULL DEFAULT '',
+		archived_at TEXT,
+		updated_at  TEXT NOT NULL
+	);
+	CREATE INDEX albums_name ON albums (name);
+
+	CREATE TABLE songs (
+		cid       TEXT PRIMARY KEY,
+		album_cid TEXT NOT NULL REFERENCES albums (cid) ON DELETE CASCADE,
+		number    INTEGER NOT NULL,
+		name      TEXT NOT NULL,
+		artists   TEXT NOT NULL DEFAULT '[]'
+	);
+	CREATE INDEX songs_album ON songs (album_cid, number);
+
+	CREATE TABLE files (
+		id               INTEGER PRIMARY KEY,
+		song_cid         TEXT NOT NULL REFERENCES songs (cid) ON DELETE CASCADE,
+		album_cid        TEXT NOT NULL REFERENCES albums (cid) ON DELETE CASCADE,
+		path             TEXT NOT NULL UNIQUE,
+		file_type        TEXT NOT NULL,
+		duration_seconds REAL NOT NULL DEFAULT 0,
+		recorded_at      TEXT NOT NULL
+	);
+	CREATE INDEX files_album ON files (album_cid);
+
+	-- Completion is keyed by album name, as in completed_albums.json.
+	CREATE TABLE completed_albums (
+		name         TEXT PRIMARY KEY,
+		completed_at TEXT NOT NULL
+	);
+
+	CREATE TABLE runs (
+		id          INTEGER PRIMARY KEY,
+		started_at  TEXT NOT NULL,
+		finished_at TEXT,
+		albums      INTEGER NOT NULL DEFAULT 0,
+		archived    INTEGER NOT NULL DEFAULT 0,
+		failed      INTEGER NOT NULL DEFAULT 0,
+		error       TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE events (
+		id        INTEGER PRIMARY KEY,
+		run_id    INTEGER REFERENCES runs (id) ON DELETE CASCADE,
+		at        TEXT NOT NULL,
+		type      TEXT NOT NULL,
+		album_cid TEXT NOT NULL DEFAULT '',
+		album     TEXT NOT NULL DEFAULT '',
+		tracks    INTEGER NOT NULL DEFAULT 0,
+		message   TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX events_run ON events (run_id);
+	CREATE INDEX events_album ON events (album_cid);
+
+	CREATE TABLE imports (
+		source      TEXT PRIMARY KEY,
+		imported_at TEXT NOT NULL,
+		rows        INTEGER NOT NULL
+	);`,
+}
+
+// SchemaVersion returns the applied schema version, zero for an empty
+// database.
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	var exists int
+	if err := db.sql.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`,
+	).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+	var version int
+	if err := db.sql.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// migrate applies pending migrations, each in its own transaction.
+func (db *DB) migrate(ctx context.Context) error {
+	if _, err := db.sql.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("library database %s has schema version %d, newer than this build (%d)", db.path, version, len(migrations))
+	}
+	for v := version; v < len(migrations); v++ {
+		err := db.tx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migrations[v]); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+				v+1, formatTime(time.Now()),
+			)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migrate library database to version %d: %w", v+1, err)
+		}
+	}
+	return nil
+}
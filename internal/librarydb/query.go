@@ -0,0 +1,147 @@
+package librarydb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrNotQuery reports SQL that Query refuses to run.
+var ErrNotQuery = errors.New("only a single SELECT, WITH, VALUES or EXPLAIN statement is allowed")
+
+// Result holds the rows of an ad-hoc query.
+type Result struct {
+	Columns []string
+	Rows    [][]any
+}
+
+// Query runs one ad-hoc SELECT, WITH, VALUES or EXPLAIN statement. Other
+// statements fail with ErrNotQuery: a read-only connection only protects the
+// main database file, and ATTACH, VACUUM INTO or PRAGMA could still create
+// or change other files. Writes through WITH are refused by query_only on
+// connections from OpenReadOnly.
+func (db *DB) Query(ctx context.Context, query string, args ...any) (Result, error) {
+	if err := checkQuery(query); err != nil {
+		return Result{}, err
+	}
+	rows, err := db.sql.QueryContext(ctx, query, args...)
+	if err != nil {
+		return Result{}, fmt.Errorf("query: %w", err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return Result{}, fmt.Errorf("query: %w", err)
+	}
+	res := Result{Columns: cols}
+	for rows.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return Result{}, fmt.Errorf("query: %w", err)
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		res.Rows = append(res.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return Result{}, fmt.Errorf("query: %w", err)
+	}
+	return res, nil
+}
+
+// queryKeywords are the statements Query accepts.
+var queryKeywords = map[string]bool{"SELECT": true, "WITH": true, "VALUES": true, "EXPLAIN": true}
+
+// checkQuery accepts a single statement that starts with one of
+// queryKeywords. Strings, quoted identifiers and comments are skipped when
+// looking for the keyword and for statement separators.
+func checkQuery(query string) error {
+	var keyword strings.Builder
+	inKeyword, seen, trailing := true, false, false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(query)
+			}
+			continue
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return fmt.Errorf("%w: unterminated comment", ErrNotQuery)
+			}
+			i += end + 3
+			continue
+		case unicode.IsSpace(rune(c)):
+			continue
+		}
+
+		if trailing {
+			if c == ';' {
+				continue
+			}
+			return fmt.Errorf("%w: found more than one statement", ErrNotQuery)
+		}
+		if c == ';' {
+			trailing = true
+			continue
+		}
+		seen = true
+		if inKeyword && (c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			keyword.WriteByte(c)
+			if i+1 < len(query) && isWordByte(query[i+1]) {
+				continue
+			}
+		}
+		if inKeyword {
+			inKeyword = false
+			if !queryKeywords[strings.ToUpper(keyword.String())] {
+				return ErrNotQuery
+			}
+		}
+
+		// Skip quoted strings and identifiers; a doubled quote escapes it.
+		var closing byte
+		switch c {
+		case '\'', '"', '`':
+			closing = c
+		case '[':
+			closing = ']'
+		default:
+			continue
+		}
+		for i++; ; i++ {
+			if i >= len(query) {
+				return fmt.Errorf("%w: unterminated quote", ErrNotQuery)
+			}
+			if query[i] == closing {
+				if closing != ']' && i+1 < len(query) && query[i+1] == closing {
+					i++
+					continue
+				}
+				break
+			}
+		}
+	}
+	if !seen {
+		return fmt.Errorf("%w: empty query", ErrNotQuery)
+	}
+	return nil
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
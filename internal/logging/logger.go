@@ -2,6 +2,7 @@ package logging
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sync"
@@ -13,9 +14,14 @@ type Logger struct {
 	l  *log.Logger
 }
 
-// New creates a thread-safe logger.
+// New creates a thread-safe logger that writes to stdout.
 func New() *Logger {
-	return &Logger{l: log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lmicroseconds)}
+	return NewWithWriter(os.Stdout)
+}
+
+// NewWithWriter creates a thread-safe logger that writes to w.
+func NewWithWriter(w io.Writer) *Logger {
+	return &Logger{l: log.New(w, "", log.Ldate|log.Ltime|log.Lmicroseconds)}
 }
 
 // Infof writes an informational message.
@@ -0,0 +1,165 @@
+package loudness
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Supported analysis modes.
+const (
+	ModeOff        = "off"
+	ModeReplayGain = "replaygain"
+	ModeR128       = "r128"
+)
+
+// Measurement is the EBU R128 loudness of a track or an album.
+type Measurement struct {
+	IntegratedLUFS float64 `json:"integratedLufs"`
+	TruePeakDBFS   float64 `json:"truePeakDbfs"`
+}
+
+// ParseMode normalizes a loudness mode flag value.
+func ParseMode(raw string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "off", "none":
+		return ModeOff, nil
+	case "replaygain", "rg", "rg2":
+		return ModeReplayGain, nil
+	case "r128", "ebur128":
+		return ModeR128, nil
+	default:
+		return "", fmt.Errorf("unsupported loudness mode %q (want off, replaygain or r128)", raw)
+	}
+}
+
+// ReferenceLUFS returns the target loudness for a mode: -18 LUFS for
+// ReplayGain 2.0 and -23 LUFS for EBU R128.
+func ReferenceLUFS(mode string) float64 {
+	if mode == ModeR128 {
+		return -23
+	}
+	return -18
+}
+
+// Gain returns the gain in dB needed to reach the mode reference loudness.
+func (m Measurement) Gain(mode string) float64 {
+	return ReferenceLUFS(mode) - m.IntegratedLUFS
+}
+
+// PeakRatio returns the true peak as a linear sample ratio.
+func (m Measurement) PeakRatio() float64 {
+	return math.Pow(10, m.TruePeakDBFS/20)
+}
+
+// Tags builds REPLAYGAIN_* tag values for a track within an album.
+func Tags(mode string, track, album Measurement) map[string]string {
+	return map[string]string{
+		"REPLAYGAIN_TRACK_GAIN":         fmt.Sprintf("%.2f dB", track.Gain(mode)),
+		"REPLAYGAIN_TRACK_PEAK":         fmt.Sprintf("%.6f", track.PeakRatio()),
+		"REPLAYGAIN_ALBUM_GAIN":         fmt.Sprintf("%.2f dB", album.Gain(mode)),
+		"REPLAYGAIN_ALBUM_PEAK":         fmt.Sprintf("%.6f", album.PeakRatio()),
+		"REPLAYGAIN_REFERENCE_LOUDNESS": fmt.Sprintf("%.2f LUFS", ReferenceLUFS(mode)),
+	}
+}
+
+// Analyze measures a single file with ffmpeg's ebur128 filter.
+func Analyze(ctx context.Context, path string) (Measurement, error) {
+	return run(ctx, []string{path}, "[0:a]ebur128=peak=true[out]")
+}
+
+// AnalyzeAlbum measures all files as one continuous programme, which is how
+// album gain is defined.
+func AnalyzeAlbum(ctx context.Context, paths []string) (Measurement, error) {
+	if len(paths) == 0 {
+		return Measurement{}, fmt.Errorf("no tracks to analyze")
+	}
+	if len(paths) == 1 {
+		return Analyze(ctx, paths[0])
+	}
+
+	var graph strings.Builder
+	for i := range paths {
+		fmt.Fprintf(&graph, "[%d:a]aresample=48000,aformat=channel_layouts=stereo[a%d];", i, i)
+	}
+	for i := range paths {
+		fmt.Fprintf(&graph, "[a%d]", i)
+	}
+	fmt.Fprintf(&graph, "concat=n=%d:v=0:a=1,ebur128=peak=true[out]", len(paths))
+	return run(ctx, paths, graph.String())
+}
+
+func run(ctx context.Context, paths []string, graph string) (Measurement, error) {
+	args := []string{"-hide_banner", "-nostats"}
+	for _, p := range paths {
+		args = append(args, "-i", p)
+	}
+	args = append(args, "-filter_complex", graph, "-map", "[out]", "-f", "null", "-")
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Measurement{}, fmt.Errorf("ffmpeg loudness analysis failed: %w: %s", err, stderr.String())
+	}
+	return parseSummary(stderr.String())
+}
+
+// parseSummary extracts integrated loudness and true peak from the summary
+// block ebur128 prints when the stream ends.
+func parseSummary(out string) (Measurement, error) {
+	idx := strings.LastIndex(out, "Summary:")
+	if idx < 0 {
+		return Measurement{}, fmt.Errorf("ebur128 summary not found in ffmpeg output")
+	}
+
+	var m Measurement
+	var haveI, havePeak bool
+	sc := bufio.NewScanner(strings.NewReader(out[idx:]))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "I:":
+			v, err := parseValue(fields[1])
+			if err != nil {
+				return Measurement{}, fmt.Errorf("parse integrated loudness: %w", err)
+			}
+			m.IntegratedLUFS = v
+			haveI = true
+		case "Peak:":
+			v, err := parseValue(fields[1])
+			if err != nil {
+				return Measurement{}, fmt.Errorf("parse true peak: %w", err)
+			}
+			m.TruePeakDBFS = v
+			havePeak = true
+		}
+	}
+
+	if !haveI {
+		return Measurement{}, fmt.Errorf("integrated loudness missing from ebur128 summary")
+	}
+	if !havePeak {
+		return Measurement{}, fmt.Errorf("true peak missing from ebur128 summary")
+	}
+	return m, nil
+}
+
+// silenceFloor replaces -inf readings for digital silence so measurements
+// stay finite and JSON-encodable.
+const silenceFloor = -120
+
+func parseValue(raw string) (float64, error) {
+	if raw == "-inf" {
+		return silenceFloor, nil
+	}
+	return strconv.ParseFloat(raw, 64)
+}
@@ -0,0 +1,92 @@
+package loudness
+
+import (
+	"math"
+	"testing"
+)
+
+const sampleOutput = `[Parsed_ebur128_0 @ 0x55d5c] t: 1.2  TARGET:-23 LUFS    M: -20.1 S:-120.7     I: -19.8 LUFS       LRA:   0.0 LU  FTPK: -3.1 dBFS  TPK: -3.1 dBFS
+[Parsed_ebur128_0 @ 0x55d5c] Summary:
+
+  Integrated loudness:
+    I:         -14.3 LUFS
+    Threshold: -24.5 LUFS
+
+  Loudness range:
+    LRA:         5.2 LU
+    Threshold: -34.4 LUFS
+    LRA low:   -19.1 LUFS
+    LRA high:  -13.9 LUFS
+
+  True peak:
+    Peak:        0.5 dBFS
+`
+
+func TestParseSummary(t *testing.T) {
+	m, err := parseSummary(sampleOutput)
+	if err != nil {
+		t.Fatalf("parseSummary failed: %v", err)
+	}
+	if m.IntegratedLUFS != -14.3 {
+		t.Fatalf("unexpected integrated loudness: %v", m.IntegratedLUFS)
+	}
+	if m.TruePeakDBFS != 0.5 {
+		t.Fatalf("unexpected true peak: %v", m.TruePeakDBFS)
+	}
+}
+
+func TestParseSummaryMissing(t *testing.T) {
+	if _, err := parseSummary("no summary here"); err == nil {
+		t.Fatalf("expected missing summary error")
+	}
+}
+
+func TestParseSummarySilence(t *testing.T) {
+	out := "Summary:\n  I:  -70.0 LUFS\n  Peak:  -inf dBFS\n"
+	m, err := parseSummary(out)
+	if err != nil {
+		t.Fatalf("parseSummary failed: %v", err)
+	}
+	if m.TruePeakDBFS != silenceFloor {
+		t.Fatalf("expected silence floor peak, got %v", m.TruePeakDBFS)
+	}
+}
+
+func TestTagsReplayGainReference(t *testing.T) {
+	track := Measurement{IntegratedLUFS: -14, TruePeakDBFS: 0}
+	album := Measurement{IntegratedLUFS: -16, TruePeakDBFS: -6}
+
+	tags := Tags(ModeReplayGain, track, album)
+	if tags["REPLAYGAIN_TRACK_GAIN"] != "-4.00 dB" {
+		t.Fatalf("unexpected track gain: %q", tags["REPLAYGAIN_TRACK_GAIN"])
+	}
+	if tags["REPLAYGAIN_ALBUM_GAIN"] != "-2.00 dB" {
+		t.Fatalf("unexpected album gain: %q", tags["REPLAYGAIN_ALBUM_GAIN"])
+	}
+	if tags["REPLAYGAIN_TRACK_PEAK"] != "1.000000" {
+		t.Fatalf("unexpected track peak: %q", tags["REPLAYGAIN_TRACK_PEAK"])
+	}
+	if tags["REPLAYGAIN_REFERENCE_LOUDNESS"] != "-18.00 LUFS" {
+		t.Fatalf("unexpected reference: %q", tags["REPLAYGAIN_REFERENCE_LOUDNESS"])
+	}
+}
+
+func TestGainR128Reference(t *testing.T) {
+	m := Measurement{IntegratedLUFS: -20}
+	if got := m.Gain(ModeR128); math.Abs(got-(-3)) > 1e-9 {
+		t.Fatalf("unexpected r128 gain: %v", got)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	cases := map[string]string{"": ModeOff, "off": ModeOff, "ReplayGain": ModeReplayGain, "r128": ModeR128}
+	for in, want := range cases {
+		got, err := ParseMode(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseMode(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseMode("loud"); err == nil {
+		t.Fatalf("expected unsupported mode error")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -35,7 +36,9 @@ func Apply(ctx context.Context, in Input) error {
 	}
 
 	args := []string{"-y", "-i", in.FilePath}
-	coverEnabled := in.CoverPath != ""
+	// Ogg has no attached picture stream, so covers are only embedded in
+	// FLAC and MP3.
+	coverEnabled := in.CoverPath != "" && in.FileType != ".ogg"
 	if coverEnabled {
 		args = append(args, "-i", in.CoverPath)
 	}
@@ -47,19 +50,15 @@ func Apply(ctx context.Context, in Input) error {
 	args = append(args, "-c:a", "copy")
 
 	if coverEnabled {
+		// The cover is already encoded by the cover pipeline; copy it as-is so
+		// each track does not re-encode the same image.
+		args = append(args, "-c:v", "copy", "-disposition:v", "attached_pic")
 		if in.FileType == ".mp3" {
 			args = append(args,
-				"-c:v", "mjpeg",
 				"-id3v2_version", "3",
-				"-disposition:v", "attached_pic",
 				"-metadata:s:v", "title=Cover",
 				"-metadata:s:v", "comment=Cover (front)",
 			)
-		} else {
-			args = append(args,
-				"-c:v", "png",
-				"-disposition:v", "attached_pic",
-			)
 		}
 	}
 
@@ -95,3 +94,42 @@ func Apply(ctx context.Context, in Input) error {
 
 	return nil
 }
+
+// ApplyTags writes extra tags (for example REPLAYGAIN_*) into an existing file
+// without touching its audio or attached pictures.
+func ApplyTags(ctx context.Context, filePath, fileType string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := []string{"-y", "-i", filePath, "-map", "0", "-c", "copy"}
+	if fileType == ".mp3" {
+		args = append(args, "-id3v2_version", "3")
+	}
+	for _, k := range keys {
+		args = append(args, "-metadata", k+"="+tags[k])
+	}
+
+	tmpPath := filepath.Join(filepath.Dir(filePath), ".tmp-tags-"+filepath.Base(filePath))
+	args = append(args, tmpPath)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg tag write failed: %w: %s", err, stderr.String())
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("replace output file with tagged version: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event types fired during a run.
+const (
+	EventRunStarted     = "run_started"
+	EventAlbumCompleted = "album_completed"
+	EventAlbumFailed    = "album_failed"
+	EventRunFinished    = "run_finished"
+)
+
+// Event is the payload delivered to hooks.
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	AlbumCID string    `json:"albumCid,omitempty"`
+	Album    string    `json:"album,omitempty"`
+	Tracks   int       `json:"tracks,omitempty"`
+	Albums   int       `json:"albums,omitempty"`
+	Failed   int       `json:"failed,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Hook delivers events to one destination.
+type Hook interface {
+	Name() string
+	Send(ctx context.Context, ev Event) error
+}
+
+// Webhook posts events as JSON to an HTTP endpoint.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Name identifies the hook in errors.
+func (w *Webhook) Name() string {
+	return "webhook " + w.URL
+}
+
+// Send posts ev as JSON and fails on non-2xx responses.
+func (w *Webhook) Send(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post %s: %w", w.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post %s: unexpected status %d", w.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// Command runs a local shell command per event with MSR_EVENT*
+// environment variables describing it.
+type Command struct {
+	Command string
+}
+
+// Name identifies the hook in errors.
+func (c *Command) Name() string {
+	return "command " + c.Command
+}
+
+// Send runs the command and fails on a non-zero exit status.
+func (c *Command) Send(ctx context.Context, ev Event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", c.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.Command)
+	}
+	cmd.Env = append(os.Environ(), eventEnv(ev, payload)...)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("run %q: %w: %s", c.Command, err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// eventEnv describes ev in MSR_EVENT* variables. The prefix keeps them
+// apart from the MSR_* option overrides, so a hook that runs msr-archiver
+// again does not pick them up as options (MSR_ALBUMS would be --albums).
+func eventEnv(ev Event, payload []byte) []string {
+	return []string{
+		"MSR_EVENT=" + ev.Type,
+		"MSR_EVENT_TIME=" + ev.Time.UTC().Format(time.RFC3339),
+		"MSR_EVENT_ALBUM=" + ev.Album,
+		"MSR_EVENT_ALBUM_CID=" + ev.AlbumCID,
+		"MSR_EVENT_TRACKS=" + strconv.Itoa(ev.Tracks),
+		"MSR_EVENT_ALBUMS=" + strconv.Itoa(ev.Albums),
+		"MSR_EVENT_FAILED=" + strconv.Itoa(ev.Failed),
+		"MSR_EVENT_DURATION=" + ev.Duration,
+		"MSR_EVENT_ERROR=" + ev.Error,
+		"MSR_EVENT_PAYLOAD=" + string(payload),
+	}
+}
+
+// Options controls delivery behavior.
+type Options struct {
+	// Events limits delivery to these event types; empty means all.
+	Events []string
+	// Attempts is the number of delivery attempts per hook.
+	Attempts int
+	// Timeout bounds each delivery attempt.
+	Timeout time.Duration
+	// Backoff is the base delay between attempts; it grows linearly.
+	Backoff time.Duration
+}
+
+// Notifier fans events out to hooks with retries and timeouts.
+type Notifier struct {
+	hooks  []Hook
+	opts   Options
+	events map[string]struct{}
+}
+
+// New creates a Notifier. A Notifier without hooks is valid and does nothing.
+func New(hooks []Hook, opts Options) *Notifier {
+	if opts.Attempts < 1 {
+		opts.Attempts = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+
+	var events map[string]struct{}
+	if len(opts.Events) > 0 {
+		events = make(map[string]struct{}, len(opts.Events))
+		for _, e := range opts.Events {
+			events[strings.TrimSpace(e)] = struct{}{}
+		}
+	}
+	return &Notifier{hooks: hooks, opts: opts, events: events}
+}
+
+// Enabled reports whether any hooks are configured.
+func (n *Notifier) Enabled() bool {
+	return n != nil && len(n.hooks) > 0
+}
+
+// Fire delivers ev to every hook and returns a joined error for hooks that
+// failed after all attempts.
+func (n *Notifier) Fire(ctx context.Context, ev Event) error {
+	if !n.Enabled() {
+		return nil
+	}
+	if n.events != nil {
+		if _, ok := n.events[ev.Type]; !ok {
+			return nil
+		}
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	var errs []error
+	for _, h := range n.hooks {
+		if err := n.send(ctx, h, ev); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) send(ctx context.Context, h Hook, ev Event) error {
+	var err error
+	for attempt := 1; attempt <= n.opts.Attempts; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, n.opts.Timeout)
+		err = h.Send(attemptCtx, ev)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt == n.opts.Attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * n.opts.Backoff):
+		}
+	}
+	return err
+}
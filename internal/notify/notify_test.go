@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliversJSON(t *testing.T) {
+	received := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var ev Event
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		received <- ev
+	}))
+	defer srv.Close()
+
+	n := New([]Hook{&Webhook{URL: srv.URL}}, Options{})
+	err := n.Fire(context.Background(), Event{Type: EventAlbumCompleted, Album: "Album", AlbumCID: "a1", Tracks: 3})
+	if err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+
+	ev := <-received
+	if ev.Type != EventAlbumCompleted || ev.Album != "Album" || ev.Tracks != 3 || ev.Time.IsZero() {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
+
+func TestWebhookRetriesUntilSuccess(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := New([]Hook{&Webhook{URL: srv.URL}}, Options{Attempts: 3, Backoff: time.Millisecond})
+	if err := n.Fire(context.Background(), Event{Type: EventRunStarted}); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	n := New([]Hook{&Webhook{URL: srv.URL}}, Options{Attempts: 1, Timeout: 50 * time.Millisecond})
+	err := n.Fire(context.Background(), Event{Type: EventRunFinished})
+	if err == nil || !strings.Contains(err.Error(), "webhook") {
+		t.Fatalf("expected webhook timeout error, got %v", err)
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	n := New([]Hook{&Webhook{URL: srv.URL}}, Options{Events: []string{EventAlbumFailed}})
+	_ = n.Fire(context.Background(), Event{Type: EventAlbumCompleted})
+	_ = n.Fire(context.Background(), Event{Type: EventAlbumFailed})
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected only album_failed delivered, got %d calls", got)
+	}
+}
+
+func TestCommandReceivesEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out.txt")
+	n := New([]Hook{&Command{Command: `printf '%s|%s|%s|%s|%s' "$MSR_EVENT" "$MSR_EVENT_ALBUM" "$MSR_EVENT_ERROR" "$MSR_EVENT_ALBUMS" "${MSR_ALBUMS-unset}" > "` + out + `"`}}, Options{})
+
+	if err := n.Fire(context.Background(), Event{Type: EventAlbumFailed, Album: "Album", Albums: 5, Error: "boom"}); err != nil {
+		t.Fatalf("Fire failed: %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read command output: %v", err)
+	}
+	if string(b) != "album_failed|Album|boom|5|unset" {
+		t.Fatalf("unexpected command output: %q", b)
+	}
+}
+
+func TestCommandFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	n := New([]Hook{&Command{Command: "exit 3"}}, Options{})
+	if err := n.Fire(context.Background(), Event{Type: EventRunStarted}); err == nil {
+		t.Fatalf("expected command failure")
+	}
+}
+
+func TestNotifierWithoutHooks(t *testing.T) {
+	var n *Notifier
+	if n.Enabled() {
+		t.Fatalf("nil notifier should be disabled")
+	}
+	if err := New(nil, Options{}).Fire(context.Background(), Event{Type: EventRunStarted}); err != nil {
+		t.Fatalf("empty notifier should not fail: %v", err)
+	}
+}
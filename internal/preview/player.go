@@ -0,0 +1,180 @@
+// Package preview plays the start of a track through an external player
+// command so songs can be auditioned before they are downloaded.
+package preview
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Track is a playable song.
+type Track struct {
+	Title string
+	URL   string
+}
+
+// Player runs one player command at a time. Each Play or Seek replaces the
+// running command with one that starts at the new position.
+type Player struct {
+	// Command is a shell command run with MSR_PREVIEW_* environment variables.
+	// When empty, ffplay is run directly with the preview window as arguments,
+	// which needs no shell quoting and so works the same under cmd on Windows.
+	Command string
+	// Window is how much of each track can be previewed.
+	Window time.Duration
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	done    chan struct{}
+	track   Track
+	offset  time.Duration
+	started time.Time
+}
+
+// New creates a Player. An empty command plays previews with ffplay.
+func New(command string, window time.Duration) *Player {
+	if window <= 0 {
+		window = 30 * time.Second
+	}
+	return &Player{Command: command, Window: window}
+}
+
+// Play stops any running preview and plays track from offset.
+func (p *Player) Play(track Track, offset time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.startLocked(track, offset)
+}
+
+// Seek restarts the current track delta away from its position, clamped to
+// the preview window. Seeking past the window stops playback.
+func (p *Player) Seek(delta time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.track.URL == "" {
+		return errors.New("nothing is playing")
+	}
+	pos := max(0, p.positionLocked()+delta)
+	if pos >= p.Window {
+		p.stopLocked()
+		return nil
+	}
+	return p.startLocked(p.track, pos)
+}
+
+// Stop ends playback. It is safe to call when nothing is playing.
+func (p *Player) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopLocked()
+}
+
+// Status returns the current track, the position within it and whether the
+// player command is still running.
+func (p *Player) Status() (Track, time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		return Track{}, 0, false
+	}
+	select {
+	case <-p.done:
+		return p.track, p.positionLocked(), false
+	default:
+		return p.track, p.positionLocked(), true
+	}
+}
+
+func (p *Player) positionLocked() time.Duration {
+	if p.cmd == nil {
+		return 0
+	}
+	return min(p.Window, p.offset+time.Since(p.started))
+}
+
+func (p *Player) startLocked(track Track, offset time.Duration) error {
+	p.stopLocked()
+	if track.URL == "" {
+		return errors.New("track has no source URL")
+	}
+	offset = max(0, offset)
+	remaining := p.Window - offset
+	if remaining <= 0 {
+		return nil
+	}
+
+	cmd := p.command(track, offset, remaining)
+	// The picker owns the terminal; player output would corrupt it.
+	cmd.Stdin = nil
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start player %q: %w", cmp.Or(p.Command, "ffplay"), err)
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+
+	p.cmd = cmd
+	p.done = done
+	p.track = track
+	p.offset = offset
+	p.started = time.Now()
+	return nil
+}
+
+// command builds the player process for track from offset. The MSR_PREVIEW_*
+// variables are set for the default player too, though it ignores them.
+func (p *Player) command(track Track, offset, remaining time.Duration) *exec.Cmd {
+	var cmd *exec.Cmd
+	switch {
+	case p.Command == "":
+		cmd = exec.Command("ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet",
+			"-ss", seconds(offset), "-t", seconds(remaining), track.URL)
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("cmd", "/C", p.Command)
+	default:
+		cmd = exec.Command("sh", "-c", p.Command)
+	}
+	cmd.Env = append(os.Environ(),
+		"MSR_PREVIEW_URL="+track.URL,
+		"MSR_PREVIEW_TITLE="+track.Title,
+		"MSR_PREVIEW_START="+seconds(offset),
+		"MSR_PREVIEW_DURATION="+seconds(remaining),
+	)
+	return cmd
+}
+
+func (p *Player) stopLocked() {
+	if p.cmd == nil {
+		return
+	}
+	select {
+	case <-p.done:
+	default:
+		killProcessGroup(p.cmd)
+		<-p.done
+	}
+	p.cmd = nil
+	p.done = nil
+	p.track = Track{}
+	p.offset = 0
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,94 @@
+//go:build !windows
+
+package preview
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubPlayer records its environment to a file and then plays "forever".
+func stubPlayer(t *testing.T) (*Player, string) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "calls.log")
+	cmd := `echo "$MSR_PREVIEW_URL $MSR_PREVIEW_START $MSR_PREVIEW_DURATION" >> ` + out + `; sleep 30`
+	return New(cmd, 10*time.Second), out
+}
+
+func waitForLines(t *testing.T, path string, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b, _ := os.ReadFile(path)
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(b) > 0 && len(lines) >= n {
+			return lines
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("player command did not run %d time(s)", n)
+	return nil
+}
+
+func TestPlayerPlaySeekStop(t *testing.T) {
+	p, log := stubPlayer(t)
+	defer p.Stop()
+
+	if err := p.Play(Track{Title: "Song", URL: "https://cdn/song.wav"}, 0); err != nil {
+		t.Fatalf("Play failed: %v", err)
+	}
+	lines := waitForLines(t, log, 1)
+	if lines[0] != "https://cdn/song.wav 0.000 10.000" {
+		t.Fatalf("unexpected player invocation %q", lines[0])
+	}
+	if track, _, playing := p.Status(); !playing || track.Title != "Song" {
+		t.Fatalf("Status = %+v, %v; want Song playing", track, playing)
+	}
+
+	if err := p.Seek(5 * time.Second); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	lines = waitForLines(t, log, 2)
+	var start, dur float64
+	var url string
+	if _, err := fmt.Sscan(lines[1], &url, &start, &dur); err != nil {
+		t.Fatalf("parse %q: %v", lines[1], err)
+	}
+	if start < 5 || start > 6 || math.Abs(start+dur-10) > 0.01 {
+		t.Fatalf("seek restarted at %.3f for %.3f, want ~5 within a 10s window", start, dur)
+	}
+
+	// Seeking past the window stops playback.
+	if err := p.Seek(time.Minute); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if _, _, playing := p.Status(); playing {
+		t.Fatalf("expected playback to stop after seeking past the window")
+	}
+}
+
+func TestPlayerRejectsMissingURL(t *testing.T) {
+	p, _ := stubPlayer(t)
+	if err := p.Play(Track{Title: "No source"}, 0); err == nil {
+		t.Fatalf("expected error for a track without URL")
+	}
+	if err := p.Seek(time.Second); err == nil {
+		t.Fatalf("expected error when seeking with nothing playing")
+	}
+	p.Stop()
+}
+
+func TestDefaultPlayerPassesWindowAsArguments(t *testing.T) {
+	p := New("", 30*time.Second)
+	cmd := p.command(Track{URL: "https://cdn/a b.wav?x=1&y=2"}, 5*time.Second, 25*time.Second)
+	want := []string{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet", "-ss", "5.000", "-t", "25.000", "https://cdn/a b.wav?x=1&y=2"}
+	if !slices.Equal(cmd.Args, want) {
+		t.Fatalf("Args = %q, want %q", cmd.Args, want)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package preview
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the shell in its own process group so stopping it
+// also stops the player it launched.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		_ = cmd.Process.Kill()
+	}
+}
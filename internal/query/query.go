@@ -0,0 +1,417 @@
+// Package query parses album selection expressions shared by --albums and the
+// interactive picker filter.
+//
+// An expression is a comma- or newline-separated list of terms. Albums
+// matching any term are selected, then albums matching a not: term are
+// removed. Terms:
+//
+//	all            every album
+//	new            albums not archived yet
+//	completed      albums already archived
+//	latest:N       the first N albums in catalog order (newest first)
+//	artist:NAME    albums credited to an artist containing NAME
+//	re:PATTERN     albums whose name or CID matches PATTERN (case-insensitive)
+//	not:TERM       exclude albums matching TERM
+//	@FILE          read more terms from FILE, one per line; # starts a comment
+//	TEXT           an album name or CID (exact, or a unique substring)
+package query
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"msr-archiver/internal/model"
+)
+
+// maxIncludeDepth bounds nested @file references.
+const maxIncludeDepth = 8
+
+type termKind int
+
+const (
+	termText termKind = iota
+	termAll
+	termNew
+	termCompleted
+	termLatest
+	termArtist
+	termRegex
+)
+
+type term struct {
+	kind   termKind
+	text   string
+	n      int
+	re     *regexp.Regexp
+	negate bool
+}
+
+// Expr is a parsed selection expression.
+type Expr struct {
+	terms []term
+}
+
+// Options controls parsing.
+type Options struct {
+	// ReadFile loads @file references; nil uses os.ReadFile.
+	ReadFile func(path string) ([]byte, error)
+}
+
+// Parse parses raw into an expression. An empty expression has no terms.
+func Parse(raw string, opts Options) (Expr, error) {
+	if opts.ReadFile == nil {
+		opts.ReadFile = os.ReadFile
+	}
+	var e Expr
+	if err := e.parse(raw, opts, 0); err != nil {
+		return Expr{}, err
+	}
+	return e, nil
+}
+
+func (e *Expr) parse(raw string, opts Options, depth int) error {
+	for _, part := range splitTerms(raw) {
+		if strings.HasPrefix(part, "@") {
+			if depth >= maxIncludeDepth {
+				return fmt.Errorf("query file %s: too many nested @file references", part[1:])
+			}
+			b, err := opts.ReadFile(part[1:])
+			if err != nil {
+				return fmt.Errorf("read query file: %w", err)
+			}
+			if err := e.parse(stripComments(string(b)), opts, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		t, err := parseTerm(part)
+		if err != nil {
+			return err
+		}
+		e.terms = append(e.terms, t)
+	}
+	return nil
+}
+
+func splitTerms(raw string) []string {
+	fields := strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' })
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func stripComments(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			lines[i] = line[:idx]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parseTerm(raw string) (term, error) {
+	var t term
+	s := raw
+	if rest, ok := cutPrefixFold(s, "not:"); ok {
+		t.negate = true
+		s = strings.TrimSpace(rest)
+		if s == "" {
+			return term{}, fmt.Errorf("query term %q: missing term after not:", raw)
+		}
+	}
+
+	switch {
+	case strings.EqualFold(s, "all"):
+		t.kind = termAll
+	case strings.EqualFold(s, "new"):
+		t.kind = termNew
+	case strings.EqualFold(s, "completed"):
+		t.kind = termCompleted
+	default:
+		if rest, ok := cutPrefixFold(s, "latest:"); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(rest))
+			if err != nil || n < 1 {
+				return term{}, fmt.Errorf("query term %q: latest: needs a positive count", raw)
+			}
+			t.kind, t.n = termLatest, n
+		} else if rest, ok := cutPrefixFold(s, "artist:"); ok {
+			t.kind, t.text = termArtist, strings.ToLower(strings.TrimSpace(rest))
+			if t.text == "" {
+				return term{}, fmt.Errorf("query term %q: missing artist name", raw)
+			}
+		} else if rest, ok := cutPrefixFold(s, "re:"); ok {
+			re, err := regexp.Compile("(?i)" + rest)
+			if err != nil {
+				return term{}, fmt.Errorf("query term %q: %w", raw, err)
+			}
+			t.kind, t.re = termRegex, re
+		} else {
+			t.kind, t.text = termText, s
+		}
+	}
+	return t, nil
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// Empty reports whether the expression has no terms.
+func (e Expr) Empty() bool {
+	return len(e.terms) == 0
+}
+
+// Filter returns indexes of albums matching e in catalog order. Plain text
+// terms match any album whose name or CID contains the text, which suits
+// incremental filtering. An empty expression matches every album.
+func (e Expr) Filter(albums []model.Album, completed func(model.Album) bool) []int {
+	idxs, _ := e.eval(albums, completed, false, nil, nil)
+	sort.Ints(idxs)
+	return idxs
+}
+
+// TextMatcher scores a plain text term against an album name, returning the
+// matched rune positions of name.
+type TextMatcher func(term, name string) (score int, positions []int, ok bool)
+
+// Hit is an album matched by Search.
+type Hit struct {
+	Index int
+	// Score sums the text match scores of included terms.
+	Score int
+	// Positions are matched rune indexes into the album name.
+	Positions []int
+}
+
+// Search is Filter with plain text terms scored by match instead of plain
+// substring matching; a term equal to an album CID still matches it. Hits
+// are returned in catalog order.
+func (e Expr) Search(albums []model.Album, completed func(model.Album) bool, match TextMatcher) []Hit {
+	hits := make(map[int]*Hit)
+	idxs, _ := e.eval(albums, completed, false, match, hits)
+	sort.Ints(idxs)
+	out := make([]Hit, 0, len(idxs))
+	for _, idx := range idxs {
+		h := Hit{Index: idx}
+		if scored, ok := hits[idx]; ok {
+			h = *scored
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// Select resolves e for --albums. Albums are returned in term order, and
+// plain text terms must identify exactly one album.
+func (e Expr) Select(albums []model.Album, completed func(model.Album) bool) ([]model.Album, error) {
+	if e.Empty() {
+		return nil, fmt.Errorf("no valid album query provided")
+	}
+	idxs, err := e.eval(albums, completed, true, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.Album, 0, len(idxs))
+	for _, idx := range idxs {
+		out = append(out, albums[idx])
+	}
+	return out, nil
+}
+
+// eval returns matching album indexes in the order terms first matched them.
+// With match set, text term scores of included albums are recorded in hits.
+func (e Expr) eval(albums []model.Album, completed func(model.Album) bool, strict bool, match TextMatcher, hits map[int]*Hit) ([]int, error) {
+	if completed == nil {
+		completed = func(model.Album) bool { return false }
+	}
+
+	seen := make(map[int]struct{})
+	excluded := make(map[int]struct{})
+	var included []int
+	hasInclude := false
+	for _, t := range e.terms {
+		var idxs []int
+		if t.kind == termText && strict {
+			idx, err := resolve(albums, t.text)
+			if err != nil {
+				return nil, err
+			}
+			idxs = []int{idx}
+		} else if t.kind == termText && match != nil {
+			idxs = t.search(albums, match, hits)
+		} else {
+			idxs = t.matches(albums, completed)
+		}
+
+		if t.negate {
+			for _, idx := range idxs {
+				excluded[idx] = struct{}{}
+			}
+			continue
+		}
+		hasInclude = true
+		for _, idx := range idxs {
+			if _, ok := seen[idx]; !ok {
+				seen[idx] = struct{}{}
+				included = append(included, idx)
+			}
+		}
+	}
+
+	// An expression of only exclusions starts from the whole catalog.
+	if !hasInclude {
+		for idx := range albums {
+			included = append(included, idx)
+		}
+	}
+
+	out := make([]int, 0, len(included))
+	for _, idx := range included {
+		if _, ok := excluded[idx]; !ok {
+			out = append(out, idx)
+		}
+	}
+	return out, nil
+}
+
+// search matches a text term with match, recording scores unless the term
+// is negated.
+func (t term) search(albums []model.Album, match TextMatcher, hits map[int]*Hit) []int {
+	var out []int
+	for idx, a := range albums {
+		score, positions, ok := match(t.text, a.Name)
+		if !ok {
+			if !strings.EqualFold(a.CID, t.text) {
+				continue
+			}
+			score, positions = 0, nil
+		}
+		out = append(out, idx)
+		if t.negate {
+			continue
+		}
+		h, exists := hits[idx]
+		if !exists {
+			h = &Hit{Index: idx}
+			hits[idx] = h
+		}
+		h.Score += score
+		h.Positions = mergePositions(h.Positions, positions)
+	}
+	return out
+}
+
+func mergePositions(a, b []int) []int {
+	if len(b) == 0 {
+		return a
+	}
+	set := make(map[int]struct{}, len(a)+len(b))
+	for _, p := range a {
+		set[p] = struct{}{}
+	}
+	for _, p := range b {
+		set[p] = struct{}{}
+	}
+	out := make([]int, 0, len(set))
+	for p := range set {
+		out = append(out, p)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func (t term) matches(albums []model.Album, completed func(model.Album) bool) []int {
+	var out []int
+	for idx, a := range albums {
+		ok := false
+		switch t.kind {
+		case termAll:
+			ok = true
+		case termNew:
+			ok = !completed(a)
+		case termCompleted:
+			ok = completed(a)
+		case termLatest:
+			ok = idx < t.n
+		case termArtist:
+			for _, name := range a.Artistes {
+				if strings.Contains(strings.ToLower(name), t.text) {
+					ok = true
+					break
+				}
+			}
+		case termRegex:
+			ok = t.re.MatchString(a.Name) || t.re.MatchString(a.CID)
+		case termText:
+			q := strings.ToLower(t.text)
+			ok = strings.Contains(strings.ToLower(a.Name), q) || strings.Contains(strings.ToLower(a.CID), q)
+		}
+		if ok {
+			out = append(out, idx)
+		}
+	}
+	return out
+}
+
+// Resolve finds the single album named by query: an exact name or CID match,
+// or else a unique case-insensitive substring match.
+func Resolve(albums []model.Album, query string) (model.Album, error) {
+	idx, err := resolve(albums, query)
+	if err != nil {
+		return model.Album{}, err
+	}
+	return albums[idx], nil
+}
+
+func resolve(albums []model.Album, query string) (int, error) {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return 0, fmt.Errorf("empty album query")
+	}
+
+	exact := make([]int, 0, 1)
+	for idx, a := range albums {
+		if strings.EqualFold(a.CID, q) || strings.EqualFold(a.Name, q) {
+			exact = append(exact, idx)
+		}
+	}
+	if len(exact) == 1 {
+		return exact[0], nil
+	}
+	if len(exact) > 1 {
+		return 0, fmt.Errorf("album query %q matched multiple albums exactly; use CID", query)
+	}
+
+	contains := make([]int, 0, 4)
+	for idx, a := range albums {
+		if strings.Contains(strings.ToLower(a.Name), q) || strings.Contains(strings.ToLower(a.CID), q) {
+			contains = append(contains, idx)
+		}
+	}
+	if len(contains) == 1 {
+		return contains[0], nil
+	}
+	if len(contains) > 1 {
+		labels := make([]string, 0, len(contains))
+		for _, idx := range contains {
+			labels = append(labels, fmt.Sprintf("%s (%s)", albums[idx].Name, albums[idx].CID))
+		}
+		sort.Strings(labels)
+		return 0, fmt.Errorf("album query %q is ambiguous: %s", query, strings.Join(labels, ", "))
+	}
+
+	return 0, fmt.Errorf("album query %q not found", query)
+}
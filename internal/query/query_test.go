@@ -0,0 +1,194 @@
+package query
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"msr-archiver/internal/model"
+)
+
+var testAlbums = []model.Album{
+	{CID: "1003", Name: "Miracle Originium", Artistes: []string{"塞壬唱片-MSR"}},
+	{CID: "1002", Name: "Speed of Light", Artistes: []string{"DJ Okawari"}},
+	{CID: "1001", Name: "Operation Originium", Artistes: []string{"塞壬唱片-MSR", "DJ Okawari"}},
+	{CID: "1000", Name: "Alpha", Artistes: []string{"Other"}},
+}
+
+func completedCIDs(cids ...string) func(model.Album) bool {
+	set := make(map[string]bool)
+	for _, c := range cids {
+		set[c] = true
+	}
+	return func(a model.Album) bool { return set[a.CID] }
+}
+
+func cids(albums []model.Album) string {
+	out := make([]string, 0, len(albums))
+	for _, a := range albums {
+		out = append(out, a.CID)
+	}
+	return strings.Join(out, ",")
+}
+
+func selectCIDs(t *testing.T, raw string, completed func(model.Album) bool) string {
+	t.Helper()
+	expr, err := Parse(raw, Options{})
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", raw, err)
+	}
+	got, err := expr.Select(testAlbums, completed)
+	if err != nil {
+		t.Fatalf("Select(%q): %v", raw, err)
+	}
+	return cids(got)
+}
+
+func TestSelectTerms(t *testing.T) {
+	done := completedCIDs("1003", "1000")
+	cases := map[string]string{
+		"speed of light,1000":             "1002,1000",
+		"artist:塞壬唱片-MSR":                 "1003,1001",
+		"artist:okawari,not:operation":    "1002",
+		"re:^(miracle|alpha)$|originium$": "1003,1001,1000",
+		"new":                             "1002,1001",
+		"completed":                       "1003,1000",
+		"latest:2":                        "1003,1002",
+		"not:artist:MSR":                  "1002,1000",
+		"latest:3,not:new":                "1003",
+		"all":                             "1003,1002,1001,1000",
+	}
+	for raw, want := range cases {
+		if got := selectCIDs(t, raw, done); got != want {
+			t.Errorf("Select(%q) = %s, want %s", raw, got, want)
+		}
+	}
+}
+
+func TestSelectPlainTextMustBeUnique(t *testing.T) {
+	expr, err := Parse("originium", Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, err := expr.Select(testAlbums, nil); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("expected ambiguous error, got %v", err)
+	}
+
+	// The picker filter accepts the same text as a substring match.
+	if got := expr.Filter(testAlbums, nil); len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Fatalf("Filter = %v, want [0 2]", got)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	files := map[string]string{
+		"list.txt": "# archive queue\nSpeed of Light\n@more.txt\n",
+		"more.txt": "1000 # alpha\n",
+		"loop.txt": "@loop.txt\n",
+	}
+	opts := Options{ReadFile: func(path string) ([]byte, error) {
+		if s, ok := files[path]; ok {
+			return []byte(s), nil
+		}
+		return nil, os.ErrNotExist
+	}}
+
+	expr, err := Parse("@list.txt, latest:1", opts)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	got, err := expr.Select(testAlbums, nil)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if cids(got) != "1002,1000,1003" {
+		t.Fatalf("Select = %s", cids(got))
+	}
+
+	if _, err := Parse("@missing.txt", opts); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected missing file error, got %v", err)
+	}
+	if _, err := Parse("@loop.txt", opts); err == nil {
+		t.Fatalf("expected nested @file error")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, raw := range []string{"latest:0", "latest:x", "re:(", "artist:", "not:"} {
+		if _, err := Parse(raw, Options{}); err == nil {
+			t.Errorf("Parse(%q): expected error", raw)
+		}
+	}
+	if _, err := (Expr{}).Select(testAlbums, nil); err == nil {
+		t.Fatalf("expected empty query error")
+	}
+}
+
+func TestSongSelector(t *testing.T) {
+	songs := []model.Song{
+		{CID: "048760", Name: "Operation Pyrite"},
+		{CID: "048761", Name: "Operation Pyrite (Instrumental)"},
+		{CID: "048762", Name: "Speed of Light"},
+		{CID: "048763", Name: "Speed of Light (Instrumental)"},
+		{CID: "048764", Name: "Alive"},
+	}
+	match := func(raw string) string {
+		t.Helper()
+		sel, err := ParseSongs(raw)
+		if err != nil {
+			t.Fatalf("ParseSongs(%q): %v", raw, err)
+		}
+		var out []string
+		for i, s := range songs {
+			if sel.Match(i+1, s) {
+				out = append(out, s.CID[4:])
+			}
+		}
+		return strings.Join(out, ",")
+	}
+
+	cases := map[string]string{
+		"":             "60,61,62,63,64",
+		"1-2,5":        "60,61,64",
+		"instrumental": "61,63",
+		"048762":       "62",
+		"3, alive":     "62,64",
+	}
+	for raw, want := range cases {
+		if got := match(raw); got != want {
+			t.Errorf("songs %q = %s, want %s", raw, got, want)
+		}
+	}
+
+	for _, raw := range []string{"0", "3-1"} {
+		if _, err := ParseSongs(raw); err == nil {
+			t.Errorf("ParseSongs(%q): expected error", raw)
+		}
+	}
+}
+
+func TestSearchScoresTextTerms(t *testing.T) {
+	expr, err := Parse("originium, not:operation", Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	match := func(term, name string) (int, []int, bool) {
+		idx := strings.Index(strings.ToLower(name), term)
+		if idx < 0 {
+			return 0, nil, false
+		}
+		return 10, []int{idx}, true
+	}
+
+	hits := expr.Search(testAlbums, nil, match)
+	if len(hits) != 1 || hits[0].Index != 0 || hits[0].Score != 10 || len(hits[0].Positions) != 1 {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+
+	// A CID still matches even when the matcher rejects it.
+	expr, _ = Parse("1002", Options{})
+	if hits := expr.Search(testAlbums, nil, match); len(hits) != 1 || hits[0].Index != 1 {
+		t.Fatalf("unexpected CID hits: %+v", hits)
+	}
+}
@@ -0,0 +1,89 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"msr-archiver/internal/model"
+)
+
+// SongSelector picks songs within an album. Terms are comma-separated track
+// numbers or ranges (1-3,7), song CIDs, or title substrings. A song is
+// selected when it matches any term; an empty selector selects every song.
+type SongSelector struct {
+	ranges []trackRange
+	texts  []string
+}
+
+type trackRange struct {
+	from, to int
+	// cid is the original text of a single number, which may also be a CID.
+	cid string
+}
+
+// ParseSongs parses a --songs selector.
+func ParseSongs(raw string) (SongSelector, error) {
+	var s SongSelector
+	for _, part := range splitTerms(raw) {
+		if r, ok, err := parseTrackRange(part); err != nil {
+			return SongSelector{}, err
+		} else if ok {
+			s.ranges = append(s.ranges, r)
+			continue
+		}
+		s.texts = append(s.texts, strings.ToLower(part))
+	}
+	return s, nil
+}
+
+// parseTrackRange parses "N" or "N-M". Non-numeric terms are not ranges.
+func parseTrackRange(part string) (trackRange, bool, error) {
+	from, to, isRange := strings.Cut(part, "-")
+	lo, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return trackRange{}, false, nil
+	}
+	hi := lo
+	if isRange {
+		if hi, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+			return trackRange{}, false, nil
+		}
+	}
+	if lo < 1 || hi < lo {
+		return trackRange{}, false, fmt.Errorf("song selector %q: invalid track range", part)
+	}
+	r := trackRange{from: lo, to: hi}
+	if !isRange {
+		r.cid = strings.TrimSpace(part)
+	}
+	return r, true, nil
+}
+
+// Empty reports whether the selector selects every song.
+func (s SongSelector) Empty() bool {
+	return len(s.ranges) == 0 && len(s.texts) == 0
+}
+
+// Match reports whether song, at 1-based position track, is selected. Numeric
+// terms also match a song CID exactly.
+func (s SongSelector) Match(track int, song model.Song) bool {
+	if s.Empty() {
+		return true
+	}
+	for _, r := range s.ranges {
+		if track >= r.from && track <= r.to {
+			return true
+		}
+		if r.cid != "" && song.CID == r.cid {
+			return true
+		}
+	}
+	title := strings.ToLower(song.Name)
+	for _, t := range s.texts {
+		if strings.EqualFold(song.CID, t) || strings.Contains(title, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -74,17 +74,22 @@ func (s *Store) MarkCompleted(albumName string) error {
 		return fmt.Errorf("marshal state: %w", err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+	return writeFileAtomic(s.path, payload)
+}
+
+// writeFileAtomic replaces path with data via a temporary file and rename.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return fmt.Errorf("create state parent dir: %w", err)
 	}
 
-	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp.*")
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp.*")
 	if err != nil {
 		return fmt.Errorf("create temporary state file: %w", err)
 	}
 	tmpPath := tmp.Name()
 
-	if _, err := tmp.Write(payload); err != nil {
+	if _, err := tmp.Write(data); err != nil {
 		tmp.Close()
 		_ = os.Remove(tmpPath)
 		return fmt.Errorf("write temporary state file: %w", err)
@@ -95,7 +100,7 @@ func (s *Store) MarkCompleted(albumName string) error {
 		return fmt.Errorf("close temporary state file: %w", err)
 	}
 
-	if err := os.Rename(tmpPath, s.path); err != nil {
+	if err := os.Rename(tmpPath, path); err != nil {
 		_ = os.Remove(tmpPath)
 		return fmt.Errorf("atomic replace state file: %w", err)
 	}
@@ -0,0 +1,112 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LibraryTrack describes one archived track file.
+type LibraryTrack struct {
+	Number   int      `json:"number"`
+	CID      string   `json:"cid"`
+	Title    string   `json:"title"`
+	Artists  []string `json:"artists,omitempty"`
+	Path     string   `json:"path"`
+	FileType string   `json:"fileType"`
+	// DurationSeconds is zero when the duration could not be probed.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// AddedAt is when the file was downloaded. It is kept when the album is
+	// recorded again by a resume or refresh, and zero for tracks recorded
+	// before it was tracked.
+	AddedAt time.Time `json:"addedAt,omitzero"`
+}
+
+// LibraryAlbum describes an archived album and its tracks.
+type LibraryAlbum struct {
+	CID         string         `json:"cid"`
+	Name        string         `json:"name"`
+	Artists     []string       `json:"artists,omitempty"`
+	Dir         string         `json:"dir"`
+	CompletedAt time.Time      `json:"completedAt"`
+	Tracks      []LibraryTrack `json:"tracks"`
+}
+
+// LibraryStore persists the archived track layout keyed by album CID so
+// playlists and indexes can be generated without walking directories.
+type LibraryStore struct {
+	path string
+
+	mu     sync.Mutex
+	albums map[string]LibraryAlbum
+}
+
+// NewLibraryStore initializes library state from path if present.
+func NewLibraryStore(path string) (*LibraryStore, error) {
+	s := &LibraryStore{
+		path:   path,
+		albums: make(map[string]LibraryAlbum),
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read library state %s: %w", path, err)
+	}
+
+	var albums []LibraryAlbum
+	if err := json.Unmarshal(b, &albums); err != nil {
+		return nil, fmt.Errorf("parse library state %s: %w", path, err)
+	}
+	for _, a := range albums {
+		s.albums[a.CID] = a
+	}
+	return s, nil
+}
+
+// Album returns the recorded album for a CID.
+func (s *LibraryStore) Album(cid string) (LibraryAlbum, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.albums[cid]
+	return a, ok
+}
+
+// Albums returns all recorded albums sorted by CID.
+func (s *LibraryStore) Albums() []LibraryAlbum {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]LibraryAlbum, 0, len(s.albums))
+	for _, a := range s.albums {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CID < out[j].CID })
+	return out
+}
+
+// PutAlbum records an album and persists state atomically.
+func (s *LibraryStore) PutAlbum(album LibraryAlbum) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.albums[album.CID] = album
+
+	albums := make([]LibraryAlbum, 0, len(s.albums))
+	for _, a := range s.albums {
+		albums = append(albums, a)
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i].CID < albums[j].CID })
+
+	payload, err := json.MarshalIndent(albums, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal library state: %w", err)
+	}
+	return writeFileAtomic(s.path, payload)
+}
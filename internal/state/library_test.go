@@ -0,0 +1,41 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLibraryStorePutAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "library.json")
+	store, err := NewLibraryStore(path)
+	if err != nil {
+		t.Fatalf("NewLibraryStore failed: %v", err)
+	}
+
+	album := LibraryAlbum{
+		CID:         "a1",
+		Name:        "Album",
+		Dir:         "Album",
+		CompletedAt: time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC),
+		Tracks:      []LibraryTrack{{Number: 1, Title: "Song", Path: "Album/Song.flac", DurationSeconds: 12.5}},
+	}
+	if err := store.PutAlbum(album); err != nil {
+		t.Fatalf("PutAlbum failed: %v", err)
+	}
+
+	reloaded, err := NewLibraryStore(path)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	got, ok := reloaded.Album("a1")
+	if !ok {
+		t.Fatalf("expected album a1 after reload")
+	}
+	if len(got.Tracks) != 1 || got.Tracks[0].DurationSeconds != 12.5 {
+		t.Fatalf("unexpected tracks: %+v", got.Tracks)
+	}
+	if len(reloaded.Albums()) != 1 {
+		t.Fatalf("expected one album, got %d", len(reloaded.Albums()))
+	}
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"msr-archiver/internal/loudness"
+)
+
+// AlbumLoudness is the cached loudness analysis of one album.
+type AlbumLoudness struct {
+	Mode   string                          `json:"mode"`
+	Album  loudness.Measurement            `json:"album"`
+	Tracks map[string]loudness.Measurement `json:"tracks"`
+}
+
+// LoudnessStore persists loudness analysis results keyed by album name so
+// reruns do not analyze the same audio again.
+type LoudnessStore struct {
+	path string
+
+	mu     sync.Mutex
+	albums map[string]AlbumLoudness
+}
+
+// NewLoudnessStore initializes loudness state from path if present.
+func NewLoudnessStore(path string) (*LoudnessStore, error) {
+	s := &LoudnessStore{
+		path:   path,
+		albums: make(map[string]AlbumLoudness),
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read loudness state %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(b, &s.albums); err != nil {
+		return nil, fmt.Errorf("parse loudness state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the cached analysis for an album if it was made with mode and
+// covers exactly the given track files.
+func (s *LoudnessStore) Get(albumName, mode string, trackFiles []string) (AlbumLoudness, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.albums[albumName]
+	if !ok || entry.Mode != mode || len(entry.Tracks) != len(trackFiles) {
+		return AlbumLoudness{}, false
+	}
+	for _, f := range trackFiles {
+		if _, ok := entry.Tracks[f]; !ok {
+			return AlbumLoudness{}, false
+		}
+	}
+	return entry, true
+}
+
+// Put records an album analysis and persists state atomically.
+func (s *LoudnessStore) Put(albumName string, entry AlbumLoudness) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.albums[albumName] = entry
+
+	payload, err := json.MarshalIndent(s.albums, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal loudness state: %w", err)
+	}
+	return writeFileAtomic(s.path, payload)
+}
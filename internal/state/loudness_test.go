@@ -0,0 +1,60 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+
+	"msr-archiver/internal/loudness"
+)
+
+func TestLoudnessStorePutAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loudness.json")
+	store, err := NewLoudnessStore(path)
+	if err != nil {
+		t.Fatalf("NewLoudnessStore failed: %v", err)
+	}
+
+	entry := AlbumLoudness{
+		Mode:  loudness.ModeReplayGain,
+		Album: loudness.Measurement{IntegratedLUFS: -15, TruePeakDBFS: -1},
+		Tracks: map[string]loudness.Measurement{
+			"a.flac": {IntegratedLUFS: -14},
+			"b.flac": {IntegratedLUFS: -16},
+		},
+	}
+	if err := store.Put("Album", entry); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	reloaded, err := NewLoudnessStore(path)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	got, ok := reloaded.Get("Album", loudness.ModeReplayGain, []string{"b.flac", "a.flac"})
+	if !ok {
+		t.Fatalf("expected cached entry")
+	}
+	if got.Album.IntegratedLUFS != -15 {
+		t.Fatalf("unexpected album loudness: %+v", got.Album)
+	}
+}
+
+func TestLoudnessStoreGetMismatch(t *testing.T) {
+	store, err := NewLoudnessStore(filepath.Join(t.TempDir(), "loudness.json"))
+	if err != nil {
+		t.Fatalf("NewLoudnessStore failed: %v", err)
+	}
+	if err := store.Put("Album", AlbumLoudness{
+		Mode:   loudness.ModeReplayGain,
+		Tracks: map[string]loudness.Measurement{"a.flac": {}},
+	}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if _, ok := store.Get("Album", loudness.ModeR128, []string{"a.flac"}); ok {
+		t.Fatalf("different mode should miss")
+	}
+	if _, ok := store.Get("Album", loudness.ModeReplayGain, []string{"a.flac", "b.flac"}); ok {
+		t.Fatalf("different track set should miss")
+	}
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Selection is a set of albums, keyed by CID, with optional per-album song
+// CIDs for albums where only some songs are chosen.
+type Selection struct {
+	Albums  []string            `json:"albums"`
+	Songs   map[string][]string `json:"songs,omitempty"`
+	SavedAt time.Time           `json:"savedAt"`
+}
+
+// Empty reports whether no album is selected.
+func (s Selection) Empty() bool {
+	return len(s.Albums) == 0
+}
+
+type selectionFile struct {
+	Session *Selection           `json:"session,omitempty"`
+	Presets map[string]Selection `json:"presets,omitempty"`
+}
+
+// SelectionStore persists the picker's autosaved session and named presets.
+type SelectionStore struct {
+	path string
+
+	mu   sync.Mutex
+	data selectionFile
+}
+
+// NewSelectionStore initializes selection state from path if present.
+func NewSelectionStore(path string) (*SelectionStore, error) {
+	s := &SelectionStore{path: path}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read selection state %s: %w", path, err)
+	}
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		return nil, fmt.Errorf("parse selection state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Session returns the autosaved picker selection.
+func (s *SelectionStore) Session() (Selection, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Session == nil {
+		return Selection{}, false
+	}
+	return *s.data.Session, true
+}
+
+// SaveSession replaces the autosaved selection. An empty selection clears it.
+func (s *SelectionStore) SaveSession(sel Selection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sel.Empty() {
+		if s.data.Session == nil {
+			return nil
+		}
+		s.data.Session = nil
+	} else {
+		sel.SavedAt = time.Now().UTC()
+		s.data.Session = &sel
+	}
+	return s.persistLocked()
+}
+
+// ClearSession removes the autosaved selection.
+func (s *SelectionStore) ClearSession() error {
+	return s.SaveSession(Selection{})
+}
+
+// Preset returns a named selection.
+func (s *SelectionStore) Preset(name string) (Selection, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sel, ok := s.data.Presets[name]
+	return sel, ok
+}
+
+// Presets returns the preset names in sorted order.
+func (s *SelectionStore) Presets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.data.Presets))
+	for name := range s.data.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SavePreset stores sel under name, replacing an existing preset.
+func (s *SelectionStore) SavePreset(name string, sel Selection) error {
+	if name == "" {
+		return fmt.Errorf("preset name is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Presets == nil {
+		s.data.Presets = make(map[string]Selection)
+	}
+	sel.SavedAt = time.Now().UTC()
+	s.data.Presets[name] = sel
+	return s.persistLocked()
+}
+
+func (s *SelectionStore) persistLocked() error {
+	payload, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal selection state: %w", err)
+	}
+	return writeFileAtomic(s.path, payload)
+}
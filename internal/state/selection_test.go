@@ -0,0 +1,58 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSelectionStoreSessionAndPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selections.json")
+	store, err := NewSelectionStore(path)
+	if err != nil {
+		t.Fatalf("NewSelectionStore failed: %v", err)
+	}
+	if _, ok := store.Session(); ok {
+		t.Fatalf("expected no session in a new store")
+	}
+
+	session := Selection{Albums: []string{"a1", "a2"}, Songs: map[string][]string{"a2": {"s1"}}}
+	if err := store.SaveSession(session); err != nil {
+		t.Fatalf("SaveSession failed: %v", err)
+	}
+	if err := store.SavePreset("ost-main", Selection{Albums: []string{"a3"}}); err != nil {
+		t.Fatalf("SavePreset failed: %v", err)
+	}
+	if err := store.SavePreset("", Selection{Albums: []string{"a3"}}); err == nil {
+		t.Fatalf("expected error for empty preset name")
+	}
+
+	reloaded, err := NewSelectionStore(path)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	got, ok := reloaded.Session()
+	if !ok || len(got.Albums) != 2 || got.Songs["a2"][0] != "s1" || got.SavedAt.IsZero() {
+		t.Fatalf("unexpected session after reload: %+v", got)
+	}
+	preset, ok := reloaded.Preset("ost-main")
+	if !ok || len(preset.Albums) != 1 || preset.Albums[0] != "a3" {
+		t.Fatalf("unexpected preset after reload: %+v", preset)
+	}
+	if names := reloaded.Presets(); len(names) != 1 || names[0] != "ost-main" {
+		t.Fatalf("unexpected preset names: %v", names)
+	}
+
+	if err := reloaded.ClearSession(); err != nil {
+		t.Fatalf("ClearSession failed: %v", err)
+	}
+	cleared, err := NewSelectionStore(path)
+	if err != nil {
+		t.Fatalf("reload after clear failed: %v", err)
+	}
+	if _, ok := cleared.Session(); ok {
+		t.Fatalf("expected session to be cleared")
+	}
+	if _, ok := cleared.Preset("ost-main"); !ok {
+		t.Fatalf("clearing the session should keep presets")
+	}
+}
@@ -0,0 +1,317 @@
+package validate
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// ErrInvalid marks audio that failed validation. Callers should treat it as
+// a reason to download the track again.
+var ErrInvalid = errors.New("invalid audio")
+
+func invalidf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// ContentLength checks that the number of bytes written matches the
+// Content-Length advertised by the server. Unknown lengths (<= 0) pass.
+func ContentLength(expected, written int64) error {
+	if expected > 0 && expected != written {
+		return invalidf("received %d bytes, Content-Length was %d", written, expected)
+	}
+	return nil
+}
+
+// WAV checks the RIFF/WAVE header against the file size so truncated
+// downloads are detected before conversion.
+func WAV(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open wav: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat wav: %w", err)
+	}
+	size := info.Size()
+
+	var hdr [12]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return invalidf("wav header too short")
+	}
+	if err := WAVHeader(hdr[:], size); err != nil {
+		return err
+	}
+
+	offset := int64(12)
+	for offset+8 <= size {
+		var chunk [8]byte
+		if _, err := f.ReadAt(chunk[:], offset); err != nil {
+			return invalidf("read chunk header at %d: %v", offset, err)
+		}
+		chunkSize := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		if string(chunk[0:4]) == "data" {
+			if offset+8+chunkSize > size {
+				return invalidf("data chunk declares %d bytes, only %d present", chunkSize, size-offset-8)
+			}
+			return nil
+		}
+		offset += 8 + chunkSize + chunkSize%2
+	}
+	return invalidf("data chunk not found")
+}
+
+// WAVHeader checks the first 12 bytes of a WAV stream and, when size is
+// positive, that the RIFF size fits in size bytes.
+func WAVHeader(hdr []byte, size int64) error {
+	if len(hdr) < 12 {
+		return invalidf("wav header too short")
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return invalidf("missing RIFF/WAVE header")
+	}
+	riffSize := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+	if size > 0 && riffSize+8 > size {
+		return invalidf("RIFF header declares %d bytes, file has %d", riffSize+8, size)
+	}
+	return nil
+}
+
+// StreamInfo is the subset of the FLAC STREAMINFO block used for validation.
+type StreamInfo struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	TotalSamples  uint64
+	MD5           [16]byte
+}
+
+// FLACStreamInfo reads the STREAMINFO block of a FLAC file.
+func FLACStreamInfo(path string) (StreamInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return StreamInfo{}, fmt.Errorf("open flac: %w", err)
+	}
+	defer f.Close()
+
+	var hdr [4 + 4 + 34]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return StreamInfo{}, invalidf("flac header too short")
+	}
+	if string(hdr[0:4]) != "fLaC" {
+		return StreamInfo{}, invalidf("missing fLaC marker")
+	}
+	if hdr[4]&0x7f != 0 {
+		return StreamInfo{}, invalidf("first metadata block is not STREAMINFO")
+	}
+
+	si := hdr[8:]
+	packed := binary.BigEndian.Uint64(si[10:18])
+	info := StreamInfo{
+		SampleRate:    int(packed >> 44),
+		Channels:      int((packed>>41)&0x7) + 1,
+		BitsPerSample: int((packed>>36)&0x1f) + 1,
+		TotalSamples:  packed & 0xfffffffff,
+	}
+	copy(info.MD5[:], si[18:34])
+	if info.SampleRate == 0 {
+		return StreamInfo{}, invalidf("STREAMINFO has zero sample rate")
+	}
+	return info, nil
+}
+
+// MP3 checks that the file contains consecutive MPEG audio frames after any
+// ID3v2 tag.
+func MP3(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open mp3: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat mp3: %w", err)
+	}
+
+	offset := int64(0)
+	var id3 [10]byte
+	if _, err := f.ReadAt(id3[:], 0); err == nil && string(id3[0:3]) == "ID3" {
+		tagSize := int64(id3[6]&0x7f)<<21 | int64(id3[7]&0x7f)<<14 | int64(id3[8]&0x7f)<<7 | int64(id3[9]&0x7f)
+		offset = 10 + tagSize
+		if id3[5]&0x10 != 0 {
+			offset += 10
+		}
+	}
+
+	const framesToCheck = 3
+	for i := 0; i < framesToCheck; i++ {
+		var hdr [4]byte
+		if _, err := f.ReadAt(hdr[:], offset); err != nil {
+			if i > 0 && offset == info.Size() {
+				return nil
+			}
+			return invalidf("read mpeg frame header at %d: %v", offset, err)
+		}
+		length, ok := mpegFrameLength(hdr)
+		if !ok {
+			return invalidf("no mpeg frame sync at offset %d", offset)
+		}
+		offset += int64(length)
+		if offset > info.Size() {
+			return invalidf("mpeg frame at %d runs past end of file", offset-int64(length))
+		}
+	}
+	return nil
+}
+
+var (
+	mpeg1L3Bitrates = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mpeg2L3Bitrates = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mpegSampleRates = [4]int{44100, 48000, 32000, 0}
+)
+
+// mpegFrameLength returns the byte length of a Layer III frame.
+func mpegFrameLength(h [4]byte) (int, bool) {
+	if h[0] != 0xff || h[1]&0xe0 != 0xe0 {
+		return 0, false
+	}
+	version := (h[1] >> 3) & 0x3 // 3 = MPEG1, 2 = MPEG2, 0 = MPEG2.5
+	layer := (h[1] >> 1) & 0x3   // 1 = Layer III
+	if version == 1 || layer != 1 {
+		return 0, false
+	}
+
+	bitrateIdx := h[2] >> 4
+	rateIdx := (h[2] >> 2) & 0x3
+	padding := int((h[2] >> 1) & 0x1)
+
+	sampleRate := mpegSampleRates[rateIdx]
+	if sampleRate == 0 {
+		return 0, false
+	}
+
+	if version == 3 {
+		bitrate := mpeg1L3Bitrates[bitrateIdx] * 1000
+		if bitrate == 0 {
+			return 0, false
+		}
+		return 144*bitrate/sampleRate + padding, true
+	}
+
+	sampleRate /= 2
+	if version == 0 {
+		sampleRate /= 2
+	}
+	bitrate := mpeg2L3Bitrates[bitrateIdx] * 1000
+	if bitrate == 0 {
+		return 0, false
+	}
+	return 72*bitrate/sampleRate + padding, true
+}
+
+// Decode fully decodes a finished FLAC or MP3 file with ffmpeg and fails on
+// any decoder error. For FLAC, the decoded PCM is hashed and compared to the
+// STREAMINFO MD5 when the encoder recorded one.
+func Decode(ctx context.Context, path, fileType string) error {
+	switch fileType {
+	case ".flac":
+		info, err := FLACStreamInfo(path)
+		if err != nil {
+			return err
+		}
+		return decodeFLAC(ctx, path, info)
+	case ".mp3":
+		if err := MP3(path); err != nil {
+			return err
+		}
+		return decodeNull(ctx, path)
+	case ".ogg":
+		return decodeNull(ctx, path)
+	default:
+		return nil
+	}
+}
+
+func decodeNull(ctx context.Context, path string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "error", "-xerror", "-i", path, "-f", "null", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return invalidf("decode %s: %v: %s", path, err, stderr.String())
+	}
+	if stderr.Len() > 0 {
+		return invalidf("decode %s: %s", path, stderr.String())
+	}
+	return nil
+}
+
+func decodeFLAC(ctx context.Context, path string, info StreamInfo) error {
+	if info.MD5 == [16]byte{} {
+		return decodeNull(ctx, path)
+	}
+
+	format, ok := pcmFormat(info.BitsPerSample)
+	if !ok {
+		return decodeNull(ctx, path)
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-v", "error", "-xerror", "-i", path, "-f", format, "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("ffmpeg stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start ffmpeg decode: %w", err)
+	}
+
+	h := md5.New()
+	if _, err := io.Copy(h, stdout); err != nil {
+		_ = cmd.Wait()
+		return fmt.Errorf("read decoded pcm: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return invalidf("decode %s: %v: %s", path, err, stderr.String())
+	}
+
+	var sum [16]byte
+	copy(sum[:], h.Sum(nil))
+	if sum != info.MD5 {
+		return invalidf("decoded audio MD5 %x does not match STREAMINFO %x", sum, info.MD5)
+	}
+	return nil
+}
+
+// pcmFormat maps FLAC bit depth to the raw sample layout STREAMINFO MD5 is
+// computed over.
+func pcmFormat(bits int) (string, bool) {
+	switch bits {
+	case 8:
+		return "s8", true
+	case 16:
+		return "s16le", true
+	case 24:
+		return "s24le", true
+	case 32:
+		return "s32le", true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,148 @@
+package validate
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func wavBytes(dataLen int) []byte {
+	b := make([]byte, 0, 44+dataLen)
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+dataLen))
+	b = append(b, "WAVE"...)
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)     // PCM
+	b = binary.LittleEndian.AppendUint16(b, 2)     // channels
+	b = binary.LittleEndian.AppendUint32(b, 44100) // sample rate
+	b = binary.LittleEndian.AppendUint32(b, 44100*4)
+	b = binary.LittleEndian.AppendUint16(b, 4)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(dataLen))
+	return append(b, make([]byte, dataLen)...)
+}
+
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestWAVValid(t *testing.T) {
+	if err := WAV(writeTemp(t, "ok.wav", wavBytes(64))); err != nil {
+		t.Fatalf("expected valid wav, got %v", err)
+	}
+}
+
+func TestWAVTruncated(t *testing.T) {
+	b := wavBytes(64)
+	err := WAV(writeTemp(t, "short.wav", b[:len(b)-10]))
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for truncated wav, got %v", err)
+	}
+}
+
+func TestWAVMissingHeader(t *testing.T) {
+	err := WAV(writeTemp(t, "bad.wav", []byte("not a wav file at all")))
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+}
+
+func TestWAVHeaderStream(t *testing.T) {
+	b := wavBytes(64)
+	if err := WAVHeader(b[:12], -1); err != nil {
+		t.Fatalf("unknown length should only check magic, got %v", err)
+	}
+	if err := WAVHeader(b[:12], int64(len(b))); err != nil {
+		t.Fatalf("expected valid header, got %v", err)
+	}
+	if err := WAVHeader(b[:12], int64(len(b)-1)); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for short Content-Length, got %v", err)
+	}
+	if err := WAVHeader([]byte("ID3"), -1); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for short header, got %v", err)
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	if err := ContentLength(-1, 10); err != nil {
+		t.Fatalf("unknown length should pass: %v", err)
+	}
+	if err := ContentLength(10, 10); err != nil {
+		t.Fatalf("matching length should pass: %v", err)
+	}
+	if err := ContentLength(10, 7); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for short body, got %v", err)
+	}
+}
+
+func TestFLACStreamInfo(t *testing.T) {
+	b := []byte("fLaC")
+	b = append(b, 0x80, 0, 0, 34) // last block, STREAMINFO, length 34
+	si := make([]byte, 34)
+	// sample rate 44100 (20 bits), channels-1 = 1 (3 bits), bps-1 = 15 (5 bits), total samples 1000 (36 bits)
+	packed := uint64(44100)<<44 | uint64(1)<<41 | uint64(15)<<36 | 1000
+	binary.BigEndian.PutUint64(si[10:18], packed)
+	for i := 18; i < 34; i++ {
+		si[i] = byte(i)
+	}
+	b = append(b, si...)
+
+	info, err := FLACStreamInfo(writeTemp(t, "a.flac", b))
+	if err != nil {
+		t.Fatalf("FLACStreamInfo failed: %v", err)
+	}
+	if info.SampleRate != 44100 || info.Channels != 2 || info.BitsPerSample != 16 || info.TotalSamples != 1000 {
+		t.Fatalf("unexpected stream info: %+v", info)
+	}
+	if info.MD5[0] != 18 || info.MD5[15] != 33 {
+		t.Fatalf("unexpected md5: %x", info.MD5)
+	}
+}
+
+func TestFLACStreamInfoRejectsNonFLAC(t *testing.T) {
+	_, err := FLACStreamInfo(writeTemp(t, "a.flac", make([]byte, 64)))
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+}
+
+// mp3Frame returns an MPEG1 Layer III 128kbps 44.1kHz frame (417 bytes).
+func mp3Frame() []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+	return frame
+}
+
+func TestMP3Valid(t *testing.T) {
+	id3 := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 5, 0, 0, 0, 0, 0}
+	b := append(id3, mp3Frame()...)
+	b = append(b, mp3Frame()...)
+	b = append(b, mp3Frame()...)
+	if err := MP3(writeTemp(t, "a.mp3", b)); err != nil {
+		t.Fatalf("expected valid mp3, got %v", err)
+	}
+}
+
+func TestMP3WithoutFrameSync(t *testing.T) {
+	err := MP3(writeTemp(t, "a.mp3", []byte("fake-mp3-data-without-frames")))
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+}
+
+func TestMP3TruncatedFrame(t *testing.T) {
+	b := append(mp3Frame(), mp3Frame()[:100]...)
+	err := MP3(writeTemp(t, "a.mp3", b))
+	if !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for truncated frame, got %v", err)
+	}
+}
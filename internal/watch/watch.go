@@ -0,0 +1,172 @@
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuietHours is a daily window during which no catalog checks run.
+// A window whose end is before its start wraps past midnight.
+type QuietHours struct {
+	Start   time.Duration
+	End     time.Duration
+	Enabled bool
+}
+
+// ParseQuietHours parses "HH:MM-HH:MM". An empty string disables quiet hours.
+func ParseQuietHours(raw string) (QuietHours, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return QuietHours{}, nil
+	}
+
+	startRaw, endRaw, ok := strings.Cut(raw, "-")
+	if !ok {
+		return QuietHours{}, fmt.Errorf("quiet hours %q: want HH:MM-HH:MM", raw)
+	}
+	start, err := parseClock(startRaw)
+	if err != nil {
+		return QuietHours{}, fmt.Errorf("quiet hours %q: %w", raw, err)
+	}
+	end, err := parseClock(endRaw)
+	if err != nil {
+		return QuietHours{}, fmt.Errorf("quiet hours %q: %w", raw, err)
+	}
+	if start == end {
+		return QuietHours{}, fmt.Errorf("quiet hours %q: start and end are equal", raw)
+	}
+	return QuietHours{Start: start, End: end, Enabled: true}, nil
+}
+
+func parseClock(raw string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(raw))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", raw)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Active reports whether t falls inside the quiet window (local clock of t).
+func (q QuietHours) Active(t time.Time) bool {
+	if !q.Enabled {
+		return false
+	}
+	clock := sinceMidnight(t)
+	if q.Start < q.End {
+		return clock >= q.Start && clock < q.End
+	}
+	return clock >= q.Start || clock < q.End
+}
+
+// Until returns how long until the quiet window that contains t ends, or zero
+// when t is outside the window.
+func (q QuietHours) Until(t time.Time) time.Duration {
+	if !q.Active(t) {
+		return 0
+	}
+	clock := sinceMidnight(t)
+	if clock < q.End {
+		return q.End - clock
+	}
+	return 24*time.Hour - clock + q.End
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+}
+
+// Status tracks watch-mode progress and serves it as a health endpoint.
+type Status struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	checking  bool
+	lastCheck time.Time
+	lastErr   string
+	nextCheck time.Time
+	archived  int
+	checks    int
+}
+
+// NewStatus creates a Status for a watch loop started at now.
+func NewStatus(now time.Time) *Status {
+	return &Status{startedAt: now}
+}
+
+// BeginCheck marks a catalog check as running.
+func (s *Status) BeginCheck() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checking = true
+}
+
+// EndCheck records the outcome of a catalog check.
+func (s *Status) EndCheck(at time.Time, archived int, err error, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checking = false
+	s.checks++
+	s.lastCheck = at
+	s.archived += archived
+	s.nextCheck = next
+	s.lastErr = ""
+	if err != nil {
+		s.lastErr = err.Error()
+	}
+}
+
+// SetNextCheck records when the next check is scheduled.
+func (s *Status) SetNextCheck(next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextCheck = next
+}
+
+type statusPayload struct {
+	Status    string `json:"status"`
+	StartedAt string `json:"startedAt"`
+	Checking  bool   `json:"checking"`
+	Checks    int    `json:"checks"`
+	LastCheck string `json:"lastCheck,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+	NextCheck string `json:"nextCheck,omitempty"`
+	Archived  int    `json:"albumsArchived"`
+}
+
+// ServeHTTP reports watch status as JSON. It responds 503 when the last check
+// failed.
+func (s *Status) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	p := statusPayload{
+		Status:    "ok",
+		StartedAt: s.startedAt.UTC().Format(time.RFC3339),
+		Checking:  s.checking,
+		Checks:    s.checks,
+		LastError: s.lastErr,
+		Archived:  s.archived,
+	}
+	if !s.lastCheck.IsZero() {
+		p.LastCheck = s.lastCheck.UTC().Format(time.RFC3339)
+	}
+	if !s.nextCheck.IsZero() {
+		p.NextCheck = s.nextCheck.UTC().Format(time.RFC3339)
+	}
+	s.mu.Unlock()
+
+	code := http.StatusOK
+	if p.LastError != "" {
+		p.Status = "degraded"
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,94 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2026, time.March, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseQuietHoursEmptyDisables(t *testing.T) {
+	q, err := ParseQuietHours("")
+	if err != nil {
+		t.Fatalf("ParseQuietHours failed: %v", err)
+	}
+	if q.Active(at(3, 0)) {
+		t.Fatalf("disabled quiet hours should never be active")
+	}
+}
+
+func TestParseQuietHoursInvalid(t *testing.T) {
+	for _, raw := range []string{"01:00", "25:00-02:00", "01:00-01:00"} {
+		if _, err := ParseQuietHours(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestQuietHoursSameDay(t *testing.T) {
+	q, err := ParseQuietHours("09:00-17:30")
+	if err != nil {
+		t.Fatalf("ParseQuietHours failed: %v", err)
+	}
+	if !q.Active(at(12, 0)) || q.Active(at(8, 59)) || q.Active(at(17, 30)) {
+		t.Fatalf("unexpected active window for %+v", q)
+	}
+	if got := q.Until(at(17, 0)); got != 30*time.Minute {
+		t.Fatalf("unexpected remaining quiet time: %s", got)
+	}
+}
+
+func TestQuietHoursWrapsMidnight(t *testing.T) {
+	q, err := ParseQuietHours("23:00-06:00")
+	if err != nil {
+		t.Fatalf("ParseQuietHours failed: %v", err)
+	}
+	if !q.Active(at(23, 30)) || !q.Active(at(2, 0)) || q.Active(at(6, 0)) || q.Active(at(12, 0)) {
+		t.Fatalf("unexpected active window for %+v", q)
+	}
+	if got := q.Until(at(23, 0)); got != 7*time.Hour {
+		t.Fatalf("unexpected remaining quiet time before midnight: %s", got)
+	}
+	if got := q.Until(at(5, 0)); got != time.Hour {
+		t.Fatalf("unexpected remaining quiet time after midnight: %s", got)
+	}
+	if got := q.Until(at(12, 0)); got != 0 {
+		t.Fatalf("expected zero outside window, got %s", got)
+	}
+}
+
+func TestStatusServeHTTP(t *testing.T) {
+	s := NewStatus(at(0, 0))
+	s.EndCheck(at(1, 0), 2, nil, at(2, 0))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var p statusPayload
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if p.Status != "ok" || p.Archived != 2 || p.Checks != 1 || p.NextCheck == "" {
+		t.Fatalf("unexpected payload: %+v", p)
+	}
+}
+
+func TestStatusReportsFailure(t *testing.T) {
+	s := NewStatus(at(0, 0))
+	s.EndCheck(at(1, 0), 0, errors.New("catalog unavailable"), at(2, 0))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+}
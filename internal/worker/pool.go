@@ -1,9 +1,11 @@
 package worker
 
 import (
+	"container/heap"
 	"context"
 	"errors"
 	"sync"
+	"time"
 )
 
 // Job is a unit of work to execute in the pool.
@@ -11,52 +13,272 @@ type Job func(context.Context) error
 
 // Run executes jobs with bounded concurrency and returns a joined error.
 func Run(ctx context.Context, workers int, jobs []Job) error {
-	if workers < 1 {
-		workers = 1
-	}
 	if len(jobs) == 0 {
 		return nil
 	}
+	p := New(ctx, Options{Workers: workers})
+	for _, job := range jobs {
+		_ = p.Submit("", 0, job)
+	}
+	_, err := p.Wait()
+	return err
+}
 
-	jobCh := make(chan Job)
-	errCh := make(chan error, len(jobs))
+// ErrClosed is returned by Submit once the pool has drained after Close.
+var ErrClosed = errors.New("worker pool closed")
 
-	var wg sync.WaitGroup
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for job := range jobCh {
-				if err := job(ctx); err != nil {
-					errCh <- err
-				}
-			}
-		}()
+// ErrSkipped is the error of jobs that never started because the pool was
+// canceled first.
+var ErrSkipped = errors.New("job skipped")
+
+// Options configures a Pool.
+type Options struct {
+	// Workers bounds the number of jobs running at once. Values below one
+	// mean one.
+	Workers int
+	// FailFast cancels running jobs and skips queued ones after the first
+	// job error.
+	FailFast bool
+}
+
+// Result is the outcome of one submitted job.
+type Result struct {
+	ID       string
+	Priority int
+	// Err is nil on success, ErrSkipped (wrapping the cancellation cause)
+	// for jobs that never started, or the job's error.
+	Err      error
+	Started  time.Time
+	Duration time.Duration
+}
+
+// Skipped reports whether the job never ran.
+func (r Result) Skipped() bool {
+	return errors.Is(r.Err, ErrSkipped)
+}
+
+// Pool runs submitted jobs with bounded concurrency. Queued jobs start in
+// priority order, lowest value first and in submission order among equals.
+// Jobs may be submitted while the pool runs, including from running jobs.
+type Pool struct {
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	opts   Options
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    jobQueue
+	seq      int
+	running  int
+	closed   bool
+	results  []Result
+	resultCh chan Result
+	pending  []Result
+	errs     []error
+
+	workers sync.WaitGroup
+	deliver sync.WaitGroup
+}
+
+// New starts a pool whose jobs run with ctx.
+func New(ctx context.Context, opts Options) *Pool {
+	if opts.Workers < 1 {
+		opts.Workers = 1
 	}
+	poolCtx, cancel := context.WithCancelCause(ctx)
+	p := &Pool{parent: ctx, ctx: poolCtx, cancel: cancel, opts: opts}
+	p.cond = sync.NewCond(&p.mu)
 
-enqueueLoop:
-	for _, job := range jobs {
-		select {
-		case <-ctx.Done():
-			break enqueueLoop
-		case jobCh <- job:
-		}
+	for i := 0; i < opts.Workers; i++ {
+		p.workers.Add(1)
+		go p.work()
+	}
+	// Wake idle workers when the pool is canceled so queued jobs are skipped.
+	stop := context.AfterFunc(poolCtx, func() {
+		p.mu.Lock()
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	})
+	go func() {
+		p.workers.Wait()
+		stop()
+	}()
+	return p
+}
+
+// Submit queues job under id. It fails with ErrClosed once the pool was
+// closed and every job has finished.
+func (p *Pool) Submit(id string, priority int, job Job) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.drained() {
+		return ErrClosed
+	}
+	heap.Push(&p.queue, &queuedJob{id: id, priority: priority, seq: p.seq, job: job})
+	p.seq++
+	// The results forwarder waits on the same cond, so Signal could wake it
+	// instead of an idle worker and leave the job queued.
+	p.cond.Broadcast()
+	return nil
+}
+
+// Results returns a channel receiving every result as jobs finish. It is
+// closed after Close once all jobs are done. Results must be called before
+// the first job finishes to see every result, and the channel must be
+// drained.
+func (p *Pool) Results() <-chan Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resultCh == nil {
+		p.resultCh = make(chan Result)
+		p.deliver.Add(1)
+		go p.forward()
 	}
-	close(jobCh)
+	return p.resultCh
+}
 
-	wg.Wait()
-	close(errCh)
+// Close marks the end of external submissions. Running jobs may still
+// submit follow-up jobs.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+// Wait closes the pool, waits for all jobs and returns the results in
+// completion order together with the joined job errors. Like Run, a
+// canceled parent context is reported as well.
+func (p *Pool) Wait() ([]Result, error) {
+	p.Close()
+	p.workers.Wait()
+	p.deliver.Wait()
+	p.cancel(nil)
 
-	var errs []error
-	for err := range errCh {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	errs := append([]error(nil), p.errs...)
+	if err := p.parent.Err(); err != nil {
 		errs = append(errs, err)
 	}
-	if ctxErr := ctx.Err(); ctxErr != nil {
-		errs = append(errs, ctxErr)
+	return append([]Result(nil), p.results...), errors.Join(errs...)
+}
+
+// errFailFast is the cancellation cause after a job failed in fail-fast mode.
+var errFailFast = errors.New("canceled after a job failed")
+
+// drained reports whether the pool is closed with no queued or running jobs.
+// Callers hold p.mu.
+func (p *Pool) drained() bool {
+	return p.closed && p.running == 0 && p.queue.Len() == 0
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+	for {
+		p.mu.Lock()
+		for p.queue.Len() == 0 && !p.drained() {
+			p.cond.Wait()
+		}
+		if p.queue.Len() == 0 {
+			p.cond.Broadcast()
+			p.mu.Unlock()
+			return
+		}
+		next := heap.Pop(&p.queue).(*queuedJob)
+		if p.ctx.Err() != nil {
+			p.record(Result{
+				ID:       next.id,
+				Priority: next.priority,
+				Err:      skipped(context.Cause(p.ctx)),
+			})
+			p.mu.Unlock()
+			continue
+		}
+		p.running++
+		p.mu.Unlock()
+
+		started := time.Now()
+		err := next.job(p.ctx)
+		res := Result{ID: next.id, Priority: next.priority, Err: err, Started: started, Duration: time.Since(started)}
+
+		p.mu.Lock()
+		p.running--
+		p.record(res)
+		if err != nil {
+			p.errs = append(p.errs, err)
+			if p.opts.FailFast {
+				p.cancel(errFailFast)
+			}
+		}
+		if p.drained() {
+			p.cond.Broadcast()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// record stores res and hands it to the forwarder. Callers hold p.mu.
+func (p *Pool) record(res Result) {
+	p.results = append(p.results, res)
+	if p.resultCh != nil {
+		p.pending = append(p.pending, res)
+		p.cond.Broadcast()
 	}
+}
 
-	if len(errs) > 0 {
-		return errors.Join(errs...)
+// forward delivers results to resultCh without blocking workers on a slow
+// reader.
+func (p *Pool) forward() {
+	defer p.deliver.Done()
+	defer close(p.resultCh)
+	for {
+		p.mu.Lock()
+		for len(p.pending) == 0 && !p.drained() {
+			p.cond.Wait()
+		}
+		if len(p.pending) == 0 {
+			p.mu.Unlock()
+			return
+		}
+		res := p.pending[0]
+		p.pending = p.pending[1:]
+		p.mu.Unlock()
+		p.resultCh <- res
 	}
-	return nil
+}
+
+func skipped(cause error) error {
+	if cause == nil || cause == errFailFast {
+		return ErrSkipped
+	}
+	return errors.Join(ErrSkipped, cause)
+}
+
+type queuedJob struct {
+	id       string
+	priority int
+	seq      int
+	job      Job
+}
+
+// jobQueue is a min-heap on (priority, seq).
+type jobQueue []*queuedJob
+
+func (q jobQueue) Len() int { return len(q) }
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority < q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *jobQueue) Push(x any)   { *q = append(*q, x.(*queuedJob)) }
+func (q *jobQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
 }
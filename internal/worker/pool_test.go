@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestRunProcessesAllJobs(t *testing.T) {
@@ -69,3 +71,145 @@ func TestRunReturnsContextErrorWhenCanceled(t *testing.T) {
 		t.Fatalf("expected context.Canceled, got %v", err)
 	}
 }
+
+func TestPoolRunsQueuedJobsByPriority(t *testing.T) {
+	p := New(context.Background(), Options{Workers: 1})
+	release := make(chan struct{})
+	if err := p.Submit("blocker", 0, func(context.Context) error {
+		<-release
+		return nil
+	}); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	for _, job := range []struct {
+		id       string
+		priority int
+	}{{"c", 3}, {"a", 1}, {"b", 2}, {"a2", 1}} {
+		id := job.id
+		p.Submit(id, job.priority, func(context.Context) error {
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+			return nil
+		})
+	}
+	close(release)
+
+	if _, err := p.Wait(); err != nil {
+		t.Fatalf("Wait returned unexpected error: %v", err)
+	}
+	if got := strings.Join(order, ","); got != "a,a2,b,c" {
+		t.Fatalf("run order = %s, want a,a2,b,c", got)
+	}
+}
+
+func TestPoolFailFastSkipsQueuedJobs(t *testing.T) {
+	errBoom := errors.New("boom")
+	p := New(context.Background(), Options{Workers: 1, FailFast: true})
+	p.Submit("fail", 0, func(context.Context) error { return errBoom })
+	var ran atomic.Bool
+	p.Submit("later", 1, func(context.Context) error {
+		ran.Store(true)
+		return nil
+	})
+
+	results, err := p.Wait()
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("Wait error = %v, want the job error", err)
+	}
+	if ran.Load() {
+		t.Fatalf("queued job ran after a failure in fail-fast mode")
+	}
+	if len(results) != 2 || !results[1].Skipped() || results[1].ID != "later" {
+		t.Fatalf("results = %+v, want the later job skipped", results)
+	}
+}
+
+func TestPoolAcceptsJobsFromRunningJobs(t *testing.T) {
+	p := New(context.Background(), Options{Workers: 2})
+	var count atomic.Int32
+	p.Submit("album", 0, func(context.Context) error {
+		for i := 0; i < 3; i++ {
+			if err := p.Submit("track", 0, func(context.Context) error {
+				count.Add(1)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	p.Close()
+
+	results, err := p.Wait()
+	if err != nil {
+		t.Fatalf("Wait returned unexpected error: %v", err)
+	}
+	if count.Load() != 3 || len(results) != 4 {
+		t.Fatalf("ran %d tracks with %d results, want 3 and 4", count.Load(), len(results))
+	}
+	if err := p.Submit("late", 0, func(context.Context) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Submit after Wait = %v, want ErrClosed", err)
+	}
+}
+
+func TestPoolResultsChannelReportsTiming(t *testing.T) {
+	p := New(context.Background(), Options{Workers: 2})
+	results := p.Results()
+	p.Submit("slow", 0, func(context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	p.Submit("fast", 0, func(context.Context) error { return errors.New("nope") })
+	p.Close()
+
+	got := make(map[string]Result)
+	for res := range results {
+		got[res.ID] = res
+	}
+	if _, err := p.Wait(); err == nil {
+		t.Fatalf("expected the failed job in the joined error")
+	}
+	if len(got) != 2 {
+		t.Fatalf("received %d results, want 2", len(got))
+	}
+	if slow := got["slow"]; slow.Err != nil || slow.Duration < 20*time.Millisecond || slow.Started.IsZero() {
+		t.Fatalf("slow result = %+v", slow)
+	}
+	if got["fast"].Err == nil {
+		t.Fatalf("fast result lost its error")
+	}
+}
+
+func TestPoolStartsJobsSubmittedAfterResults(t *testing.T) {
+	p := New(context.Background(), Options{Workers: 1})
+	results := p.Results()
+	go func() {
+		for range results {
+		}
+	}()
+
+	// Each submit comes once the previous job ran, when the worker and the
+	// results forwarder are both waiting. Run with -race to widen the window.
+	for i := 0; i < 500; i++ {
+		started := make(chan struct{})
+		if err := p.Submit("job", 0, func(context.Context) error {
+			close(started)
+			return nil
+		}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("job %d submitted to an open pool never started", i)
+		}
+		time.Sleep(20 * time.Microsecond)
+	}
+	if _, err := p.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+}